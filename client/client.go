@@ -0,0 +1,221 @@
+// Package client is the Go client of the raptor API. Every call takes a
+// context, requests are authorized with a bearer token and transient
+// failures are retried.
+//
+//	c := client.New(client.NewConfig().
+//		WithURL("https://api.example.com").
+//		WithToken(os.Getenv("RAPTOR_API_TOKEN")))
+//	endpoint, err := c.CreateEndpoint(ctx, client.CreateEndpointParams{
+//		Name:    "my-endpoint",
+//		Runtime: "go",
+//	})
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/anthdm/raptor/internal/version"
+)
+
+// Version is the version of the client, which is sent in the User-Agent
+// header of every request.
+const Version = version.Version
+
+const (
+	defaultURL          = "http://localhost:3000"
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 200 * time.Millisecond
+	maxRetryBackoff     = 10 * time.Second
+)
+
+// Config holds the configuration of a Client.
+type Config struct {
+	url          string
+	token        string
+	httpClient   *http.Client
+	maxRetries   int
+	retryBackoff time.Duration
+}
+
+// NewConfig returns the default configuration, which talks to an API on
+// localhost:3000 and retries transient failures 3 times.
+func NewConfig() Config {
+	return Config{
+		url:          defaultURL,
+		httpClient:   http.DefaultClient,
+		maxRetries:   defaultMaxRetries,
+		retryBackoff: defaultRetryBackoff,
+	}
+}
+
+// WithURL sets the URL of the API.
+func (c Config) WithURL(url string) Config {
+	c.url = strings.TrimSuffix(url, "/")
+	return c
+}
+
+// WithToken sets the API token that is sent as a bearer token.
+func (c Config) WithToken(token string) Config {
+	c.token = token
+	return c
+}
+
+// WithHTTPClient sets the HTTP client the requests are made with.
+func (c Config) WithHTTPClient(httpClient *http.Client) Config {
+	c.httpClient = httpClient
+	return c
+}
+
+// WithRetries sets the number of times a request is retried after a
+// transient failure. Zero disables retries.
+func (c Config) WithRetries(n int) Config {
+	c.maxRetries = n
+	return c
+}
+
+// WithRetryBackoff sets the wait before the first retry, which doubles with
+// every next retry.
+func (c Config) WithRetryBackoff(backoff time.Duration) Config {
+	c.retryBackoff = backoff
+	return c
+}
+
+// Client is a client of the raptor API. It is safe for concurrent use.
+type Client struct {
+	config Config
+}
+
+// New returns a new client with the given configuration.
+func New(config Config) *Client {
+	return &Client{
+		config: config,
+	}
+}
+
+// request is a request to the API. The body is kept in memory so the
+// request can be retried.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	contentType string
+	body        []byte
+}
+
+func jsonRequest(method, path string, params any) (request, error) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return request{}, err
+	}
+	return request{method: method, path: path, contentType: "application/json", body: b}, nil
+}
+
+// Status returns nil when the API is up.
+func (c *Client) Status(ctx context.Context) error {
+	return c.do(ctx, request{method: http.MethodGet, path: "/status"}, nil)
+}
+
+// do makes the request and decodes the response into v, if not nil. Transient
+// failures are retried with an exponential backoff.
+func (c *Client) do(ctx context.Context, req request, v any) error {
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, req, v)
+		if err == nil || attempt >= c.config.maxRetries || !retryable(req.method, err) {
+			return err
+		}
+		wait := c.config.retryBackoff << attempt
+		if wait > maxRetryBackoff || wait <= 0 {
+			wait = maxRetryBackoff
+		}
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.retryAfter > 0 {
+			wait = apiErr.retryAfter
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, req request, v any) error {
+	u := c.config.url + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u, body)
+	if err != nil {
+		return err
+	}
+	if len(req.contentType) > 0 {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("User-Agent", "raptor-go/"+Version)
+	if len(c.config.token) > 0 {
+		httpReq.Header.Set("Authorization", "Bearer "+c.config.token)
+	}
+
+	resp, err := c.config.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return readError(resp)
+	}
+	if v == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("could not decode the response of %s %s: %w", req.method, req.path, err)
+	}
+	return nil
+}
+
+// retryable reports whether a request that failed with err may be retried.
+// Requests that were refused by the server are always retried, others only
+// when they are idempotent.
+func retryable(method string, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests:
+			return true
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return idempotent(method)
+		}
+		return false
+	}
+	// Errors of the HTTP client are network errors.
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) && idempotent(method)
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,265 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anthdm/raptor/internal/api"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpoints(t *testing.T) {
+	c, _ := createClient(t)
+	ctx := context.Background()
+
+	endpoint, err := c.CreateEndpoint(ctx, CreateEndpointParams{
+		Name:        "my-endpoint",
+		Runtime:     "go",
+		Environment: map[string]string{"FOO": "bar"},
+	})
+	require.Nil(t, err)
+	require.Equal(t, "my-endpoint", endpoint.Slug)
+
+	other, err := c.GetEndpoint(ctx, endpoint.ID)
+	require.Nil(t, err)
+	require.Equal(t, endpoint.ID, other.ID)
+
+	endpoints, err := c.ListEndpoints(ctx)
+	require.Nil(t, err)
+	require.Len(t, endpoints, 1)
+
+	endpoint, err = c.SetEnvironment(ctx, endpoint.ID, map[string]string{"BAR": "baz"})
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{"FOO": "bar", "BAR": "baz"}, endpoint.Environment)
+	endpoint, err = c.UnsetEnvironment(ctx, endpoint.ID, "FOO")
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{"BAR": "baz"}, endpoint.Environment)
+	endpoint, err = c.ReplaceEnvironment(ctx, endpoint.ID, nil)
+	require.Nil(t, err)
+	require.Empty(t, endpoint.Environment)
+
+	_, err = c.UpdateEndpoint(ctx, endpoint.ID, UpdateEndpointParams{
+		Environment: map[string]string{"FOO": "bar"},
+		Version:     endpoint.Version - 1,
+	})
+	require.True(t, errors.Is(err, ErrVersionConflict))
+
+	purged, err := c.PurgeCache(ctx, endpoint.ID)
+	require.Nil(t, err)
+	require.Equal(t, endpoint.Version, purged.Version)
+	require.Equal(t, endpoint.CacheGeneration+1, purged.CacheGeneration)
+
+	require.Nil(t, c.DeleteEndpoint(ctx, endpoint.ID))
+	_, err = c.GetEndpoint(ctx, endpoint.ID)
+	require.True(t, errors.Is(err, ErrNotFound))
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	require.Contains(t, apiErr.Message, endpoint.ID.String())
+	require.Equal(t, "not_found", apiErr.Code)
+
+	_, err = c.CreateEndpoint(ctx, CreateEndpointParams{Name: "my-endpoint", Runtime: "ruby"})
+	require.True(t, errors.Is(err, ErrBadRequest))
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, "invalid_request", apiErr.Code)
+	require.Equal(t, []FieldError{{Field: "body.runtime", Message: "must be one of [go tinygo rust wasi js python]"}}, apiErr.Details)
+}
+
+func TestDeployments(t *testing.T) {
+	c, _ := createClient(t)
+	ctx := context.Background()
+	endpoint, err := c.CreateEndpoint(ctx, CreateEndpointParams{Name: "my-endpoint", Runtime: "go"})
+	require.Nil(t, err)
+
+	deploy, err := c.CreateDeployment(ctx, endpoint.ID, bytes.NewReader([]byte("a")), CreateDeploymentParams{})
+	require.Nil(t, err)
+	require.Equal(t, endpoint.ID, deploy.EndpointID)
+	require.False(t, deploy.Published)
+
+	smokeTest, err := ParseSmokeTest("/health")
+	require.Nil(t, err)
+	_, err = c.CreateDeployment(ctx, endpoint.ID, bytes.NewReader([]byte("a")), CreateDeploymentParams{
+		SmokeTests: []SmokeTest{smokeTest},
+	})
+	require.True(t, errors.Is(err, ErrBadRequest))
+
+	// Assets are sent together with the blob and validated by the API.
+	_, err = c.CreateDeployment(ctx, endpoint.ID, bytes.NewReader([]byte("a")), CreateDeploymentParams{
+		Assets: []byte("not an archive"),
+	})
+	require.True(t, errors.Is(err, ErrBadRequest))
+
+	redeploy, err := c.CreateEnvironmentDeployment(ctx, deploy.ID, CreateEnvironmentDeploymentParams{
+		Environment: map[string]string{"FOO": "bar"},
+	})
+	require.Nil(t, err)
+	require.Equal(t, deploy.Hash, redeploy.Hash)
+
+	resp, err := c.Publish(ctx, deploy.ID)
+	require.Nil(t, err)
+	require.Equal(t, deploy.ID, resp.DeploymentID)
+	endpoint, err = c.GetEndpoint(ctx, endpoint.ID)
+	require.Nil(t, err)
+	require.Equal(t, deploy.ID, endpoint.ActiveDeploymentID)
+
+	_, err = c.Publish(ctx, deploy.ID)
+	require.True(t, errors.Is(err, ErrBadRequest))
+}
+
+func TestMetricsAndLogs(t *testing.T) {
+	c, store := createClient(t)
+	ctx := context.Background()
+	endpoint, err := c.CreateEndpoint(ctx, CreateEndpointParams{Name: "my-endpoint", Runtime: "go"})
+	require.Nil(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.Nil(t, store.CreateRuntimeLog(&types.RuntimeLog{
+			ID:         uuid.New(),
+			EndpointID: endpoint.ID,
+			Data:       "hello",
+			CreatedAT:  time.Now(),
+		}))
+	}
+	require.Nil(t, store.CreateRequestMetric(&types.RequestMetric{
+		ID:         uuid.New(),
+		EndpointID: endpoint.ID,
+		RequestURL: "/",
+		StatusCode: http.StatusOK,
+		CreatedAT:  time.Now(),
+	}))
+
+	logs, err := c.GetLogs(ctx, endpoint.ID, 2)
+	require.Nil(t, err)
+	require.Len(t, logs, 2)
+	metrics, err := c.GetMetrics(ctx, endpoint.ID)
+	require.Nil(t, err)
+	require.Len(t, metrics, 1)
+	require.Equal(t, http.StatusOK, metrics[0].StatusCode)
+}
+
+func TestKVAndSchedules(t *testing.T) {
+	c, _ := createClient(t)
+	ctx := context.Background()
+	endpoint, err := c.CreateEndpoint(ctx, CreateEndpointParams{Name: "my-endpoint", Runtime: "go"})
+	require.Nil(t, err)
+
+	_, err = c.PutKV(ctx, endpoint.ID, "users/a b", PutKVParams{Value: []byte("bob")})
+	require.Nil(t, err)
+	entry, err := c.GetKV(ctx, endpoint.ID, "users/a b")
+	require.Nil(t, err)
+	require.Equal(t, []byte("bob"), entry.Value)
+	entries, err := c.ListKV(ctx, endpoint.ID, ListKVParams{Prefix: "users/"})
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+	require.Nil(t, c.DeleteKV(ctx, endpoint.ID, "users/a b"))
+	_, err = c.GetKV(ctx, endpoint.ID, "users/a b")
+	require.True(t, errors.Is(err, ErrNotFound))
+
+	schedule, err := c.CreateSchedule(ctx, endpoint.ID, CreateScheduleParams{Cron: "@hourly"})
+	require.Nil(t, err)
+	schedules, err := c.GetSchedules(ctx, endpoint.ID)
+	require.Nil(t, err)
+	require.Len(t, schedules, 1)
+	runs, err := c.GetScheduleRuns(ctx, schedule.ID)
+	require.Nil(t, err)
+	require.Empty(t, runs)
+	require.Nil(t, c.DeleteSchedule(ctx, schedule.ID))
+
+	invocations, err := c.GetInvocations(ctx, endpoint.ID, "dead")
+	require.Nil(t, err)
+	require.Empty(t, invocations)
+}
+
+func TestToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"unauthorized"}`))
+			return
+		}
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	c := New(NewConfig().WithURL(server.URL))
+	err := c.Status(context.Background())
+	require.True(t, errors.Is(err, ErrUnauthorized))
+	require.Equal(t, "raptor: api responded with status 401: unauthorized", err.Error())
+
+	c = New(NewConfig().WithURL(server.URL).WithToken("secret"))
+	require.Nil(t, c.Status(context.Background()))
+}
+
+func TestRetries(t *testing.T) {
+	var (
+		requests atomic.Int32
+		status   atomic.Int32
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every request fails until the third one.
+		if requests.Add(1) < 3 {
+			w.WriteHeader(int(status.Load()))
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`[]`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	c := New(NewConfig().WithURL(server.URL).WithRetryBackoff(time.Millisecond))
+	ctx := context.Background()
+
+	status.Store(http.StatusServiceUnavailable)
+	_, err := c.ListEndpoints(ctx)
+	require.Nil(t, err)
+	require.Equal(t, int32(3), requests.Load())
+
+	// Requests that are not idempotent are not retried after a 5xx.
+	requests.Store(0)
+	_, err = c.RetryInvocation(ctx, uuid.New())
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	require.Equal(t, int32(1), requests.Load())
+
+	// But they are when the server refused them.
+	requests.Store(0)
+	status.Store(http.StatusTooManyRequests)
+	_, err = c.RetryInvocation(ctx, uuid.New())
+	require.Nil(t, err)
+	require.Equal(t, int32(3), requests.Load())
+
+	requests.Store(0)
+	c = New(NewConfig().WithURL(server.URL).WithRetries(1).WithRetryBackoff(time.Millisecond))
+	_, err = c.ListEndpoints(ctx)
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	require.Equal(t, int32(2), requests.Load())
+
+	// Retries stop when the context is done.
+	requests.Store(0)
+	c = New(NewConfig().WithURL(server.URL).WithRetryBackoff(time.Hour))
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = c.ListEndpoints(ctx)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Equal(t, int32(1), requests.Load())
+}
+
+func createClient(t *testing.T) (*Client, *storage.MemoryStore) {
+	store := storage.NewMemoryStore()
+	server := httptest.NewServer(api.NewServer(store, store, storage.NewDefaultModCache()).Handler())
+	t.Cleanup(server.Close)
+	return New(NewConfig().WithURL(server.URL)), store
+}
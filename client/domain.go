@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+// CreateDomain attaches the custom domain to the endpoint. The domain is
+// served once the TXT record of the response holds its verification token
+// and it is verified with VerifyDomain.
+func (c *Client) CreateDomain(ctx context.Context, endpointID uuid.UUID, hostname string) (*DomainResponse, error) {
+	params := struct {
+		Hostname string `json:"hostname"`
+	}{hostname}
+	req, err := jsonRequest(http.MethodPost, fmt.Sprintf("/endpoint/%s/domain", endpointID), params)
+	if err != nil {
+		return nil, err
+	}
+	var domain DomainResponse
+	if err := c.do(ctx, req, &domain); err != nil {
+		return nil, err
+	}
+	return &domain, nil
+}
+
+func (c *Client) GetDomains(ctx context.Context, endpointID uuid.UUID) ([]DomainResponse, error) {
+	domains := []DomainResponse{}
+	req := request{method: http.MethodGet, path: fmt.Sprintf("/endpoint/%s/domain", endpointID)}
+	if err := c.do(ctx, req, &domains); err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+func (c *Client) VerifyDomain(ctx context.Context, hostname string) (*DomainResponse, error) {
+	var domain DomainResponse
+	req := request{method: http.MethodPost, path: fmt.Sprintf("/domain/%s/verify", url.PathEscape(hostname))}
+	if err := c.do(ctx, req, &domain); err != nil {
+		return nil, err
+	}
+	return &domain, nil
+}
+
+func (c *Client) DeleteDomain(ctx context.Context, hostname string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/domain/" + url.PathEscape(hostname)}, nil)
+}
+
+// PutCertificate stores the certificate the ingress serves the domain with.
+func (c *Client) PutCertificate(ctx context.Context, hostname string, params PutCertificateParams) (*Certificate, error) {
+	req, err := jsonRequest(http.MethodPut, fmt.Sprintf("/domain/%s/certificate", url.PathEscape(hostname)), params)
+	if err != nil {
+		return nil, err
+	}
+	var cert Certificate
+	if err := c.do(ctx, req, &cert); err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
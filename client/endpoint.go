@@ -0,0 +1,197 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+func (c *Client) CreateEndpoint(ctx context.Context, params CreateEndpointParams) (*Endpoint, error) {
+	req, err := jsonRequest(http.MethodPost, "/endpoint", params)
+	if err != nil {
+		return nil, err
+	}
+	var endpoint Endpoint
+	if err := c.do(ctx, req, &endpoint); err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func (c *Client) GetEndpoint(ctx context.Context, id uuid.UUID) (*Endpoint, error) {
+	var endpoint Endpoint
+	req := request{method: http.MethodGet, path: "/endpoint/" + id.String()}
+	if err := c.do(ctx, req, &endpoint); err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func (c *Client) ListEndpoints(ctx context.Context) ([]Endpoint, error) {
+	endpoints := []Endpoint{}
+	req := request{method: http.MethodGet, path: "/endpoint"}
+	if err := c.do(ctx, req, &endpoints); err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+func (c *Client) UpdateEndpoint(ctx context.Context, id uuid.UUID, params UpdateEndpointParams) (*Endpoint, error) {
+	req, err := jsonRequest(http.MethodPut, "/endpoint/"+id.String(), params)
+	if err != nil {
+		return nil, err
+	}
+	var endpoint Endpoint
+	if err := c.do(ctx, req, &endpoint); err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+// DeleteEndpoint deletes the endpoint together with its deployments, domains,
+// key-value store, schedules, invocations, metrics and logs.
+func (c *Client) DeleteEndpoint(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/endpoint/" + id.String()}, nil)
+}
+
+// PurgeCache purges the responses the ingress cached for the endpoint.
+func (c *Client) PurgeCache(ctx context.Context, id uuid.UUID) (*Endpoint, error) {
+	var endpoint Endpoint
+	req := request{method: http.MethodPost, path: "/endpoint/" + id.String() + "/purge"}
+	if err := c.do(ctx, req, &endpoint); err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+// SetEnvironment merges the variables into the environment of the endpoint.
+func (c *Client) SetEnvironment(ctx context.Context, id uuid.UUID, env map[string]string) (*Endpoint, error) {
+	return c.UpdateEndpoint(ctx, id, UpdateEndpointParams{Environment: env})
+}
+
+// UnsetEnvironment removes the variables from the environment of the endpoint.
+func (c *Client) UnsetEnvironment(ctx context.Context, id uuid.UUID, keys ...string) (*Endpoint, error) {
+	return c.UpdateEndpoint(ctx, id, UpdateEndpointParams{UnsetEnvironment: keys})
+}
+
+// ReplaceEnvironment replaces the whole environment of the endpoint.
+func (c *Client) ReplaceEnvironment(ctx context.Context, id uuid.UUID, env map[string]string) (*Endpoint, error) {
+	if env == nil {
+		env = map[string]string{}
+	}
+	return c.UpdateEndpoint(ctx, id, UpdateEndpointParams{Environment: env, ReplaceEnvironment: true})
+}
+
+// CreateDeployment deploys the blob to the endpoint, a WASM module or a
+// script for the js runtime, with the static assets of params if any. The
+// deployment is published when params.Publish is set and all its smoke tests
+// pass.
+func (c *Client) CreateDeployment(ctx context.Context, endpointID uuid.UUID, blob io.Reader, params CreateDeploymentParams) (*CreateDeploymentResponse, error) {
+	b, err := io.ReadAll(blob)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	if params.Publish {
+		query.Set("publish", "true")
+	}
+	for _, t := range params.SmokeTests {
+		query.Add("smoke_test", t.String())
+	}
+	req := request{
+		method:      http.MethodPost,
+		path:        fmt.Sprintf("/endpoint/%s/deployment", endpointID),
+		query:       query,
+		contentType: "application/octet-stream",
+		body:        b,
+	}
+	// Deployments with assets are a multipart form of the blob and the
+	// assets.
+	if len(params.Assets) > 0 {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		for _, part := range []struct {
+			name string
+			b    []byte
+		}{{"blob", b}, {"assets", params.Assets}} {
+			w, err := mw.CreateFormFile(part.name, part.name)
+			if err != nil {
+				return nil, err
+			}
+			w.Write(part.b)
+		}
+		if err := mw.Close(); err != nil {
+			return nil, err
+		}
+		req.contentType = mw.FormDataContentType()
+		req.body = buf.Bytes()
+	}
+	var resp CreateDeploymentResponse
+	if err := c.do(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CreateEnvironmentDeployment redeploys the blob of a deployment with the
+// given environment.
+func (c *Client) CreateEnvironmentDeployment(ctx context.Context, deployID uuid.UUID, params CreateEnvironmentDeploymentParams) (*Deployment, error) {
+	req, err := jsonRequest(http.MethodPost, fmt.Sprintf("/deployment/%s/environment", deployID), params)
+	if err != nil {
+		return nil, err
+	}
+	var deploy Deployment
+	if err := c.do(ctx, req, &deploy); err != nil {
+		return nil, err
+	}
+	return &deploy, nil
+}
+
+// Publish makes the deployment the LIVE deployment of its endpoint.
+func (c *Client) Publish(ctx context.Context, deployID uuid.UUID) (*PublishResponse, error) {
+	params := struct {
+		DeploymentID uuid.UUID `json:"deployment_id"`
+	}{deployID}
+	req, err := jsonRequest(http.MethodPost, "/publish", params)
+	if err != nil {
+		return nil, err
+	}
+	var resp PublishResponse
+	if err := c.do(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetMetrics returns the metrics of the latest requests on LIVE of the
+// endpoint, newest first.
+func (c *Client) GetMetrics(ctx context.Context, endpointID uuid.UUID) ([]RequestMetric, error) {
+	metrics := []RequestMetric{}
+	req := request{method: http.MethodGet, path: fmt.Sprintf("/endpoint/%s/metrics", endpointID)}
+	if err := c.do(ctx, req, &metrics); err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+// GetLogs returns up to limit of the latest runtime logs of the endpoint,
+// newest first, or the API default when limit is 0.
+func (c *Client) GetLogs(ctx context.Context, endpointID uuid.UUID, limit int) ([]RuntimeLog, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	logs := []RuntimeLog{}
+	req := request{method: http.MethodGet, path: fmt.Sprintf("/endpoint/%s/logs", endpointID), query: query}
+	if err := c.do(ctx, req, &logs); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Errors that an *Error matches with errors.Is, by its status code.
+var (
+	ErrBadRequest = errors.New("bad request")
+	// ErrUnauthorized is returned when the API token is missing or wrong.
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	// ErrVersionConflict is returned when an endpoint was updated at a
+	// version that is no longer its current version.
+	ErrVersionConflict = errors.New("version conflict")
+	ErrUnprocessable   = errors.New("unprocessable")
+)
+
+// Error is an error the API responded with.
+type Error struct {
+	StatusCode int
+	// Code is the machine-readable code of the error, like not_found or
+	// invalid_request.
+	Code string
+	// Message is the reason the API gave for the error, if any.
+	Message string
+	// Details holds the fields of the request that failed validation, for
+	// the invalid_request code.
+	Details []FieldError
+	// retryAfter is the wait the API asked for before a retry.
+	retryAfter time.Duration
+}
+
+// FieldError is a field of a request that is invalid, like body.name.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if len(e.Message) == 0 {
+		return fmt.Sprintf("raptor: api responded with status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("raptor: api responded with status %d: %s", e.StatusCode, e.Message)
+}
+
+// Is reports whether the status code of the error is the status of target.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrVersionConflict:
+		return e.StatusCode == http.StatusPreconditionFailed
+	case ErrUnprocessable:
+		return e.StatusCode == http.StatusUnprocessableEntity
+	}
+	return false
+}
+
+// readError returns the error of a response with the reason the API gave in
+// its error response.
+func readError(resp *http.Response) error {
+	var errResp struct {
+		Error   string       `json:"error"`
+		Code    string       `json:"code"`
+		Details []FieldError `json:"details"`
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err := json.Unmarshal(b, &errResp); err != nil {
+		errResp.Error, errResp.Code, errResp.Details = "", "", nil
+	}
+	apiErr := &Error{
+		StatusCode: resp.StatusCode,
+		Code:       errResp.Code,
+		Message:    errResp.Error,
+		Details:    errResp.Details,
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiErr.retryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+func (c *Client) GetInvocation(ctx context.Context, invocationID uuid.UUID) (*Invocation, error) {
+	var invocation Invocation
+	req := request{method: http.MethodGet, path: "/invocation/" + invocationID.String()}
+	if err := c.do(ctx, req, &invocation); err != nil {
+		return nil, err
+	}
+	return &invocation, nil
+}
+
+// GetInvocations returns the latest invocations of the endpoint with the
+// given status (queued, running, succeeded or dead), or of any status if
+// empty.
+func (c *Client) GetInvocations(ctx context.Context, endpointID uuid.UUID, status string) ([]Invocation, error) {
+	query := url.Values{}
+	if len(status) > 0 {
+		query.Set("status", status)
+	}
+	invocations := []Invocation{}
+	req := request{method: http.MethodGet, path: fmt.Sprintf("/endpoint/%s/invocation", endpointID), query: query}
+	if err := c.do(ctx, req, &invocations); err != nil {
+		return nil, err
+	}
+	return invocations, nil
+}
+
+// RetryInvocation puts a dead invocation back in the queue.
+func (c *Client) RetryInvocation(ctx context.Context, invocationID uuid.UUID) (*Invocation, error) {
+	var invocation Invocation
+	req := request{method: http.MethodPost, path: fmt.Sprintf("/invocation/%s/retry", invocationID)}
+	if err := c.do(ctx, req, &invocation); err != nil {
+		return nil, err
+	}
+	return &invocation, nil
+}
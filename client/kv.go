@@ -0,0 +1,62 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+func (c *Client) ListKV(ctx context.Context, endpointID uuid.UUID, params ListKVParams) ([]KVEntry, error) {
+	query := url.Values{}
+	if len(params.Prefix) > 0 {
+		query.Set("prefix", params.Prefix)
+	}
+	if params.Limit > 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	entries := []KVEntry{}
+	req := request{method: http.MethodGet, path: fmt.Sprintf("/endpoint/%s/kv", endpointID), query: query}
+	if err := c.do(ctx, req, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (c *Client) GetKV(ctx context.Context, endpointID uuid.UUID, key string) (*KVEntry, error) {
+	var entry KVEntry
+	req := request{method: http.MethodGet, path: kvPath(endpointID, key)}
+	if err := c.do(ctx, req, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (c *Client) PutKV(ctx context.Context, endpointID uuid.UUID, key string, params PutKVParams) (*KVEntry, error) {
+	req, err := jsonRequest(http.MethodPut, kvPath(endpointID, key), params)
+	if err != nil {
+		return nil, err
+	}
+	var entry KVEntry
+	if err := c.do(ctx, req, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (c *Client) DeleteKV(ctx context.Context, endpointID uuid.UUID, key string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: kvPath(endpointID, key)}, nil)
+}
+
+// kvPath returns the path of a key, which may contain slashes.
+func kvPath(endpointID uuid.UUID, key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return fmt.Sprintf("/endpoint/%s/kv/%s", endpointID, strings.Join(segments, "/"))
+}
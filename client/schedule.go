@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// CreateSchedule invokes the endpoint on the cron schedule.
+func (c *Client) CreateSchedule(ctx context.Context, endpointID uuid.UUID, params CreateScheduleParams) (*Schedule, error) {
+	req, err := jsonRequest(http.MethodPost, fmt.Sprintf("/endpoint/%s/schedule", endpointID), params)
+	if err != nil {
+		return nil, err
+	}
+	var schedule Schedule
+	if err := c.do(ctx, req, &schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (c *Client) GetSchedules(ctx context.Context, endpointID uuid.UUID) ([]Schedule, error) {
+	schedules := []Schedule{}
+	req := request{method: http.MethodGet, path: fmt.Sprintf("/endpoint/%s/schedule", endpointID)}
+	if err := c.do(ctx, req, &schedules); err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// GetScheduleRuns returns the latest runs of the schedule, newest first.
+func (c *Client) GetScheduleRuns(ctx context.Context, scheduleID uuid.UUID) ([]ScheduleRun, error) {
+	runs := []ScheduleRun{}
+	req := request{method: http.MethodGet, path: fmt.Sprintf("/schedule/%s/runs", scheduleID)}
+	if err := c.do(ctx, req, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+func (c *Client) DeleteSchedule(ctx context.Context, scheduleID uuid.UUID) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/schedule/" + scheduleID.String()}, nil)
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+// The resources of the API.
+type (
+	Endpoint          = types.Endpoint
+	DeploymentHistory = types.DeploymentHistory
+	Deployment        = types.Deployment
+	Domain            = types.Domain
+	Certificate       = types.Certificate
+	KVEntry           = types.KVEntry
+	Schedule          = types.Schedule
+	ScheduleRun       = types.ScheduleRun
+	Invocation        = types.Invocation
+	RequestMetric     = types.RequestMetric
+	RuntimeLog        = types.RuntimeLog
+	SmokeTest         = types.SmokeTest
+	SmokeTestResult   = types.SmokeTestResult
+)
+
+// ParseSmokeTest parses a smoke test in the form of "[METHOD] PATH [STATUS]",
+// like "/health" or "POST /users 201".
+func ParseSmokeTest(s string) (SmokeTest, error) {
+	return types.ParseSmokeTest(s)
+}
+
+// CreateEndpointParams holds the fields of a new endpoint.
+type CreateEndpointParams struct {
+	Name string `json:"name"`
+	// Slug used to reach the endpoint by name, derived from the name when
+	// empty.
+	Slug string `json:"slug,omitempty"`
+	// Runtime on which the code will be invoked (go, tinygo, rust, wasi, js
+	// or python).
+	Runtime     string            `json:"runtime"`
+	Environment map[string]string `json:"environment,omitempty"`
+	// When true, each deployment stores a snapshot of the environment and
+	// runs with it.
+	SnapshotEnvironment bool `json:"snapshot_environment"`
+	// Hosts the endpoint may send outbound HTTP requests to.
+	AllowedHosts []string `json:"allowed_hosts,omitempty"`
+}
+
+// UpdateEndpointParams holds the changes to an endpoint. Fields that are
+// not set are left untouched.
+type UpdateEndpointParams struct {
+	// Environment variables that are merged into the current environment.
+	Environment map[string]string `json:"environment,omitempty"`
+	// Keys of the environment variables that are removed.
+	UnsetEnvironment []string `json:"unset_environment,omitempty"`
+	// ReplaceEnvironment replaces the whole environment with Environment.
+	ReplaceEnvironment  bool  `json:"replace_environment,omitempty"`
+	SnapshotEnvironment *bool `json:"snapshot_environment,omitempty"`
+	// AllowedHosts replaces the hosts the endpoint may send outbound
+	// requests to when not nil.
+	AllowedHosts []string `json:"allowed_hosts"`
+	// Version is the version of the endpoint the update is based on. When not
+	// zero the update fails with ErrVersionConflict if the endpoint was
+	// modified in the meantime.
+	Version int `json:"version,omitempty"`
+}
+
+// CreateDeploymentParams holds the options of a new deployment.
+type CreateDeploymentParams struct {
+	// When true, the deployment is published once it passes its smoke tests.
+	Publish bool
+	// Requests that are made against the preview of the deployment before it
+	// is published.
+	SmokeTests []SmokeTest
+	// Assets is a tar, tar.gz or zip archive of static assets that are
+	// served together with the deployment.
+	Assets []byte
+}
+
+// CreateDeploymentResponse is the deployment that was created and whether it
+// was published.
+type CreateDeploymentResponse struct {
+	*Deployment
+	Published bool `json:"published"`
+	// The LIVE url of the endpoint when the deployment was published.
+	URL        string            `json:"url,omitempty"`
+	SmokeTests []SmokeTestResult `json:"smoke_tests,omitempty"`
+}
+
+// CreateEnvironmentDeploymentParams holds the environment of a redeployment.
+// The current environment of the endpoint is used when it is nil.
+type CreateEnvironmentDeploymentParams struct {
+	Environment map[string]string `json:"environment"`
+}
+
+// PublishResponse is the deployment that went LIVE.
+type PublishResponse struct {
+	DeploymentID uuid.UUID `json:"deployment_id"`
+	URL          string    `json:"url"`
+}
+
+// DomainResponse holds a custom domain together with the DNS TXT record
+// that needs to hold the verification token.
+type DomainResponse struct {
+	*Domain
+	VerificationRecord string `json:"verification_record"`
+}
+
+// PutCertificateParams holds the PEM encoded certificate and private key of a
+// custom domain.
+type PutCertificateParams struct {
+	Certificate string `json:"certificate"`
+	PrivateKey  string `json:"private_key"`
+}
+
+// PutKVParams holds the value of a key in the key-value store of an endpoint.
+type PutKVParams struct {
+	Value []byte `json:"value"`
+	// Number of seconds after which the key expires. Zero never expires.
+	TTL int `json:"ttl,omitempty"`
+}
+
+// ListKVParams filters the keys of a key-value store.
+type ListKVParams struct {
+	Prefix string
+	// The maximum number of entries, the API default when zero.
+	Limit int
+}
+
+// CreateScheduleParams holds the fields of a new cron schedule.
+type CreateScheduleParams struct {
+	// Cron expression with 5 fields or @hourly, @daily, @weekly, @monthly
+	// and @yearly, evaluated in UTC.
+	Cron string `json:"cron"`
+	// The method of the scheduled request, POST when empty.
+	Method string `json:"method,omitempty"`
+	// The path of the scheduled request, / when empty.
+	Path string `json:"path,omitempty"`
+}
@@ -1,17 +1,22 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	_ "embed"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/anthdm/raptor/internal/api"
+	"github.com/anthdm/raptor/internal/assets"
+	"github.com/anthdm/raptor/internal/build"
 	"github.com/anthdm/raptor/internal/client"
 	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/manifest"
+	"github.com/anthdm/raptor/internal/output"
 	"github.com/anthdm/raptor/internal/types"
 	"github.com/anthdm/raptor/internal/version"
 	"github.com/google/uuid"
@@ -21,14 +26,25 @@ func printUsage() {
 	fmt.Printf(`
 Raptor cli v%s
 
-Usage: raptor COMMAND
+Usage: raptor [--output json|table|yaml] COMMAND
 
 Commands:
-  endpoint			Create a new endpoint
+  endpoint			Create, inspect and delete endpoints
+  init				Create a raptor.toml manifest for the project in the current directory
+  apply				Create or update the endpoint declared in raptor.toml
   publish			Publish a deployment to an endpoint
-  deploy			Create a new deployment
+  deploy			Create a new deployment, of the endpoint in raptor.toml when no --endpoint is given
+  env				Manage the environment variables of an endpoint
+  domain			Manage the custom domains of an endpoint
+  kv				Inspect and seed the key-value store of an endpoint
+  schedule			Invoke an endpoint on a cron schedule
+  invocation			Inspect and retry asynchronous invocations
+  serve				Serve a WASM file locally: raptor serve --file app.wasm [--runtime go|tinygo|rust|wasi|js|python] [--env K=V]
   help				Show usage
 
+The output format of a command is set with --output (or -o), which can also be
+given after the command: raptor endpoint list -o table
+
 `, version.Version)
 	os.Exit(0)
 }
@@ -50,6 +66,10 @@ func main() {
 	var configFile string
 	flagset.StringVar(&configFile, "config", "config.toml", "The location of your raptor config file")
 
+	var outputName string
+	flagset.StringVar(&outputName, "output", string(output.JSON), "The output format (json, table or yaml)")
+	flagset.StringVar(&outputName, "o", string(output.JSON), "The output format (json, table or yaml)")
+
 	flagset.Usage = printUsage
 	flagset.Parse(os.Args[1:])
 
@@ -57,7 +77,12 @@ func main() {
 		printErrorAndExit(err)
 	}
 
-	args := flagset.Args()
+	args, outputName := outputFlag(flagset.Args(), outputName)
+	format, err := output.ParseFormat(outputName)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	outputFormat = format
 	if len(args) == 0 {
 		printUsage()
 	}
@@ -74,6 +99,20 @@ func main() {
 		command.handleEndpoint(args[1:])
 	case "deploy":
 		command.handleDeploy(args[1:])
+	case "init":
+		command.handleInit(args[1:])
+	case "apply":
+		command.handleApply(args[1:])
+	case "env":
+		command.handleEnv(args[1:])
+	case "domain":
+		command.handleDomain(args[1:])
+	case "kv":
+		command.handleKV(args[1:])
+	case "schedule":
+		command.handleSchedule(args[1:])
+	case "invocation":
+		command.handleInvocation(args[1:])
 	case "serve":
 		if len(args) < 2 {
 			printUsage()
@@ -90,6 +129,31 @@ type command struct {
 	client *client.Client
 }
 
+// outputFormat is the format in which printOutput writes the results of a
+// command.
+var outputFormat = output.JSON
+
+// outputFlag removes the --output flag from the arguments of a command, so
+// it can be given after the command as well. It returns the remaining
+// arguments and the output format, which is value if the flag is not given.
+func outputFlag(args []string, value string) ([]string, string) {
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, val, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || (name != "output" && name != "o") {
+			rest = append(rest, arg)
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			i++
+			val = args[i]
+		}
+		value = val
+	}
+	return rest, value
+}
+
 func (c command) handlePublish(args []string) {
 	flagset := flag.NewFlagSet("endpoint", flag.ExitOnError)
 
@@ -107,30 +171,123 @@ func (c command) handlePublish(args []string) {
 	if err != nil {
 		printErrorAndExit(err)
 	}
-	b, err := json.MarshalIndent(resp, "", "    ")
+	printOutput(resp)
+}
+
+func printEndpointUsage() {
+	fmt.Printf(`
+Usage: raptor endpoint COMMAND [ARGS]
+
+Commands:
+  create			Create an endpoint: raptor endpoint create --name <name> --runtime go|tinygo|rust|wasi|js|python [--env FOO=bar]
+  get				Show an endpoint: raptor endpoint get --endpoint <id>
+  list				List the endpoints: raptor endpoint list
+  delete			Delete an endpoint and all its data: raptor endpoint delete --endpoint <id> [--yes]
+  metrics			Show the latest requests on LIVE: raptor endpoint metrics --endpoint <id>
+  logs				Show the latest logs on LIVE: raptor endpoint logs --endpoint <id> [--limit 20]
+  purge				Purge the cached responses of LIVE: raptor endpoint purge --endpoint <id>
+
+`)
+	os.Exit(0)
+}
+
+func (c command) handleEndpoint(args []string) {
+	if len(args) == 0 {
+		printEndpointUsage()
+	}
+	// Flags without a command create an endpoint, as raptor endpoint did
+	// before it had commands.
+	if strings.HasPrefix(args[0], "-") {
+		c.handleCreateEndpoint(args)
+		return
+	}
+	if args[0] == "create" {
+		c.handleCreateEndpoint(args[1:])
+		return
+	}
+	flagset := flag.NewFlagSet("endpoint", flag.ExitOnError)
+
+	var endpointID string
+	flagset.StringVar(&endpointID, "endpoint", "", "The id of the endpoint")
+	var limit int
+	flagset.IntVar(&limit, "limit", 0, "The number of logs to show")
+	var yes bool
+	flagset.BoolVar(&yes, "yes", false, "Delete the endpoint without asking for confirmation")
+	_ = flagset.Parse(args[1:])
+
+	if args[0] == "list" {
+		endpoints, err := c.client.ListEndpoints()
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		printOutput(endpoints)
+		return
+	}
+	id, err := uuid.Parse(endpointID)
 	if err != nil {
-		printErrorAndExit(err)
+		printErrorAndExit(fmt.Errorf("invalid endpoint id given: %s", endpointID))
+	}
+	switch args[0] {
+	case "get":
+		endpoint, err := c.client.GetEndpoint(id)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		printOutput(endpoint)
+	case "delete":
+		if !yes && !confirm(fmt.Sprintf("delete endpoint %s with all its deployments, domains, schedules and data?", id)) {
+			fmt.Println("endpoint not deleted")
+			return
+		}
+		if err := c.client.DeleteEndpoint(id); err != nil {
+			printErrorAndExit(err)
+		}
+		fmt.Printf("endpoint %s deleted\n", id)
+	case "metrics":
+		metrics, err := c.client.GetMetrics(id)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		printOutput(metrics)
+	case "logs":
+		logs, err := c.client.GetLogs(id, limit)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		printOutput(logs)
+	case "purge":
+		if _, err := c.client.PurgeCache(id); err != nil {
+			printErrorAndExit(err)
+		}
+		fmt.Printf("cached responses of endpoint %s purged\n", id)
+	default:
+		printEndpointUsage()
 	}
-	fmt.Println(string(b))
 }
 
-func (c command) handleEndpoint(args []string) {
+func (c command) handleCreateEndpoint(args []string) {
 	flagset := flag.NewFlagSet("endpoint", flag.ExitOnError)
 
 	var name string
 	flagset.StringVar(&name, "name", "", "The name of your endpoint")
+	var slug string
+	flagset.StringVar(&slug, "slug", "", "The slug of your endpoint, derived from the name if not provided")
 	var runtime string
-	flagset.StringVar(&runtime, "runtime", "", "The runtime of your endpoint (go or js)")
+	flagset.StringVar(&runtime, "runtime", "", "The runtime of your endpoint ("+strings.Join(types.RuntimeNames(), ", ")+")")
 	var env stringList
 	flagset.Var(&env, "env", "Environment variables for this endpoint")
+	var snapshotEnv bool
+	flagset.BoolVar(&snapshotEnv, "snapshot-env", false, "Store the environment with each deployment so changes go LIVE on publish")
+	var allowHosts stringList
+	flagset.Var(&allowHosts, "allow-host", "A host the endpoint may send outbound HTTP requests to (repeatable)")
 	_ = flagset.Parse(args)
 
 	if len(runtime) == 0 {
-		fmt.Println("please provide a valid runtime [--runtime go, --runtime js]")
+		fmt.Printf("please provide a valid runtime [--runtime %s]\n", strings.Join(types.RuntimeNames(), "|"))
 		os.Exit(1)
 	}
 	if !types.ValidRuntime(runtime) {
-		fmt.Printf("invalid runtime %s, only go and js are currently supported\n", runtime)
+		fmt.Printf("invalid runtime %s, supported runtimes are %s\n", runtime, strings.Join(types.RuntimeNames(), ", "))
 		os.Exit(1)
 	}
 	if len(name) == 0 {
@@ -138,19 +295,18 @@ func (c command) handleEndpoint(args []string) {
 		os.Exit(1)
 	}
 	params := api.CreateEndpointParams{
-		Runtime:     runtime,
-		Name:        name,
-		Environment: makeEnvMap(env),
+		Runtime:             runtime,
+		Name:                name,
+		Slug:                slug,
+		Environment:         makeEnvMap(env),
+		SnapshotEnvironment: snapshotEnv,
+		AllowedHosts:        allowHosts,
 	}
 	endpoint, err := c.client.CreateEndpoint(params)
 	if err != nil {
 		printErrorAndExit(err)
 	}
-	b, err := json.MarshalIndent(endpoint, "", "    ")
-	if err != nil {
-		printErrorAndExit(err)
-	}
-	fmt.Println(string(b))
+	printOutput(endpoint)
 }
 
 func (c command) handleDeploy(args []string) {
@@ -160,31 +316,512 @@ func (c command) handleDeploy(args []string) {
 	flagset.StringVar(&endpointID, "endpoint", "", "The id of the endpoint to where you want to deploy")
 	var file string
 	flagset.StringVar(&file, "file", "", "The file location of your code that you want to deploy")
+	var dir string
+	flagset.StringVar(&dir, "dir", "", "The directory of your Go or JS sources that you want to build and deploy")
+	var from string
+	flagset.StringVar(&from, "from", "", "The id of a deployment to redeploy with the current environment of the endpoint")
+	var env stringList
+	flagset.Var(&env, "env", "The environment of the redeployment, used together with --from")
+	var manifestFile string
+	flagset.StringVar(&manifestFile, "manifest", manifest.FileName, "The manifest to deploy when no endpoint is given")
+	var publish bool
+	flagset.BoolVar(&publish, "publish", false, "Publish the deployment LIVE once it passes its smoke tests")
+	var smokeTests stringList
+	flagset.Var(&smokeTests, "smoke-test", "A request that has to pass on the preview before publishing: \"[METHOD] PATH [STATUS]\" (repeatable)")
+	var assetsDir string
+	flagset.StringVar(&assetsDir, "assets", "", "The directory of static assets that are served together with the deployment")
 	_ = flagset.Parse(args)
 
+	if len(from) > 0 {
+		c.handleEnvironmentDeploy(from, env)
+		return
+	}
+	params, err := makeCreateDeploymentParams(publish, smokeTests)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	if len(assetsDir) > 0 {
+		params.Assets, err = assets.Archive(assetsDir)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+	}
+	if len(endpointID) == 0 && len(file) == 0 && len(dir) == 0 {
+		c.handleManifestDeploy(manifestFile, params)
+		return
+	}
+
 	id, err := uuid.Parse(endpointID)
 	if err != nil {
 		printErrorAndExit(fmt.Errorf("invalid endpoint id given: %s", args[0]))
 	}
-	b, err := os.ReadFile(file)
+	var b []byte
+	if len(dir) > 0 {
+		b, err = c.buildDir(id, dir)
+	} else {
+		b, err = os.ReadFile(file)
+	}
 	if err != nil {
 		printErrorAndExit(err)
 	}
-	deploy, err := c.client.CreateDeployment(id, bytes.NewReader(b), api.CreateDeploymentParams{})
+	deploy, err := c.client.CreateDeployment(id, bytes.NewReader(b), params)
 	if err != nil {
 		printErrorAndExit(err)
 	}
-	b, err = json.MarshalIndent(deploy, "", "    ")
+	printCreatedDeploy(deploy, params)
+}
+
+func makeCreateDeploymentParams(publish bool, smokeTests []string) (api.CreateDeploymentParams, error) {
+	params := api.CreateDeploymentParams{Publish: publish}
+	if len(smokeTests) > 0 && !publish {
+		return params, fmt.Errorf("smoke tests can only be used together with --publish")
+	}
+	for _, value := range smokeTests {
+		t, err := types.ParseSmokeTest(value)
+		if err != nil {
+			return params, err
+		}
+		params.SmokeTests = append(params.SmokeTests, t)
+	}
+	return params, nil
+}
+
+// printCreatedDeploy prints the deployment and, when it had to be published,
+// the results of its smoke tests. It exits when the deployment was not
+// published.
+func printCreatedDeploy(resp *api.CreateDeploymentResponse, params api.CreateDeploymentParams) {
+	printDeploy(resp.Deployment)
+	if !params.Publish {
+		return
+	}
+	fmt.Println()
+	for _, result := range resp.SmokeTests {
+		if result.Passed {
+			fmt.Printf("ok   %s (%d)\n", result.SmokeTest, result.StatusCode)
+		} else {
+			fmt.Printf("FAIL %s: %s\n", result.SmokeTest, result.Error)
+		}
+	}
+	if !resp.Published {
+		printErrorAndExit(fmt.Errorf("deploy %s was not published because a smoke test failed", resp.ID))
+	}
+	fmt.Printf("deploy %s published LIVE: %s\n", resp.ID, resp.URL)
+}
+
+// buildDir builds the sources in the given directory for the runtime of the
+// endpoint.
+func (c command) buildDir(endpointID uuid.UUID, dir string) ([]byte, error) {
+	endpoint, err := c.client.GetEndpoint(endpointID)
+	if err != nil {
+		return nil, err
+	}
+	runtime, err := build.Detect(dir)
+	if err != nil {
+		return nil, err
+	}
+	// Go sources are built with TinyGo for endpoints on the tinygo runtime.
+	if runtime == "go" && endpoint.Runtime == "tinygo" {
+		runtime = endpoint.Runtime
+	}
+	if runtime != endpoint.Runtime {
+		return nil, fmt.Errorf("%s contains %s sources but the endpoint runs %s", dir, runtime, endpoint.Runtime)
+	}
+	return buildSources(dir, runtime)
+}
+
+func buildSources(dir string, runtime string) ([]byte, error) {
+	fmt.Printf("building %s (%s)\n", dir, runtime)
+	b, err := build.Build(dir, runtime)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("built %s\n", build.FormatSize(len(b)))
+	return b, nil
+}
+
+func (c command) handleEnvironmentDeploy(from string, env []string) {
+	id, err := uuid.Parse(from)
+	if err != nil {
+		printErrorAndExit(fmt.Errorf("invalid deployment id given: %s", from))
+	}
+	params := api.CreateEnvironmentDeploymentParams{}
+	if len(env) > 0 {
+		params.Environment = makeEnvMap(env)
+	}
+	deploy, err := c.client.CreateEnvironmentDeployment(id, params)
 	if err != nil {
 		printErrorAndExit(err)
 	}
-	fmt.Println(string(b))
+	printDeploy(deploy)
+}
+
+func printDeploy(deploy *types.Deployment) {
+	printOutput(deploy)
 	fmt.Println()
 	fmt.Printf("deploy preview: %s/preview/%s\n", config.IngressUrl(), deploy.ID)
 }
 
-func (c command) handleServeEndpoint(args []string) {
-	fmt.Println("TODO")
+func printEnvUsage() {
+	fmt.Printf(`
+Usage: raptor env COMMAND --endpoint <id> [ARGS]
+
+Commands:
+  list				List the environment variables
+  set				Set variables: raptor env set --endpoint <id> FOO=bar NAME=bob
+  unset				Remove variables: raptor env unset --endpoint <id> FOO NAME
+  replace			Replace all variables: raptor env replace --endpoint <id> FOO=bar
+
+`)
+	os.Exit(0)
+}
+
+func (c command) handleEnv(args []string) {
+	if len(args) == 0 {
+		printEnvUsage()
+	}
+	flagset := flag.NewFlagSet("env", flag.ExitOnError)
+
+	var endpointID string
+	flagset.StringVar(&endpointID, "endpoint", "", "The id of the endpoint")
+	var version int
+	flagset.IntVar(&version, "version", 0, "Only apply the change when the endpoint is still at this version")
+	_ = flagset.Parse(args[1:])
+
+	id, err := uuid.Parse(endpointID)
+	if err != nil {
+		printErrorAndExit(fmt.Errorf("invalid endpoint id given: %s", endpointID))
+	}
+
+	params := api.UpdateEndpointParams{
+		Version: version,
+	}
+	switch args[0] {
+	case "list":
+		endpoint, err := c.client.GetEndpoint(id)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		printEnv(endpoint)
+		return
+	case "set":
+		params.Environment = makeEnvMap(flagset.Args())
+	case "unset":
+		params.UnsetEnvironment = flagset.Args()
+	case "replace":
+		params.Environment = makeEnvMap(flagset.Args())
+		params.ReplaceEnvironment = true
+	default:
+		printEnvUsage()
+	}
+	endpoint, err := c.client.UpdateEndpoint(id, params)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	printEnv(endpoint)
+}
+
+func printEnv(endpoint *types.Endpoint) {
+	keys := make([]string, 0, len(endpoint.Environment))
+	for key := range endpoint.Environment {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("%s=%s\n", key, endpoint.Environment[key])
+	}
+	fmt.Println()
+	fmt.Printf("endpoint version: %d\n", endpoint.Version)
+}
+
+func printDomainUsage() {
+	fmt.Printf(`
+Usage: raptor domain COMMAND [ARGS]
+
+Commands:
+  add				Attach a domain: raptor domain add --endpoint <id> --host app.example.com
+  list				List the domains: raptor domain list --endpoint <id>
+  verify			Verify the ownership of a domain: raptor domain verify --host app.example.com
+  remove			Remove a domain: raptor domain remove --host app.example.com
+
+`)
+	os.Exit(0)
+}
+
+func (c command) handleDomain(args []string) {
+	if len(args) == 0 {
+		printDomainUsage()
+	}
+	flagset := flag.NewFlagSet("domain", flag.ExitOnError)
+
+	var endpointID string
+	flagset.StringVar(&endpointID, "endpoint", "", "The id of the endpoint")
+	var host string
+	flagset.StringVar(&host, "host", "", "The hostname of the domain")
+	_ = flagset.Parse(args[1:])
+
+	switch args[0] {
+	case "add":
+		id, err := uuid.Parse(endpointID)
+		if err != nil {
+			printErrorAndExit(fmt.Errorf("invalid endpoint id given: %s", endpointID))
+		}
+		domain, err := c.client.CreateDomain(id, api.CreateDomainParams{Hostname: host})
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		printOutput(domain)
+		fmt.Println()
+		fmt.Printf("create a TXT record %s with the value %s and run:\n", domain.VerificationRecord, domain.VerificationToken)
+		fmt.Printf("raptor domain verify --host %s\n", domain.Hostname)
+	case "list":
+		id, err := uuid.Parse(endpointID)
+		if err != nil {
+			printErrorAndExit(fmt.Errorf("invalid endpoint id given: %s", endpointID))
+		}
+		domains, err := c.client.GetDomains(id)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		printOutput(domains)
+	case "verify":
+		domain, err := c.client.VerifyDomain(host)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		printOutput(domain)
+	case "remove":
+		if err := c.client.DeleteDomain(host); err != nil {
+			printErrorAndExit(err)
+		}
+		fmt.Printf("domain %s removed\n", host)
+	default:
+		printDomainUsage()
+	}
+}
+
+func printKVUsage() {
+	fmt.Printf(`
+Usage: raptor kv COMMAND [ARGS]
+
+Commands:
+  list				List the entries: raptor kv list --endpoint <id> [--prefix users/]
+  get				Print the value of a key: raptor kv get --endpoint <id> --key foo
+  put				Set a key: raptor kv put --endpoint <id> --key foo --value bar [--ttl 60]
+  delete			Delete a key: raptor kv delete --endpoint <id> --key foo
+
+`)
+	os.Exit(0)
+}
+
+func (c command) handleKV(args []string) {
+	if len(args) == 0 {
+		printKVUsage()
+	}
+	flagset := flag.NewFlagSet("kv", flag.ExitOnError)
+
+	var endpointID string
+	flagset.StringVar(&endpointID, "endpoint", "", "The id of the endpoint")
+	var key string
+	flagset.StringVar(&key, "key", "", "The key")
+	var value string
+	flagset.StringVar(&value, "value", "", "The value of the key")
+	var ttl int
+	flagset.IntVar(&ttl, "ttl", 0, "The number of seconds after which the key expires")
+	var prefix string
+	flagset.StringVar(&prefix, "prefix", "", "Only list the keys with this prefix")
+	_ = flagset.Parse(args[1:])
+
+	id, err := uuid.Parse(endpointID)
+	if err != nil {
+		printErrorAndExit(fmt.Errorf("invalid endpoint id given: %s", endpointID))
+	}
+	switch args[0] {
+	case "list":
+		entries, err := c.client.ListKV(id, prefix)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		for _, entry := range entries {
+			fmt.Printf("%s\t%s\n", entry.Key, entry.Value)
+		}
+	case "get":
+		entry, err := c.client.GetKV(id, key)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		fmt.Println(string(entry.Value))
+	case "put":
+		params := api.PutKVParams{Value: []byte(value), TTL: ttl}
+		if _, err := c.client.PutKV(id, key, params); err != nil {
+			printErrorAndExit(err)
+		}
+		fmt.Printf("key %s set\n", key)
+	case "delete":
+		if err := c.client.DeleteKV(id, key); err != nil {
+			printErrorAndExit(err)
+		}
+		fmt.Printf("key %s deleted\n", key)
+	default:
+		printKVUsage()
+	}
+}
+
+func printScheduleUsage() {
+	fmt.Printf(`
+Usage: raptor schedule COMMAND [ARGS]
+
+Commands:
+  add				Add a schedule: raptor schedule add --endpoint <id> --cron "*/5 * * * *" [--path /cleanup] [--method POST]
+  list				List the schedules: raptor schedule list --endpoint <id>
+  runs				Show the latest runs: raptor schedule runs --schedule <id>
+  remove			Remove a schedule: raptor schedule remove --schedule <id>
+
+`)
+	os.Exit(0)
+}
+
+func (c command) handleSchedule(args []string) {
+	if len(args) == 0 {
+		printScheduleUsage()
+	}
+	flagset := flag.NewFlagSet("schedule", flag.ExitOnError)
+
+	var endpointID string
+	flagset.StringVar(&endpointID, "endpoint", "", "The id of the endpoint")
+	var scheduleID string
+	flagset.StringVar(&scheduleID, "schedule", "", "The id of the schedule")
+	var cron string
+	flagset.StringVar(&cron, "cron", "", "The cron expression of the schedule, evaluated in UTC")
+	var method string
+	flagset.StringVar(&method, "method", "", "The method of the scheduled request (default POST)")
+	var path string
+	flagset.StringVar(&path, "path", "", "The path of the scheduled request (default /)")
+	_ = flagset.Parse(args[1:])
+
+	switch args[0] {
+	case "add":
+		id, err := uuid.Parse(endpointID)
+		if err != nil {
+			printErrorAndExit(fmt.Errorf("invalid endpoint id given: %s", endpointID))
+		}
+		params := api.CreateScheduleParams{Cron: cron, Method: method, Path: path}
+		schedule, err := c.client.CreateSchedule(id, params)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		printOutput(schedule)
+	case "list":
+		id, err := uuid.Parse(endpointID)
+		if err != nil {
+			printErrorAndExit(fmt.Errorf("invalid endpoint id given: %s", endpointID))
+		}
+		schedules, err := c.client.GetSchedules(id)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		printOutput(schedules)
+	case "runs":
+		id, err := uuid.Parse(scheduleID)
+		if err != nil {
+			printErrorAndExit(fmt.Errorf("invalid schedule id given: %s", scheduleID))
+		}
+		runs, err := c.client.GetScheduleRuns(id)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		printOutput(runs)
+	case "remove":
+		id, err := uuid.Parse(scheduleID)
+		if err != nil {
+			printErrorAndExit(fmt.Errorf("invalid schedule id given: %s", scheduleID))
+		}
+		if err := c.client.DeleteSchedule(id); err != nil {
+			printErrorAndExit(err)
+		}
+		fmt.Printf("schedule %s removed\n", id)
+	default:
+		printScheduleUsage()
+	}
+}
+
+func printInvocationUsage() {
+	fmt.Printf(`
+Usage: raptor invocation COMMAND [ARGS]
+
+Commands:
+  get				Show an invocation: raptor invocation get --invocation <id>
+  list				List the invocations: raptor invocation list --endpoint <id> [--status dead]
+  retry				Retry a dead invocation: raptor invocation retry --invocation <id>
+
+`)
+	os.Exit(0)
+}
+
+func (c command) handleInvocation(args []string) {
+	if len(args) == 0 {
+		printInvocationUsage()
+	}
+	flagset := flag.NewFlagSet("invocation", flag.ExitOnError)
+
+	var endpointID string
+	flagset.StringVar(&endpointID, "endpoint", "", "The id of the endpoint")
+	var invocationID string
+	flagset.StringVar(&invocationID, "invocation", "", "The id of the invocation")
+	var status string
+	flagset.StringVar(&status, "status", "", "Only list the invocations with this status (queued, running, succeeded or dead)")
+	_ = flagset.Parse(args[1:])
+
+	switch args[0] {
+	case "get":
+		id, err := uuid.Parse(invocationID)
+		if err != nil {
+			printErrorAndExit(fmt.Errorf("invalid invocation id given: %s", invocationID))
+		}
+		invocation, err := c.client.GetInvocation(id)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		printOutput(invocation)
+	case "list":
+		id, err := uuid.Parse(endpointID)
+		if err != nil {
+			printErrorAndExit(fmt.Errorf("invalid endpoint id given: %s", endpointID))
+		}
+		invocations, err := c.client.GetInvocations(id, status)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		printOutput(invocations)
+	case "retry":
+		id, err := uuid.Parse(invocationID)
+		if err != nil {
+			printErrorAndExit(fmt.Errorf("invalid invocation id given: %s", invocationID))
+		}
+		invocation, err := c.client.RetryInvocation(id)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		printOutput(invocation)
+	default:
+		printInvocationUsage()
+	}
+}
+
+func printOutput(v any) {
+	if err := output.Write(os.Stdout, outputFormat, v); err != nil {
+		printErrorAndExit(err)
+	}
+}
+
+// confirm asks the question and reports whether it was answered with yes.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
 }
 
 func makeEnvMap(list []string) map[string]string {
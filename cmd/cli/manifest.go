@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anthdm/raptor/internal/api"
+	"github.com/anthdm/raptor/internal/assets"
+	"github.com/anthdm/raptor/internal/build"
+	"github.com/anthdm/raptor/internal/manifest"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+func (c command) handleInit(args []string) {
+	flagset := flag.NewFlagSet("init", flag.ExitOnError)
+
+	var name string
+	flagset.StringVar(&name, "name", "", "The name of the endpoint, defaults to the name of the current directory")
+	var runtime string
+	flagset.StringVar(&runtime, "runtime", "", "The runtime of the endpoint ("+strings.Join(types.RuntimeNames(), ", ")+"), detected from the sources if not provided")
+	_ = flagset.Parse(args)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	if len(name) == 0 {
+		name = filepath.Base(wd)
+	}
+	if len(runtime) == 0 {
+		runtime, err = build.Detect(wd)
+		if err != nil {
+			printErrorAndExit(fmt.Errorf("%s, use --runtime", err))
+		}
+	}
+	if err := manifest.Init(manifest.FileName, name, runtime); err != nil {
+		printErrorAndExit(err)
+	}
+	fmt.Printf("created %s for endpoint %s (%s)\n", manifest.FileName, name, runtime)
+	fmt.Println("run raptor apply to create the endpoint or raptor deploy to create it and deploy")
+}
+
+func (c command) handleApply(args []string) {
+	flagset := flag.NewFlagSet("apply", flag.ExitOnError)
+
+	var manifestFile string
+	flagset.StringVar(&manifestFile, "manifest", manifest.FileName, "The manifest to apply")
+	var dryRun bool
+	flagset.BoolVar(&dryRun, "dry-run", false, "Only print the changes")
+	_ = flagset.Parse(args)
+
+	m, err := manifest.Load(manifestFile)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	if _, err := c.apply(manifestFile, m, dryRun); err != nil {
+		printErrorAndExit(err)
+	}
+}
+
+func (c command) handleManifestDeploy(manifestFile string, params api.CreateDeploymentParams) {
+	m, err := manifest.Load(manifestFile)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	// The smoke tests of the manifest run when no smoke tests are given.
+	if params.Publish && len(params.SmokeTests) == 0 {
+		manifestParams, err := makeCreateDeploymentParams(true, m.SmokeTests)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+		params.SmokeTests = manifestParams.SmokeTests
+	}
+	endpointID := m.EndpointID()
+	if endpointID == uuid.Nil {
+		endpointID, err = c.apply(manifestFile, m, false)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+	}
+
+	// The assets of the manifest are deployed when no assets are given.
+	if dir := m.AssetsDir(manifestFile); len(dir) > 0 && len(params.Assets) == 0 {
+		params.Assets, err = assets.Archive(dir)
+		if err != nil {
+			printErrorAndExit(err)
+		}
+	}
+
+	source := m.Source(manifestFile)
+	var b []byte
+	if len(m.File) > 0 {
+		b, err = os.ReadFile(source)
+	} else {
+		b, err = buildSources(source, m.Runtime)
+	}
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	deploy, err := c.client.CreateDeployment(endpointID, bytes.NewReader(b), params)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	printCreatedDeploy(deploy, params)
+}
+
+// apply reconciles the endpoint with the manifest and returns its id. The
+// endpoint is created when the manifest has no id yet, after which the id is
+// stored in the manifest.
+func (c command) apply(manifestFile string, m *manifest.Manifest, dryRun bool) (uuid.UUID, error) {
+	var (
+		endpoint  *types.Endpoint
+		domains   = []string{}
+		schedules = []types.Schedule{}
+		err       error
+	)
+	if m.EndpointID() == uuid.Nil {
+		if dryRun {
+			fmt.Printf("+ endpoint %s (%s)\n", m.Name, m.Runtime)
+			return uuid.Nil, nil
+		}
+		endpoint, err = c.client.CreateEndpoint(api.CreateEndpointParams{
+			Name:                m.Name,
+			Slug:                m.Slug,
+			Runtime:             m.Runtime,
+			Environment:         m.Environment,
+			SnapshotEnvironment: m.SnapshotEnvironment,
+			AllowedHosts:        m.AllowedHosts,
+		})
+		if err != nil {
+			return uuid.Nil, err
+		}
+		if err := manifest.SetID(manifestFile, endpoint.ID); err != nil {
+			return uuid.Nil, err
+		}
+		fmt.Printf("+ endpoint %s (%s)\n", endpoint.Name, endpoint.ID)
+	} else {
+		endpoint, err = c.client.GetEndpoint(m.EndpointID())
+		if err != nil {
+			return uuid.Nil, err
+		}
+		existingDomains, err := c.client.GetDomains(endpoint.ID)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		for _, domain := range existingDomains {
+			domains = append(domains, domain.Hostname)
+		}
+		schedules, err = c.client.GetSchedules(endpoint.ID)
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	plan, err := m.Diff(endpoint, domains, schedules)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	for _, warning := range plan.Warnings {
+		fmt.Printf("! %s\n", warning)
+	}
+	if plan.Empty() {
+		fmt.Printf("endpoint %s is up to date\n", endpoint.ID)
+		return endpoint.ID, nil
+	}
+	printPlan(plan)
+	if dryRun {
+		return endpoint.ID, nil
+	}
+
+	if plan.Update != nil {
+		if _, err := c.client.UpdateEndpoint(endpoint.ID, *plan.Update); err != nil {
+			return uuid.Nil, err
+		}
+	}
+	for _, hostname := range plan.RemoveDomains {
+		if err := c.client.DeleteDomain(hostname); err != nil {
+			return uuid.Nil, err
+		}
+	}
+	for _, hostname := range plan.AddDomains {
+		domain, err := c.client.CreateDomain(endpoint.ID, api.CreateDomainParams{Hostname: hostname})
+		if err != nil {
+			return uuid.Nil, err
+		}
+		fmt.Printf("create a TXT record %s with the value %s and run: raptor domain verify --host %s\n",
+			domain.VerificationRecord, domain.VerificationToken, domain.Hostname)
+	}
+	for _, id := range plan.RemoveSchedules {
+		if err := c.client.DeleteSchedule(id); err != nil {
+			return uuid.Nil, err
+		}
+	}
+	for _, s := range plan.AddSchedules {
+		params := api.CreateScheduleParams{Cron: s.Cron, Method: s.Method, Path: s.Path}
+		if _, err := c.client.CreateSchedule(endpoint.ID, params); err != nil {
+			return uuid.Nil, err
+		}
+	}
+	fmt.Printf("endpoint %s is up to date\n", endpoint.ID)
+	return endpoint.ID, nil
+}
+
+func printPlan(plan manifest.Plan) {
+	if update := plan.Update; update != nil {
+		if update.ReplaceEnvironment {
+			fmt.Printf("~ environment (%d variables)\n", len(update.Environment))
+		}
+		if update.SnapshotEnvironment != nil {
+			fmt.Printf("~ snapshot environment: %t\n", *update.SnapshotEnvironment)
+		}
+		if update.AllowedHosts != nil {
+			fmt.Printf("~ allowed hosts: %v\n", update.AllowedHosts)
+		}
+	}
+	for _, hostname := range plan.RemoveDomains {
+		fmt.Printf("- domain %s\n", hostname)
+	}
+	for _, hostname := range plan.AddDomains {
+		fmt.Printf("+ domain %s\n", hostname)
+	}
+	for _, id := range plan.RemoveSchedules {
+		fmt.Printf("- schedule %s\n", id)
+	}
+	for _, s := range plan.AddSchedules {
+		fmt.Printf("+ schedule %s\n", s)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anthdm/raptor/internal/actrs"
+	"github.com/anthdm/raptor/internal/cpython"
+	"github.com/anthdm/raptor/internal/runtime"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/spidermonkey"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/anthdm/raptor/proto"
+	"github.com/google/uuid"
+	"github.com/tetratelabs/wazero"
+
+	prot "google.golang.org/protobuf/proto"
+)
+
+// reloadInterval is the interval at which the served file is checked for
+// changes.
+var reloadInterval = time.Millisecond * 500
+
+// devServer serves a single WASM file on an in-process runtime, without the
+// database and the cluster. The key-value store lives in memory for as long
+// as the server runs.
+type devServer struct {
+	file         string
+	engine       string
+	env          map[string]string
+	allowedHosts []string
+	endpointID   uuid.UUID
+	store        storage.Store
+
+	mu      sync.Mutex
+	runtime *runtime.Runtime
+	script  []byte
+	stdout  *bytes.Buffer
+	modTime time.Time
+}
+
+func (c command) handleServeEndpoint(args []string) {
+	flagset := flag.NewFlagSet("serve", flag.ExitOnError)
+
+	var file string
+	flagset.StringVar(&file, "file", "", "The WASM file (or JS file for the js runtime) to serve")
+	var engine string
+	flagset.StringVar(&engine, "runtime", "go", "The runtime of the file ("+strings.Join(types.RuntimeNames(), ", ")+")")
+	var addr string
+	flagset.StringVar(&addr, "addr", ":5000", "The address to listen on")
+	var env stringList
+	flagset.Var(&env, "env", "Environment variables of the served file")
+	var allowHosts stringList
+	flagset.Var(&allowHosts, "allow-host", "A host the guest may send outbound HTTP requests to (repeatable)")
+	_ = flagset.Parse(args)
+
+	if len(file) == 0 {
+		printErrorAndExit(fmt.Errorf("please provide the file to serve --file <app.wasm>"))
+	}
+	if !types.ValidRuntime(engine) {
+		printErrorAndExit(fmt.Errorf("invalid runtime %s, supported runtimes are %s", engine, strings.Join(types.RuntimeNames(), ", ")))
+	}
+	for _, host := range allowHosts {
+		if !types.ValidAllowedHost(host) {
+			printErrorAndExit(fmt.Errorf("invalid allowed host %q", host))
+		}
+	}
+
+	s := &devServer{
+		file:         file,
+		engine:       engine,
+		env:          makeEnvMap(env),
+		allowedHosts: allowHosts,
+		endpointID:   uuid.New(),
+		store:        storage.NewMemoryStore(),
+		stdout:       &bytes.Buffer{},
+	}
+	if err := s.load(); err != nil {
+		printErrorAndExit(err)
+	}
+	go s.watch()
+
+	fmt.Printf("serving %s (%s) on %s\n", file, engine, addr)
+	log.Fatal(http.ListenAndServe(addr, s))
+}
+
+// load (re)creates the runtime from the file on disk.
+func (s *devServer) load() error {
+	info, err := os.Stat(s.file)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(s.file)
+	if err != nil {
+		return err
+	}
+	args := runtime.Args{
+		Cache:        wazero.NewCompilationCache(),
+		DeploymentID: uuid.New(),
+		Engine:       s.engine,
+		Blob:         b,
+		Stdout:       s.stdout,
+		Fetch:        runtime.FetchConfig{AllowedHosts: s.allowedHosts},
+		KV:           actrs.NewEndpointKV(s.store, s.endpointID),
+	}
+	var script []byte
+	switch s.engine {
+	case "js":
+		args.Blob = spidermonkey.WasmBlob
+		script = b
+	case "python":
+		args.Blob = cpython.WasmBlob
+		script = b
+	}
+	run, err := runtime.New(context.Background(), args)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.runtime != nil {
+		s.runtime.Close()
+	}
+	s.runtime = run
+	s.script = script
+	s.modTime = info.ModTime()
+	return nil
+}
+
+// watch reloads the runtime when the file changes. A file that fails to load
+// is reported and the previous version keeps serving.
+func (s *devServer) watch() {
+	for range time.Tick(reloadInterval) {
+		info, err := os.Stat(s.file)
+		if err != nil {
+			continue
+		}
+		s.mu.Lock()
+		changed := !info.ModTime().Equal(s.modTime)
+		s.mu.Unlock()
+		if !changed {
+			continue
+		}
+		if err := s.load(); err != nil {
+			fmt.Printf("failed to reload %s: %s\n", s.file, err)
+			// Do not retry until the file changes again.
+			s.mu.Lock()
+			s.modTime = info.ModTime()
+			s.mu.Unlock()
+			continue
+		}
+		fmt.Printf("reloaded %s\n", s.file)
+	}
+}
+
+func (s *devServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	req, err := shared.MakeProtoRequest(uuid.NewString(), r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// There is no /live/<endpoint> prefix hence we keep the full path.
+	req.URL = shared.WithQuery(r.URL.Path, r.URL)
+	req.Runtime = s.engine
+	req.EndpointID = s.endpointID.String()
+	req.Env = s.env
+	req.AllowedHosts = s.allowedHosts
+	resp, logs, err := s.invoke(req)
+	if len(logs) > 0 {
+		os.Stdout.Write(logs)
+	}
+	if err != nil {
+		fmt.Printf("%s %s: %s\n", r.Method, r.URL.Path, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Printf("%s %s %d %v\n", r.Method, r.URL.Path, resp.StatusCode, time.Since(start))
+	for name, values := range shared.ResponseHeader(resp.Header) {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(int(resp.StatusCode))
+	w.Write(resp.Response)
+}
+
+// invoke runs the request on the runtime. Requests are served one at a time
+// since the runtime writes to a single stdout buffer.
+func (s *devServer) invoke(req *proto.HTTPRequest) (*proto.HTTPResponse, []byte, error) {
+	b, err := prot.Marshal(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.stdout.Reset()
+
+	args := []string{}
+	switch s.engine {
+	case "js":
+		args, err = runtime.JSArgs(s.script, req, s.env)
+		if err != nil {
+			return nil, nil, err
+		}
+	case "python":
+		args = runtime.PythonArgs(s.script)
+	}
+	if err := s.runtime.Invoke(bytes.NewReader(b), s.env, args...); err != nil {
+		return nil, bytes.Clone(s.stdout.Bytes()), err
+	}
+	logs, res, header, status, err := shared.ParseResponse(s.stdout)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &proto.HTTPResponse{
+		Response:   res,
+		RequestID:  req.ID,
+		StatusCode: int32(status),
+		Header:     shared.MakeProtoHeader(header),
+	}, logs, nil
+}
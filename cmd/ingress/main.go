@@ -7,10 +7,12 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/anthdm/hollywood/actor"
 	"github.com/anthdm/hollywood/cluster"
 	"github.com/anthdm/raptor/internal/actrs"
+	"github.com/anthdm/raptor/internal/certs"
 	"github.com/anthdm/raptor/internal/config"
 	"github.com/anthdm/raptor/internal/storage"
 )
@@ -60,21 +62,79 @@ func main() {
 		log.Fatal(err)
 	}
 	c.RegisterKind(actrs.KindRuntime, actrs.NewRuntime(store, modCache), &cluster.KindConfig{})
-	c.Engine().Spawn(actrs.NewMetric, actrs.KindMetric, actor.WithID("1"))
+	c.RegisterKind(actrs.KindSocket, actrs.NewSocket(store, modCache), &cluster.KindConfig{})
+	c.Engine().Spawn(actrs.NewMetric(metricStore), actrs.KindMetric, actor.WithID("1"))
 	c.Spawn(actrs.NewRuntimeManager(c), actrs.KindRuntimeManager, actor.WithID("1"))
-	c.Engine().Spawn(actrs.NewRuntimeLog, actrs.KindRuntimeLog, actor.WithID("1"))
+	c.Engine().Spawn(actrs.NewRuntimeLog(metricStore), actrs.KindRuntimeLog, actor.WithID("1"))
 	c.Start()
+	c.Engine().Spawn(actrs.NewScheduler(store), actrs.KindScheduler, actor.WithID("1"))
+	c.Engine().Spawn(actrs.NewQueue(store), actrs.KindQueue, actor.WithID("1"))
+
+	var certManager *certs.Manager
+	if config.Get().TLS.Enabled {
+		certManager, err = makeCertManager(store)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	server := actrs.NewWasmServer(
 		config.Get().HTTPIngressAddr,
 		c,
 		store,
 		metricStore,
-		modCache)
+		modCache,
+		config.Get().TLS.HTTPSIngressAddr,
+		certManager)
 	c.Engine().Spawn(server, actrs.KindWasmServer)
 	fmt.Printf("ingress server running\t%s\n", config.Get().HTTPIngressAddr)
+	if certManager != nil {
+		fmt.Printf("ingress TLS server running\t%s\n", config.Get().TLS.HTTPSIngressAddr)
+	}
 
 	sigch := make(chan os.Signal, 1)
-	signal.Notify(sigch, syscall.SIGINT, syscall.SIGTERM)
-	<-sigch
+	signal.Notify(sigch, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range sigch {
+		if sig != syscall.SIGHUP {
+			return
+		}
+		// SIGHUP reloads the certificates without restarting the ingress.
+		if certManager != nil {
+			if _, err := certManager.Reload(); err != nil {
+				fmt.Printf("failed to reload certificates: %s\n", err)
+			}
+		}
+	}
+}
+
+func makeCertManager(store certs.Store) (*certs.Manager, error) {
+	tlsConfig := config.Get().TLS
+	manager := certs.NewManager(store)
+	if len(tlsConfig.CertFile) > 0 {
+		pair := certs.FilePair{
+			CertFile: tlsConfig.CertFile,
+			KeyFile:  tlsConfig.KeyFile,
+		}
+		if err := manager.LoadFiles(pair); err != nil {
+			return nil, err
+		}
+	}
+	if len(tlsConfig.CertDir) > 0 {
+		if err := manager.LoadDir(tlsConfig.CertDir); err != nil {
+			return nil, err
+		}
+	}
+	if tlsConfig.ACME {
+		acmeConfig := certs.ACMEConfig{
+			DirectoryURL: tlsConfig.ACMEDirectoryURL,
+			Email:        tlsConfig.ACMEEmail,
+			CacheDir:     tlsConfig.ACMECacheDir,
+			CAFile:       tlsConfig.ACMECAFile,
+		}
+		if err := manager.EnableACME(acmeConfig); err != nil {
+			return nil, err
+		}
+	}
+	go manager.Watch(time.Minute, nil)
+	return manager, nil
 }
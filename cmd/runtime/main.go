@@ -46,8 +46,8 @@ func main() {
 		log.Fatal(err)
 	}
 	var (
-		modCache = storage.NewDefaultModCache()
-		// metricStore = store
+		modCache    = storage.NewDefaultModCache()
+		metricStore = store
 	)
 	clusterConfig := cluster.NewConfig().
 		WithListenAddr(address).
@@ -58,8 +58,9 @@ func main() {
 		log.Fatal(err)
 	}
 	c.RegisterKind(actrs.KindRuntime, actrs.NewRuntime(store, modCache), &cluster.KindConfig{})
-	c.Engine().Spawn(actrs.NewMetric, actrs.KindMetric, actor.WithID("1"))
-	c.Engine().Spawn(actrs.NewRuntimeLog, actrs.KindRuntimeLog, actor.WithID("1"))
+	c.RegisterKind(actrs.KindSocket, actrs.NewSocket(store, modCache), &cluster.KindConfig{})
+	c.Engine().Spawn(actrs.NewMetric(metricStore), actrs.KindMetric, actor.WithID("1"))
+	c.Engine().Spawn(actrs.NewRuntimeLog(metricStore), actrs.KindRuntimeLog, actor.WithID("1"))
 	c.Start()
 
 	sigch := make(chan os.Signal, 1)
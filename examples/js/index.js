@@ -1,19 +1,7 @@
-// This should come from the official SDK.
-// But there is no official SDK yet, so we keep it here.
-function respond(res, status) {
-    var buffer = new ArrayBuffer(8);
-    var view = new DataView(buffer);
-    view.setUint32(0, status, true);
-    view.setUint32(4, res.length, true);
+// The SDK is injected by the runtime, it hands the request to the fetch
+// handler.
+addEventListener("fetch", (event) => {
+    console.log("USER LOGS", event.request.method, event.request.url);
 
-    putstr(res);
-    writebytes(view)
-}
-
-console.log("USER LOGS");
-console.log("USER LOGS");
-console.log("USER LOGS");
-console.log("USER LOGS");
-console.log("USER LOGS");
-
-respond("Hello world!", 200)
\ No newline at end of file
+    event.respondWith(new Response("Hello world!"));
+});
@@ -0,0 +1,10 @@
+import raptor
+
+
+def handler(request):
+    print("user request", request.method, request.url)
+    name = request.query.get("name", "world")
+    return raptor.Response.json({"hello": name, "from": "python"})
+
+
+raptor.handle(handler)
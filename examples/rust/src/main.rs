@@ -0,0 +1,10 @@
+// Build with: cargo build --target wasm32-wasip1 --release
+// Deploy target/wasm32-wasip1/release/rust-example.wasm on an endpoint with
+// the rust runtime.
+fn main() {
+    raptor::handle(|req| {
+        println!("user request {} {}", req.method, req.url);
+        let name = req.query("name").unwrap_or("world");
+        raptor::Response::new(200).body(format!("hello {} from rust!", name))
+    });
+}
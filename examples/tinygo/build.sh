@@ -0,0 +1 @@
+tinygo build -target=wasip1 -o examples/tinygo/app.wasm examples/tinygo/main.go
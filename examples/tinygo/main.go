@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/anthdm/raptor/sdk/tinygo"
+)
+
+func main() {
+	tinygo.Handle(func(w *tinygo.ResponseWriter, r *tinygo.Request) {
+		fmt.Println("user request", r.Method, r.RequestURI)
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			name = "world"
+		}
+		w.Write([]byte("hello " + name + " from tinygo!"))
+	})
+}
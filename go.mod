@@ -10,6 +10,7 @@ require (
 	github.com/stealthrocket/net v0.2.1
 	github.com/stretchr/testify v1.8.4
 	github.com/tetratelabs/wazero v1.6.0
+	golang.org/x/crypto v0.14.0
 	google.golang.org/protobuf v1.30.0
 )
 
@@ -25,7 +26,6 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/zeebo/errs v1.2.2 // indirect
 	github.com/zeebo/xxh3 v1.0.2 // indirect
-	golang.org/x/crypto v0.14.0 // indirect
 	golang.org/x/exp v0.0.0-20231214170342-aacd6d4b4611 // indirect
 	golang.org/x/text v0.13.0 // indirect
 	google.golang.org/genproto v0.0.0-20230110181048-76db0878b65f // indirect
@@ -37,6 +37,6 @@ require (
 require (
 	github.com/go-chi/chi v1.5.5
 	github.com/pelletier/go-toml/v2 v2.1.1
-	golang.org/x/net v0.17.0 // indirect
+	golang.org/x/net v0.17.0
 	golang.org/x/sys v0.14.0 // indirect
 )
@@ -1 +1,25 @@
-GOOS=wasip1 GOARCH=wasm go build -o internal/_testdata/helloworld.wasm internal/_testdata/helloworld.go 
\ No newline at end of file
+#!/bin/sh
+set -e
+
+GOOS=wasip1 GOARCH=wasm go build -o internal/_testdata/helloworld.wasm internal/_testdata/helloworld.go 
+GOOS=wasip1 GOARCH=wasm go build -o internal/_testdata/websocket.wasm internal/_testdata/websocket.go 
+GOOS=wasip1 GOARCH=wasm go build -o internal/_testdata/fetch.wasm internal/_testdata/fetch.go 
+GOOS=wasip1 GOARCH=wasm go build -o internal/_testdata/kv.wasm internal/_testdata/kv.go 
+GOOS=wasip1 GOARCH=wasm go build -o internal/_testdata/loop.wasm internal/_testdata/loop.go
+GOOS=wasip1 GOARCH=wasm go build -o internal/_testdata/wasi.wasm internal/_testdata/wasi.go
+GOOS=wasip1 GOARCH=wasm go build -o internal/_testdata/stdio.wasm internal/_testdata/stdio.go
+
+# The tinygo and rust modules are optional locally. CI installs both
+# toolchains so the tests that use them run there.
+if command -v tinygo >/dev/null 2>&1; then
+	tinygo build -target=wasip1 -o internal/_testdata/tinygo.wasm internal/_testdata/wasi.go
+else
+	echo "tinygo not found, skipping tinygo.wasm"
+fi
+
+if command -v rustup >/dev/null 2>&1 && rustup target list --installed | grep -qx wasm32-wasip1; then
+	cargo build --manifest-path internal/_testdata/rust/Cargo.toml --target wasm32-wasip1 --release
+	cp internal/_testdata/rust/target/wasm32-wasip1/release/rust-testdata.wasm internal/_testdata/rust.wasm
+else
+	echo "rust wasm32-wasip1 target not installed, skipping rust.wasm"
+fi
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"os"
+
+	raptor "github.com/anthdm/raptor/sdk"
+)
+
+// handle fetches the url in the FETCH_URL environment variable and responds
+// with its body.
+func handle(w http.ResponseWriter, r *http.Request) {
+	resp, err := http.Get(os.Getenv("FETCH_URL"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	w.WriteHeader(resp.StatusCode)
+	w.Write(b)
+}
+
+func main() {
+	raptor.Handle(http.HandlerFunc(handle))
+}
@@ -7,6 +7,7 @@ import (
 )
 
 func handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "max-age=60")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Hello world!"))
 }
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	raptor "github.com/anthdm/raptor/sdk"
+)
+
+// handle increments a counter in the key-value store and responds with it.
+func handle(w http.ResponseWriter, r *http.Request) {
+	count := 0
+	b, err := raptor.KV.Get("counter")
+	if err != nil && !errors.Is(err, raptor.ErrKeyNotFound) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err == nil {
+		count, _ = strconv.Atoi(string(b))
+	}
+	count++
+	if err := raptor.KV.Put("counter", []byte(strconv.Itoa(count)), 0); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(strconv.Itoa(count)))
+}
+
+func main() {
+	raptor.Handle(http.HandlerFunc(handle))
+}
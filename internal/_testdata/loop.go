@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	raptor "github.com/anthdm/raptor/sdk"
+)
+
+// requests outlives requests when the instance serves them in a loop.
+var requests int
+
+func main() {
+	raptor.Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Println("serving", r.URL.Path)
+		fmt.Fprintf(w, "%s %d", r.URL.Path, requests)
+	}))
+}
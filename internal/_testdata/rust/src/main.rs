@@ -0,0 +1,14 @@
+fn main() {
+    raptor::handle(|req| {
+        if req.path() == "/panic" {
+            panic!("boom");
+        }
+        let body = format!(
+            "{} hello {} {}",
+            req.method,
+            req.query("name").unwrap_or(""),
+            req.env.get("FOO").map(|s| s.as_str()).unwrap_or("")
+        );
+        raptor::Response::new(201).body(body)
+    });
+}
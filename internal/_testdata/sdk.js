@@ -0,0 +1,14 @@
+addEventListener("fetch", (event) => {
+    event.respondWith((async () => {
+        const req = event.request;
+        const body = await req.json();
+        console.log("request", req.method, req.url);
+        return Response.json({
+            method: req.method,
+            url: req.url,
+            contentType: req.headers.get("content-type"),
+            name: body.name,
+            foo: event.env.FOO,
+        }, { status: 201 });
+    })());
+});
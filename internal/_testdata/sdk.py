@@ -0,0 +1,25 @@
+import os
+
+import raptor
+
+
+def handler(request):
+    if request.path == "/panic":
+        raise RuntimeError("boom")
+    print("request", request.method, request.url)
+    return raptor.Response.json(
+        {
+            "method": request.method,
+            "url": request.url,
+            "contentType": request.headers.get("content-type"),
+            "name": request.json()["name"],
+            "id": request.query.get("id"),
+            "foo": request.env.get("FOO"),
+            "environ": os.environ.get("FOO"),
+            "requestID": request.info.request_id,
+        },
+        status=201,
+    )
+
+
+raptor.handle(handler)
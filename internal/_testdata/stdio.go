@@ -0,0 +1,24 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// This guest calls the host over stdio like the scripts of the js runtime
+// do. It makes the host call in $CALL and responds with the answer.
+func main() {
+	fmt.Fprintln(os.Stderr, "not a host call")
+	fmt.Fprintf(os.Stderr, "\x1braptor:%s\n", os.Getenv("CALL"))
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		answer = err.Error()
+	}
+	os.Stdout.WriteString(answer)
+	trailer := make([]byte, 8)
+	binary.LittleEndian.PutUint32(trailer, 200)
+	binary.LittleEndian.PutUint32(trailer[4:], uint32(len(answer)))
+	os.Stdout.Write(trailer)
+}
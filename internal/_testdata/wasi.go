@@ -0,0 +1,17 @@
+package main
+
+import (
+	"github.com/anthdm/raptor/sdk/tinygo"
+)
+
+// This guest is built with TinyGo for the tinygo runtime and with the
+// standard Go toolchain as a generic module for the wasi runtime.
+func main() {
+	tinygo.Handle(func(w *tinygo.ResponseWriter, r *tinygo.Request) {
+		if r.URL.Path == "/panic" {
+			panic("boom")
+		}
+		w.WriteHeader(201)
+		w.Write([]byte(r.Method + " hello " + r.URL.Query().Get("name") + " " + r.Env["FOO"]))
+	})
+}
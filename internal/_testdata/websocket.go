@@ -0,0 +1,22 @@
+package main
+
+import (
+	"net/http"
+
+	raptor "github.com/anthdm/raptor/sdk"
+)
+
+func handleSocket(conn *raptor.Conn, r *http.Request) {
+	for {
+		msg, err := conn.Read()
+		if err != nil {
+			return
+		}
+		conn.WriteText("echo: " + string(msg.Data))
+	}
+}
+
+func main() {
+	raptor.HandleWebSocket(handleSocket)
+	raptor.Handle(http.NotFoundHandler())
+}
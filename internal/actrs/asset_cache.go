@@ -0,0 +1,88 @@
+package actrs
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/anthdm/raptor/internal/assets"
+	"github.com/google/uuid"
+)
+
+// assetCacheSize is the number of bytes of assets that are cached. It holds
+// a few bundles of assets.MaxSize.
+var assetCacheSize int64 = 256 << 20
+
+// assetEntrySize is the size an entry counts with besides its bundle, so
+// deployments without assets are bounded as well.
+const assetEntrySize = 64
+
+// assetCache caches the parsed assets of deployments, so the ingress does
+// not hit the store on every request. Deployments never change, hence
+// entries do not expire. Deployments without assets are cached as well.
+// The cache holds up to a total size, and the least recently used
+// deployments are evicted first.
+type assetCache struct {
+	mu      sync.Mutex
+	maxSize int64
+	size    int64
+	lru     *list.List
+	entries map[uuid.UUID]*list.Element
+}
+
+type assetCacheEntry struct {
+	deployID uuid.UUID
+	bundle   *assets.Bundle
+	size     int64
+}
+
+func newAssetCache(maxSize int64) *assetCache {
+	return &assetCache{
+		maxSize: maxSize,
+		lru:     list.New(),
+		entries: make(map[uuid.UUID]*list.Element),
+	}
+}
+
+func (c *assetCache) get(deployID uuid.UUID) (*assets.Bundle, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[deployID]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*assetCacheEntry).bundle, true
+}
+
+func (c *assetCache) put(deployID uuid.UUID, bundle *assets.Bundle) {
+	size := int64(assetEntrySize)
+	if bundle != nil {
+		size += bundle.Size()
+	}
+	// Bundles larger than the cache are parsed on every request.
+	if size > c.maxSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[deployID]; ok {
+		c.remove(elem)
+	}
+	for c.size+size > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+	c.entries[deployID] = c.lru.PushFront(&assetCacheEntry{
+		deployID: deployID,
+		bundle:   bundle,
+		size:     size,
+	})
+	c.size += size
+}
+
+func (c *assetCache) remove(elem *list.Element) {
+	entry := elem.Value.(*assetCacheEntry)
+	c.lru.Remove(elem)
+	delete(c.entries, entry.deployID)
+	c.size -= entry.size
+}
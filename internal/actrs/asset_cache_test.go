@@ -0,0 +1,60 @@
+package actrs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthdm/raptor/internal/assets"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func testBundle(t *testing.T, size int) *assets.Bundle {
+	dir := t.TempDir()
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "index.html"), make([]byte, size), 0644))
+	b, err := assets.Archive(dir)
+	require.Nil(t, err)
+	bundle, err := assets.Parse(b)
+	require.Nil(t, err)
+	return bundle
+}
+
+func TestAssetCacheBoundsSize(t *testing.T) {
+	bundle := testBundle(t, 1000)
+	entrySize := assetEntrySize + bundle.Size()
+	c := newAssetCache(2 * entrySize)
+
+	a, b, d := uuid.New(), uuid.New(), uuid.New()
+	c.put(a, bundle)
+	c.put(b, bundle)
+
+	// a is used, hence b is evicted first.
+	got, ok := c.get(a)
+	require.True(t, ok)
+	require.Equal(t, bundle, got)
+	c.put(d, bundle)
+	_, ok = c.get(b)
+	require.False(t, ok)
+	_, ok = c.get(a)
+	require.True(t, ok)
+	_, ok = c.get(d)
+	require.True(t, ok)
+	require.Equal(t, 2*entrySize, c.size)
+
+	// Bundles larger than the cache are not cached.
+	large := uuid.New()
+	c.put(large, testBundle(t, 10000))
+	_, ok = c.get(large)
+	require.False(t, ok)
+	require.Equal(t, 2*entrySize, c.size)
+}
+
+func TestAssetCacheDeploymentsWithoutAssets(t *testing.T) {
+	c := newAssetCache(10 * assetEntrySize)
+	for i := 0; i < 100; i++ {
+		c.put(uuid.New(), nil)
+	}
+	require.Equal(t, 10, len(c.entries))
+	require.Equal(t, int64(10*assetEntrySize), c.size)
+}
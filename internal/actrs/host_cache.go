@@ -0,0 +1,121 @@
+package actrs
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// hostCache caches the endpoint a hostname routes to, so the ingress does not
+// hit the store on every request. Hostnames come from the Host header of the
+// clients, hence both the hostnames that route to an endpoint and the ones
+// that do not are cached up to a fixed number, and the least recently used
+// are evicted first. The hostnames that do not route to any endpoint are
+// kept apart in a smaller cache, so random hostnames can not evict the
+// ones that do.
+type hostCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	found   *hostLRU
+	missing *hostLRU
+}
+
+// hostLRU holds up to size hostnames in the order they were used.
+type hostLRU struct {
+	size    int
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type hostCacheEntry struct {
+	host       string
+	endpointID uuid.UUID
+	expires    time.Time
+}
+
+// newHostCache returns a cache that holds up to size hostnames that route to
+// an endpoint and up to missSize hostnames that do not.
+func newHostCache(ttl time.Duration, size int, missSize int) *hostCache {
+	return &hostCache{
+		ttl:     ttl,
+		found:   newHostLRU(size),
+		missing: newHostLRU(missSize),
+	}
+}
+
+func newHostLRU(size int) *hostLRU {
+	return &hostLRU{
+		size:    size,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *hostCache) get(host string) (endpointID uuid.UUID, found bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if endpointID, ok := c.found.get(host); ok {
+		return endpointID, true, true
+	}
+	if _, ok := c.missing.get(host); ok {
+		return uuid.Nil, false, true
+	}
+	return uuid.Nil, false, false
+}
+
+func (c *hostCache) put(host string, endpointID uuid.UUID, found bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := time.Now().Add(c.ttl)
+	if found {
+		c.missing.remove(host)
+		c.found.put(host, endpointID, expires)
+		return
+	}
+	c.found.remove(host)
+	c.missing.put(host, uuid.Nil, expires)
+}
+
+func (l *hostLRU) get(host string) (uuid.UUID, bool) {
+	elem, ok := l.entries[host]
+	if !ok {
+		return uuid.Nil, false
+	}
+	entry := elem.Value.(*hostCacheEntry)
+	if time.Now().After(entry.expires) {
+		l.remove(host)
+		return uuid.Nil, false
+	}
+	l.lru.MoveToFront(elem)
+	return entry.endpointID, true
+}
+
+func (l *hostLRU) put(host string, endpointID uuid.UUID, expires time.Time) {
+	if l.size <= 0 {
+		return
+	}
+	if elem, ok := l.entries[host]; ok {
+		entry := elem.Value.(*hostCacheEntry)
+		entry.endpointID = endpointID
+		entry.expires = expires
+		l.lru.MoveToFront(elem)
+		return
+	}
+	for l.lru.Len() >= l.size {
+		l.remove(l.lru.Back().Value.(*hostCacheEntry).host)
+	}
+	l.entries[host] = l.lru.PushFront(&hostCacheEntry{
+		host:       host,
+		endpointID: endpointID,
+		expires:    expires,
+	})
+}
+
+func (l *hostLRU) remove(host string) {
+	if elem, ok := l.entries[host]; ok {
+		l.lru.Remove(elem)
+		delete(l.entries, host)
+	}
+}
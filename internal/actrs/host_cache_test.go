@@ -0,0 +1,59 @@
+package actrs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newHostCache(time.Minute, 2, 1)
+	a, b := uuid.New(), uuid.New()
+	c.put("a.example.com", a, true)
+	c.put("b.example.com", b, true)
+
+	// a is used, hence b is evicted first.
+	endpointID, found, ok := c.get("a.example.com")
+	require.True(t, ok)
+	require.True(t, found)
+	require.Equal(t, a, endpointID)
+	c.put("c.example.com", uuid.New(), true)
+	_, _, ok = c.get("b.example.com")
+	require.False(t, ok)
+	_, _, ok = c.get("a.example.com")
+	require.True(t, ok)
+	require.Equal(t, 2, len(c.found.entries))
+}
+
+func TestHostCacheBoundsMisses(t *testing.T) {
+	c := newHostCache(time.Minute, 2, 2)
+	endpointID := uuid.New()
+	c.put("app.example.com", endpointID, true)
+	for i := 0; i < 100; i++ {
+		c.put(fmt.Sprintf("random-%d.example.com", i), uuid.Nil, false)
+	}
+	require.Equal(t, 2, len(c.missing.entries))
+
+	// Misses do not evict the hostnames that route to an endpoint.
+	id, found, ok := c.get("app.example.com")
+	require.True(t, ok)
+	require.True(t, found)
+	require.Equal(t, endpointID, id)
+
+	_, found, ok = c.get("random-99.example.com")
+	require.True(t, ok)
+	require.False(t, found)
+	_, _, ok = c.get("random-0.example.com")
+	require.False(t, ok)
+}
+
+func TestHostCacheExpires(t *testing.T) {
+	c := newHostCache(-time.Second, 2, 2)
+	c.put("app.example.com", uuid.New(), true)
+	_, _, ok := c.get("app.example.com")
+	require.False(t, ok)
+	require.Equal(t, 0, len(c.found.entries))
+}
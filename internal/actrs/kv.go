@@ -0,0 +1,67 @@
+package actrs
+
+import (
+	"errors"
+	"time"
+
+	"github.com/anthdm/raptor/internal/runtime"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+// maxKVListLimit is the maximum number of keys a guest can list at once.
+const maxKVListLimit = 1000
+
+// endpointKV is the key-value store of a single endpoint that is handed to
+// the runtime.
+type endpointKV struct {
+	store      storage.Store
+	endpointID uuid.UUID
+}
+
+// NewEndpointKV returns the key-value store of the given endpoint.
+func NewEndpointKV(store storage.Store, endpointID uuid.UUID) runtime.KV {
+	return endpointKV{
+		store:      store,
+		endpointID: endpointID,
+	}
+}
+
+func (kv endpointKV) Get(key string) ([]byte, error) {
+	entry, err := kv.store.GetKV(kv.endpointID, key)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return nil, runtime.ErrKVNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entry.Value, nil
+}
+
+func (kv endpointKV) Put(key string, value []byte, ttl time.Duration) error {
+	entry, err := types.NewKVEntry(kv.endpointID, key, value, ttl)
+	if err != nil {
+		return err
+	}
+	return kv.store.PutKV(entry)
+}
+
+func (kv endpointKV) Delete(key string) error {
+	return kv.store.DeleteKV(kv.endpointID, key)
+}
+
+func (kv endpointKV) List(prefix string, limit int) ([]string, error) {
+	if limit > maxKVListLimit {
+		limit = maxKVListLimit
+	}
+	entries, err := kv.store.ListKV(kv.endpointID, prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(entries))
+	for i, entry := range entries {
+		keys[i] = entry.Key
+	}
+	return keys, nil
+}
@@ -1,7 +1,10 @@
 package actrs
 
 import (
+	"log/slog"
+
 	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/raptor/internal/storage"
 	"github.com/anthdm/raptor/internal/types"
 )
 
@@ -10,18 +13,27 @@ import (
 
 const KindMetric = "runtime_metric"
 
-type Metric struct{}
+type Metric struct {
+	store storage.MetricStore
+}
 
-func NewMetric() actor.Receiver {
-	return &Metric{}
+func NewMetric(store storage.MetricStore) actor.Producer {
+	return func() actor.Receiver {
+		return &Metric{
+			store: store,
+		}
+	}
 }
 
-// TODO: Store metrics where they belong
 func (m *Metric) Receive(c *actor.Context) {
 	switch msg := c.Message().(type) {
 	case actor.Started:
 	case actor.Stopped:
 	case types.RuntimeMetric:
 		_ = msg
+	case types.RequestMetric:
+		if err := m.store.CreateRequestMetric(&msg); err != nil {
+			slog.Warn("failed to store request metric", "err", err, "endpoint", msg.EndpointID)
+		}
 	}
 }
@@ -0,0 +1,305 @@
+package actrs
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/anthdm/raptor/proto"
+	"github.com/google/uuid"
+)
+
+const KindQueue = "queue"
+
+// The headers of asynchronous invocations. The max attempts and callback
+// headers are set by the caller, the invocation header is set on the request
+// the guest receives and the token header is set when polling.
+const (
+	InvocationHeader      = "X-Raptor-Invocation"
+	MaxAttemptsHeader     = "X-Raptor-Max-Attempts"
+	CallbackHeader        = "X-Raptor-Callback-URL"
+	InvocationTokenHeader = "X-Raptor-Invocation-Token"
+)
+
+// sensitiveHeaders are the headers of the caller that are not stored with
+// an invocation, hence they are neither passed to the guest nor shown when
+// the invocation is polled or called back.
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Proxy-Authorization",
+}
+
+const (
+	defaultMaxAttempts    = 3
+	defaultBackoff        = time.Second
+	defaultMaxBackoff     = time.Minute * 5
+	maxInvocationAttempts = 10
+)
+
+var (
+	// queueInterval is the interval at which the queue checks for due
+	// invocations. The queue is also woken up by new invocations.
+	queueInterval = time.Second
+	// queueBatchSize is the number of invocations that are claimed at once.
+	queueBatchSize = 10
+	// invocationTimeout is the time the lease on a running invocation lasts.
+	// Invocations that are still running after it are attempted again.
+	invocationTimeout = time.Minute
+	// callbackTimeout is the time a webhook callback may take.
+	callbackTimeout = time.Second * 10
+)
+
+type queueTick struct{}
+
+// Queue is an actor that executes asynchronous invocations. Invocations that
+// fail with a 5xx status or crash the runtime are retried with an exponential
+// backoff until their max attempts, after which they are dead-lettered.
+// Invocations are claimed in the store, hence multiple queues can run in the
+// cluster.
+type Queue struct {
+	store             storage.Store
+	runtimeManagerPID *actor.PID
+	repeat            actor.SendRepeater
+	client            *http.Client
+}
+
+func NewQueue(store storage.Store) actor.Producer {
+	return func() actor.Receiver {
+		return &Queue{
+			store:  store,
+			client: newCallbackClient(),
+		}
+	}
+}
+
+func (q *Queue) Receive(c *actor.Context) {
+	switch c.Message().(type) {
+	case actor.Started:
+		q.runtimeManagerPID = c.Engine().Registry.GetPID(KindRuntimeManager, "1")
+		q.repeat = c.SendRepeat(c.PID(), queueTick{}, queueInterval)
+	case actor.Stopped:
+		q.repeat.Stop()
+	case queueTick:
+		invocations, err := q.store.ClaimInvocations(time.Now().UTC(), invocationTimeout, queueBatchSize)
+		if err != nil {
+			slog.Warn("failed to claim invocations", "err", err)
+			return
+		}
+		for _, invocation := range invocations {
+			go q.run(c.Engine(), invocation)
+		}
+	}
+}
+
+func (q *Queue) run(engine *actor.Engine, invocation *types.Invocation) {
+	// Invocations are attempted once more than their max attempts when their
+	// last runner crashed.
+	if invocation.Attempts > invocation.MaxAttempts {
+		invocation.Status = types.InvocationDead
+		invocation.UpdatedAT = time.Now().UTC()
+		q.finish(invocation)
+		return
+	}
+
+	resp, err := q.invoke(engine, invocation)
+	now := time.Now().UTC()
+	invocation.UpdatedAT = now
+	switch {
+	case err != nil:
+		invocation.StatusCode = 0
+		invocation.Response = nil
+		invocation.Error = err.Error()
+	case resp.StatusCode >= http.StatusInternalServerError:
+		invocation.StatusCode = int(resp.StatusCode)
+		invocation.Response = resp.Response
+		invocation.Error = fmt.Sprintf("endpoint responded with status %d", resp.StatusCode)
+	default:
+		invocation.Status = types.InvocationSucceeded
+		invocation.StatusCode = int(resp.StatusCode)
+		invocation.Response = resp.Response
+		invocation.Error = ""
+		q.finish(invocation)
+		return
+	}
+	if invocation.Attempts >= invocation.MaxAttempts {
+		invocation.Status = types.InvocationDead
+	} else {
+		invocation.Status = types.InvocationQueued
+		invocation.NextAttemptAT = now.Add(backoff(invocation))
+	}
+	q.finish(invocation)
+}
+
+func (q *Queue) invoke(engine *actor.Engine, invocation *types.Invocation) (*proto.HTTPResponse, error) {
+	endpoint, err := q.store.GetEndpoint(invocation.EndpointID)
+	if err != nil {
+		return nil, err
+	}
+	header := make(map[string]*proto.HeaderFields, len(invocation.Header)+1)
+	for k, v := range invocation.Header {
+		header[k] = &proto.HeaderFields{Fields: v}
+	}
+	header[InvocationHeader] = &proto.HeaderFields{Fields: []string{invocation.ID.String()}}
+	req := &proto.HTTPRequest{
+		ID:     uuid.NewString(),
+		Method: invocation.Method,
+		URL:    invocation.URL,
+		Header: header,
+		Body:   invocation.Body,
+	}
+	if err := makeLiveRequest(req, endpoint); err != nil {
+		return nil, err
+	}
+	invocation.DeploymentID = endpoint.ActiveDeploymentID
+	return invokeRuntime(engine, q.runtimeManagerPID, req, invocationTimeout)
+}
+
+// finish stores the result of the attempt and calls the callback of the
+// invocation when it is done.
+func (q *Queue) finish(invocation *types.Invocation) {
+	if err := q.store.UpdateInvocation(invocation); err != nil {
+		slog.Warn("failed to update invocation", "err", err, "invocation_id", invocation.ID)
+		return
+	}
+	slog.Info("invocation attempted", "invocation_id", invocation.ID, "status", invocation.Status, "attempt", invocation.Attempts)
+	if !invocation.Done() || len(invocation.CallbackURL) == 0 {
+		return
+	}
+	b, err := json.Marshal(invocation)
+	if err != nil {
+		return
+	}
+	resp, err := q.client.Post(invocation.CallbackURL, "application/json", bytes.NewReader(b))
+	if err != nil {
+		slog.Warn("invocation callback failed", "err", err, "invocation_id", invocation.ID)
+		return
+	}
+	resp.Body.Close()
+}
+
+// newCallbackClient returns the client of the callbacks, which only connects
+// to public addresses and does not follow redirects, so callbacks can not
+// reach the network of the ingress.
+func newCallbackClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: callbackTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("callback to %s is not allowed", address)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Transport: transport,
+		Timeout:   callbackTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// publicIP reports whether the given address is reachable on the internet,
+// which excludes loopback, private, link-local and unspecified addresses.
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// backoff returns the delay before the next attempt of the invocation, which
+// doubles with every attempt.
+func backoff(invocation *types.Invocation) time.Duration {
+	maxBackoff := time.Duration(config.Get().Async.MaxBackoffSeconds) * time.Second
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	delay := invocation.Backoff
+	for i := 1; i < invocation.Attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+// newInvocation returns a new invocation of the given endpoint for the given
+// request, together with the token it is polled with. The retry policy comes
+// from the config and can be overridden by the caller with the max attempts
+// header. Callbacks are only made to the allowed hosts of the endpoint.
+func newInvocation(endpoint *types.Endpoint, r *http.Request, req *proto.HTTPRequest) (*types.Invocation, string, error) {
+	header := make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		if k == MaxAttemptsHeader || k == CallbackHeader {
+			continue
+		}
+		header[k] = v.Fields
+	}
+	for _, k := range sensitiveHeaders {
+		header.Del(k)
+	}
+	invocation := types.NewInvocation(endpoint, req.Method, req.URL, header, req.Body)
+	token, err := newInvocationToken()
+	if err != nil {
+		return nil, "", err
+	}
+	invocation.TokenHash = types.HashInvocationToken(token)
+
+	asyncConfig := config.Get().Async
+	invocation.MaxAttempts = asyncConfig.MaxAttempts
+	if invocation.MaxAttempts <= 0 {
+		invocation.MaxAttempts = defaultMaxAttempts
+	}
+	invocation.Backoff = time.Duration(asyncConfig.BackoffSeconds) * time.Second
+	if invocation.Backoff <= 0 {
+		invocation.Backoff = defaultBackoff
+	}
+	if value := r.Header.Get(MaxAttemptsHeader); len(value) > 0 {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxInvocationAttempts {
+			return nil, "", fmt.Errorf("%s should be between 1 and %d", MaxAttemptsHeader, maxInvocationAttempts)
+		}
+		invocation.MaxAttempts = n
+	}
+	if value := r.Header.Get(CallbackHeader); len(value) > 0 {
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return nil, "", fmt.Errorf("%s should be an http or https url", CallbackHeader)
+		}
+		if !types.HostAllowed(endpoint.AllowedHosts, u) {
+			return nil, "", fmt.Errorf("%s should be on one of the allowed hosts of the endpoint", CallbackHeader)
+		}
+		invocation.CallbackURL = value
+	}
+	return invocation, token, nil
+}
+
+// newInvocationToken returns a random token to poll an invocation with.
+func newInvocationToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,60 @@
+package actrs
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewInvocation(t *testing.T) {
+	endpoint := types.NewEndpoint("My endpoint", "go", nil)
+	endpoint.AllowedHosts = []string{"hooks.example.com"}
+
+	r := httptest.NewRequest("POST", "/async/my-endpoint/users", strings.NewReader("body"))
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("Cookie", "session=secret")
+	r.Header.Set("X-Foo", "bar")
+	r.Header.Set(CallbackHeader, "https://hooks.example.com/done")
+	req, err := shared.MakeProtoRequest("request-id", r)
+	require.Nil(t, err)
+
+	invocation, token, err := newInvocation(endpoint, r, req)
+	require.Nil(t, err)
+	require.Equal(t, "https://hooks.example.com/done", invocation.CallbackURL)
+	require.Equal(t, []string{"bar"}, invocation.Header["X-Foo"])
+	require.NotContains(t, invocation.Header, "Authorization")
+	require.NotContains(t, invocation.Header, "Cookie")
+	require.NotContains(t, invocation.Header, CallbackHeader)
+	require.True(t, invocation.ValidToken(token))
+	require.False(t, invocation.ValidToken(""))
+	require.NotContains(t, invocation.TokenHash, token)
+
+	// Callbacks are only made to the allowed hosts of the endpoint.
+	for _, callback := range []string{"https://example.com/done", "http://127.0.0.1:8080", "ftp://hooks.example.com"} {
+		r.Header.Set(CallbackHeader, callback)
+		_, _, err = newInvocation(endpoint, r, req)
+		require.NotNil(t, err, callback)
+	}
+}
+
+func TestCallbackClientOnlyReachesPublicAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, err := newCallbackClient().Post(server.URL, "application/json", nil)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "is not allowed")
+
+	for _, ip := range []string{"127.0.0.1", "::1", "10.0.0.1", "172.16.0.1", "192.168.1.1", "169.254.169.254", "fe80::1", "fd00::1", "0.0.0.0"} {
+		require.False(t, publicIP(net.ParseIP(ip)), ip)
+	}
+	for _, ip := range []string{"93.184.216.34", "2606:2800:220:1::"} {
+		require.True(t, publicIP(net.ParseIP(ip)), ip)
+	}
+}
@@ -4,12 +4,17 @@ import (
 	"bytes"
 	"context"
 	_ "embed"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
 	"net/http"
 	"time"
 
 	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/cpython"
 	"github.com/anthdm/raptor/internal/runtime"
 	"github.com/anthdm/raptor/internal/shared"
 	"github.com/anthdm/raptor/internal/spidermonkey"
@@ -41,6 +46,14 @@ type Runtime struct {
 	repeat       actor.SendRepeater
 	stdout       *bytes.Buffer
 	script       []byte
+	// env holds the environment snapshot of the deployment if any.
+	env map[string]string
+	// loop is the long-lived instance that serves the requests when
+	// instances are reused, started with loopEnv.
+	loop    *runtime.Loop
+	loopEnv map[string]string
+	// noLoop is set when the guest does not serve requests in a loop.
+	noLoop bool
 }
 
 func NewRuntime(store storage.Store, cache storage.ModCacher) actor.Producer {
@@ -63,20 +76,29 @@ func (r *Runtime) Receive(c *actor.Context) {
 		// TODO: send metrics about the runtime to the metric actor.
 		_ = time.Since(r.started)
 		c.Send(r.managerPID, &proto.RemoveRuntime{Key: r.deploymentID.String()})
-		r.runtime.Close()
+		if r.loop != nil {
+			r.loop.Close()
+		}
+		if r.runtime != nil {
+			r.runtime.Close()
+		}
 		// Releasing this mod will invalidate the cache for some reason.
 		// r.mod.Close(context.TODO())
 	case *proto.HTTPRequest:
 		slog.Info("runtime handling request", "request_id", msg.ID, "pid", c.PID())
 		// Refresh the keepAlive timer
 		r.repeat = c.SendRepeat(c.PID(), shutdown{}, runtimeKeepAlive)
-		if r.runtime == nil {
-			r.initialize(msg)
-		}
 		// In the ideal world we should ask the cluster for the PID of the manager we
 		// need to notify we are done invoking. Hollywood does not have that functionality
 		// yet. To fix this we have the PID of the manager in the request messsage.
 		r.managerPID = msg.ManagerPID
+		if r.runtime == nil {
+			if err := r.initialize(msg); err != nil {
+				slog.Warn("failed to initialize runtime", "err", err, "deployment", msg.DeploymentID)
+				respondError(c, http.StatusInternalServerError, "internal server error", msg.ID)
+				return
+			}
+		}
 		// Handle the HTTP request that is forwarded from the WASM server actor.
 		r.handleHTTPRequest(c, msg)
 	case shutdown:
@@ -85,43 +107,89 @@ func (r *Runtime) Receive(c *actor.Context) {
 }
 
 func (r *Runtime) initialize(msg *proto.HTTPRequest) error {
-	r.deploymentID = uuid.MustParse(msg.DeploymentID)
+	deploymentID, err := uuid.Parse(msg.DeploymentID)
+	if err != nil {
+		return err
+	}
+	r.deploymentID = deploymentID
+	run, deploy, err := loadRuntime(r.store, r.cache, r.deploymentID, msg, r.stdout)
+	if err != nil {
+		return err
+	}
+	r.runtime = run
+	r.env = deploy.Environment
+	if msg.Runtime == "js" || msg.Runtime == "python" {
+		r.script = deploy.Blob
+	}
+	return nil
+}
+
+// loadRuntime returns a new runtime for the given deployment that writes its
+// output to stdout. The runtime is configured by the given request.
+func loadRuntime(store storage.Store, cache storage.ModCacher, deploymentID uuid.UUID, req *proto.HTTPRequest, stdout io.Writer) (*runtime.Runtime, *types.Deployment, error) {
 	// TODO: this could be coming from a Redis cache instead of Postgres.
 	// Maybe only the blob. Not sure...
-	deploy, err := r.store.GetDeployment(r.deploymentID)
+	deploy, err := store.GetDeployment(deploymentID)
 	if err != nil {
-		return fmt.Errorf("runtime: could not find deployment (%s)", r.deploymentID)
+		return nil, nil, fmt.Errorf("runtime: could not find deployment (%s)", deploymentID)
 	}
 
-	modCache, ok := r.cache.Get(r.deploymentID)
+	modCache, ok := cache.Get(deploymentID)
 	if !ok {
-		slog.Warn("no cache hit", "endpoint", r.deploymentID)
+		slog.Warn("no cache hit", "endpoint", deploymentID)
 		modCache = wazero.NewCompilationCache()
 	}
 
 	args := runtime.Args{
 		Cache:        modCache,
 		DeploymentID: deploy.ID,
-		Engine:       msg.Runtime,
-		Stdout:       r.stdout,
+		Engine:       req.Runtime,
+		Stdout:       stdout,
+		Fetch: runtime.FetchConfig{
+			AllowedHosts: req.AllowedHosts,
+		},
+		KV: NewEndpointKV(store, deploy.EndpointID),
 	}
 
 	switch args.Engine {
 	case "js":
-		r.script = deploy.Blob
 		args.Blob = spidermonkey.WasmBlob
+	case "python":
+		args.Blob = cpython.WasmBlob
 	default:
 		args.Blob = deploy.Blob
 	}
 
 	run, err := runtime.New(context.Background(), args)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	r.runtime = run
-	r.cache.Put(deploy.ID, modCache)
+	cache.Put(deploy.ID, modCache)
 
-	return nil
+	return run, deploy, nil
+}
+
+// deploymentEnv returns the environment a deployment runs with. Deployments
+// with an environment snapshot run with that snapshot instead of the current
+// environment of the endpoint.
+func deploymentEnv(snapshot map[string]string, env map[string]string) map[string]string {
+	if snapshot != nil {
+		return snapshot
+	}
+	return env
+}
+
+// scriptArgs returns the arguments the module is invoked with to handle req.
+// Scripts of the js runtime run with the SDK, which hands them the request.
+// Scripts of the python runtime read the request from stdin with theirs.
+func scriptArgs(engine string, script []byte, req *proto.HTTPRequest, env map[string]string) ([]string, error) {
+	switch engine {
+	case "js":
+		return runtime.JSArgs(script, req, env)
+	case "python":
+		return runtime.PythonArgs(script), nil
+	}
+	return []string{}, nil
 }
 
 func (r *Runtime) handleHTTPRequest(ctx *actor.Context, msg *proto.HTTPRequest) {
@@ -133,19 +201,26 @@ func (r *Runtime) handleHTTPRequest(ctx *actor.Context, msg *proto.HTTPRequest)
 		return
 	}
 
-	args := []string{}
-	if msg.Runtime == "js" {
-		args = []string{"", "-e", string(r.script)}
+	env := deploymentEnv(r.env, msg.Env)
+	// The allowed hosts of the endpoint can change while the runtime is
+	// warm, hence they are resolved for every request.
+	r.runtime.SetAllowedHosts(msg.AllowedHosts)
+
+	args, err := scriptArgs(msg.Runtime, r.script, msg, env)
+	if err != nil {
+		slog.Warn("failed to pass the HTTP request to the script", "err", err)
+		respondError(ctx, http.StatusInternalServerError, "internal server error", msg.ID)
+		return
 	}
 
-	req := bytes.NewReader(b)
-	if err := r.runtime.Invoke(req, msg.Env, args...); err != nil {
+	stdout, err := r.invoke(msg.Runtime, b, env, args)
+	if err != nil {
 		slog.Warn("runtime invoke error", "err", err)
 		respondError(ctx, http.StatusInternalServerError, "internal server error", msg.ID)
 		return
 	}
 
-	logs, res, status, err := shared.ParseStdout(r.stdout)
+	logs, res, header, status, err := shared.ParseResponse(stdout)
 	if err != nil {
 		respondError(ctx, http.StatusInternalServerError, "invalid response", msg.ID)
 		return
@@ -154,6 +229,11 @@ func (r *Runtime) handleHTTPRequest(ctx *actor.Context, msg *proto.HTTPRequest)
 		Response:   []byte(res),
 		RequestID:  msg.ID,
 		StatusCode: int32(status),
+		Header:     shared.MakeProtoHeader(header),
+	}
+	// The logs of scheduled runs are kept in their run history.
+	if msg.Scheduled {
+		resp.Logs = logs
 	}
 
 	ctx.Respond(resp)
@@ -161,25 +241,74 @@ func (r *Runtime) handleHTTPRequest(ctx *actor.Context, msg *proto.HTTPRequest)
 
 	// only send metrics and logs when its a request on LIVE
 	if !msg.Preview {
+		endpointID, _ := uuid.Parse(msg.EndpointID)
 		metric := types.RequestMetric{
 			ID:           uuid.New(),
 			Duration:     time.Since(start),
 			DeploymentID: r.deploymentID,
-			// EndpointID:   deploy.EndpointID,
-			RequestURL: msg.URL,
-			StatusCode: status,
+			EndpointID:   endpointID,
+			RequestURL:   msg.URL,
+			StatusCode:   status,
+			CreatedAT:    time.Now(),
 		}
 		metricPID := ctx.Engine().Registry.GetPID(KindMetric, "1")
 		ctx.Send(metricPID, metric)
 
 		runtimeLogPID := ctx.Engine().Registry.GetPID(KindRuntimeLog, "1")
 		runtimeLog := types.RuntimeLogEvent{
-			Data: logs,
+			EndpointID:   endpointID,
+			DeploymentID: r.deploymentID,
+			RequestID:    msg.ID,
+			Data:         logs,
 		}
 		ctx.Send(runtimeLogPID, runtimeLog)
 	}
 }
 
+// invoke handles the encoded request and returns the output of the guest.
+// When instances are reused the request is served by the long-lived
+// instance of the deployment, otherwise by a new instance.
+func (r *Runtime) invoke(engine string, b []byte, env map[string]string, args []string) (io.Reader, error) {
+	// Scripts are passed with the arguments of every invocation, hence they
+	// need a new instance.
+	if config.Get().Runtime.ReuseInstances && !r.noLoop && len(args) == 0 {
+		out, err := r.invokeLoop(b, env)
+		if !errors.Is(err, runtime.ErrLoopUnsupported) {
+			return bytes.NewReader(out), err
+		}
+		slog.Info("guest does not serve requests in a loop", "deployment", r.deploymentID, "runtime", engine)
+		r.noLoop = true
+	}
+	if err := r.runtime.Invoke(bytes.NewReader(b), env, args...); err != nil {
+		return nil, err
+	}
+	return r.stdout, nil
+}
+
+// invokeLoop serves the request with the long-lived instance, which is
+// started with the first request and restarted when the environment
+// changes or the instance crashed.
+func (r *Runtime) invokeLoop(b []byte, env map[string]string) ([]byte, error) {
+	if r.loop != nil && !maps.Equal(r.loopEnv, env) {
+		r.loop.Close()
+		r.loop = nil
+	}
+	if r.loop == nil {
+		loop, err := r.runtime.Loop(env)
+		if err != nil {
+			return nil, err
+		}
+		r.loop = loop
+		r.loopEnv = env
+	}
+	out, err := r.loop.Invoke(b)
+	if err != nil {
+		r.loop.Close()
+		r.loop = nil
+	}
+	return out, err
+}
+
 func respondError(ctx *actor.Context, code int32, msg string, id string) {
 	ctx.Respond(&proto.HTTPResponse{
 		Response:   []byte(msg),
@@ -1,16 +1,28 @@
 package actrs
 
 import (
+	"log/slog"
+	"time"
+
 	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/raptor/internal/storage"
 	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
 )
 
 const KindRuntimeLog = "runtime_log"
 
-type RuntimeLog struct{}
+// RuntimeLog stores the logs of the requests that are made on LIVE.
+type RuntimeLog struct {
+	store storage.MetricStore
+}
 
-func NewRuntimeLog() actor.Receiver {
-	return &RuntimeLog{}
+func NewRuntimeLog(store storage.MetricStore) actor.Producer {
+	return func() actor.Receiver {
+		return &RuntimeLog{
+			store: store,
+		}
+	}
 }
 
 func (rl *RuntimeLog) Receive(c *actor.Context) {
@@ -18,6 +30,20 @@ func (rl *RuntimeLog) Receive(c *actor.Context) {
 	case actor.Started:
 	case actor.Stopped:
 	case types.RuntimeLogEvent:
-		_ = msg
+		// Requests that did not log anything are not stored.
+		if len(msg.Data) == 0 {
+			return
+		}
+		log := &types.RuntimeLog{
+			ID:           uuid.New(),
+			EndpointID:   msg.EndpointID,
+			DeploymentID: msg.DeploymentID,
+			RequestID:    msg.RequestID,
+			Data:         string(msg.Data),
+			CreatedAT:    time.Now(),
+		}
+		if err := rl.store.CreateRuntimeLog(log); err != nil {
+			slog.Warn("failed to store runtime log", "err", err, "endpoint", msg.EndpointID)
+		}
 	}
 }
@@ -1,6 +1,9 @@
 package actrs
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/anthdm/hollywood/actor"
 	"github.com/anthdm/hollywood/cluster"
 	"github.com/anthdm/raptor/proto"
@@ -46,3 +49,27 @@ func (rm *RuntimeManager) Receive(c *actor.Context) {
 	case actor.Initialized:
 	}
 }
+
+// invokeRuntime dispatches the given request to a runtime of the manager and
+// waits for the response. It blocks, hence it should not be called from
+// within the receive loop of an actor.
+func invokeRuntime(engine *actor.Engine, managerPID *actor.PID, req *proto.HTTPRequest, timeout time.Duration) (*proto.HTTPResponse, error) {
+	req.ManagerPID = managerPID
+	res, err := engine.Request(managerPID, requestRuntime{key: req.DeploymentID}, time.Second).Result()
+	if err != nil {
+		return nil, fmt.Errorf("runtime manager did not respond: %s", err)
+	}
+	pid, ok := res.(*actor.PID)
+	if !ok || pid == nil {
+		return nil, fmt.Errorf("runtime manager responded with a non *actor.PID")
+	}
+	res, err = engine.Request(pid, req, timeout).Result()
+	if err != nil {
+		return nil, fmt.Errorf("runtime did not respond: %s", err)
+	}
+	resp, ok := res.(*proto.HTTPResponse)
+	if !ok {
+		return nil, fmt.Errorf("runtime responded with a non *proto.HTTPResponse")
+	}
+	return resp, nil
+}
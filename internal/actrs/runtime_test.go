@@ -0,0 +1,86 @@
+package actrs
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/anthdm/raptor/proto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeRespondsWhenItFailsToLoad(t *testing.T) {
+	store := storage.NewMemoryStore()
+	endpoint := types.NewEndpoint("My endpoint", "go", nil)
+	require.Nil(t, store.CreateEndpoint(endpoint))
+	deploy := types.NewDeployment(endpoint, []byte("not a module"))
+	require.Nil(t, store.CreateDeployment(deploy))
+
+	engine, err := actor.NewEngine(&actor.EngineConfig{})
+	require.Nil(t, err)
+	pid := engine.Spawn(NewRuntime(store, storage.NewDefaultModCache()), KindRuntime, actor.WithID(uuid.NewString()))
+
+	for _, id := range []string{deploy.ID.String(), "invalid"} {
+		req := &proto.HTTPRequest{
+			ID:           uuid.NewString(),
+			DeploymentID: id,
+			Runtime:      "go",
+			ManagerPID:   actor.NewPID("local", "manager"),
+		}
+		res, err := engine.Request(pid, req, time.Second).Result()
+		require.Nil(t, err)
+		resp, ok := res.(*proto.HTTPResponse)
+		require.True(t, ok)
+		require.Equal(t, int32(http.StatusInternalServerError), resp.StatusCode)
+		require.Equal(t, req.ID, resp.RequestID)
+	}
+	engine.Poison(pid).Wait()
+}
+
+func TestRuntimeRespondsWithHeaders(t *testing.T) {
+	b, err := os.ReadFile("../_testdata/helloworld.wasm")
+	require.Nil(t, err)
+	store := storage.NewMemoryStore()
+	endpoint := types.NewEndpoint("My endpoint", "go", nil)
+	require.Nil(t, store.CreateEndpoint(endpoint))
+	deploy := types.NewDeployment(endpoint, b)
+	require.Nil(t, store.CreateDeployment(deploy))
+
+	engine, err := actor.NewEngine(&actor.EngineConfig{})
+	require.Nil(t, err)
+	pid := engine.Spawn(NewRuntime(store, storage.NewDefaultModCache()), KindRuntime, actor.WithID(uuid.NewString()))
+
+	req := &proto.HTTPRequest{
+		ID:           uuid.NewString(),
+		EndpointID:   endpoint.ID.String(),
+		DeploymentID: deploy.ID.String(),
+		Runtime:      "go",
+		Method:       "GET",
+		URL:          "/",
+		Preview:      true,
+		ManagerPID:   actor.NewPID("local", "manager"),
+	}
+	res, err := engine.Request(pid, req, 10*time.Second).Result()
+	require.Nil(t, err)
+	resp, ok := res.(*proto.HTTPResponse)
+	require.True(t, ok)
+	require.Equal(t, int32(http.StatusOK), resp.StatusCode)
+	require.Equal(t, "Hello world!", string(resp.Response))
+	require.Equal(t, http.Header{"Cache-Control": {"max-age=60"}}, shared.ResponseHeader(resp.Header))
+	// The runtime stops itself once it is idle for runtimeKeepAlive.
+}
+
+func TestDeploymentEnv(t *testing.T) {
+	env := map[string]string{"FOO": "current", "BAR": "bar"}
+	snapshot := map[string]string{"FOO": "snapshot"}
+
+	// The snapshot replaces the environment as a whole.
+	require.Equal(t, snapshot, deploymentEnv(snapshot, env))
+	require.Equal(t, env, deploymentEnv(nil, env))
+}
@@ -0,0 +1,133 @@
+package actrs
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/anthdm/raptor/proto"
+	"github.com/google/uuid"
+)
+
+const KindScheduler = "scheduler"
+
+// ScheduleHeader is the header that holds the id of the schedule in
+// scheduled requests.
+const ScheduleHeader = "X-Raptor-Schedule"
+
+var (
+	// schedulerInterval is the interval at which the scheduler checks for
+	// due schedules.
+	schedulerInterval = time.Second * 10
+	// scheduledRunTimeout is the time a scheduled run may take.
+	scheduledRunTimeout = time.Minute
+	// maxScheduleRunLogs is the number of bytes of logs that are kept per run.
+	maxScheduleRunLogs = 64 << 10
+)
+
+type schedulerTick struct{}
+
+// Scheduler is an actor that invokes the LIVE deployments of endpoints on
+// their cron schedules. Runs are claimed in the store, hence multiple
+// schedulers can run in the cluster without invoking a schedule twice.
+type Scheduler struct {
+	store             storage.Store
+	runtimeManagerPID *actor.PID
+	repeat            actor.SendRepeater
+}
+
+func NewScheduler(store storage.Store) actor.Producer {
+	return func() actor.Receiver {
+		return &Scheduler{
+			store: store,
+		}
+	}
+}
+
+func (s *Scheduler) Receive(c *actor.Context) {
+	switch c.Message().(type) {
+	case actor.Started:
+		s.runtimeManagerPID = c.Engine().Registry.GetPID(KindRuntimeManager, "1")
+		s.repeat = c.SendRepeat(c.PID(), schedulerTick{}, schedulerInterval)
+	case actor.Stopped:
+		s.repeat.Stop()
+	case schedulerTick:
+		s.runDue(c.Engine(), time.Now().UTC())
+	}
+}
+
+func (s *Scheduler) runDue(engine *actor.Engine, now time.Time) {
+	schedules, err := s.store.GetDueSchedules(now)
+	if err != nil {
+		slog.Warn("failed to get due schedules", "err", err)
+		return
+	}
+	for _, schedule := range schedules {
+		// Runs that were missed, e.g. while no scheduler was running, are
+		// made up for with a single run.
+		next, err := schedule.Next(now)
+		if err != nil {
+			slog.Warn("invalid schedule", "err", err, "schedule_id", schedule.ID)
+			continue
+		}
+		claimed, err := s.store.ClaimSchedule(schedule.ID, schedule.NextRunAT, next)
+		if err != nil {
+			slog.Warn("failed to claim schedule", "err", err, "schedule_id", schedule.ID)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		go s.run(engine, schedule)
+	}
+}
+
+func (s *Scheduler) run(engine *actor.Engine, schedule *types.Schedule) {
+	run := &types.ScheduleRun{
+		ID:         uuid.New(),
+		ScheduleID: schedule.ID,
+		StartedAT:  time.Now().UTC(),
+	}
+	resp, err := s.invoke(engine, schedule, run)
+	run.Duration = time.Since(run.StartedAT)
+	if err != nil {
+		run.Error = err.Error()
+	} else {
+		run.StatusCode = int(resp.StatusCode)
+		logs := resp.Logs
+		if len(logs) > maxScheduleRunLogs {
+			logs = logs[len(logs)-maxScheduleRunLogs:]
+		}
+		run.Logs = strings.ToValidUTF8(string(logs), "")
+	}
+	if err := s.store.CreateScheduleRun(run); err != nil {
+		slog.Warn("failed to store schedule run", "err", err, "schedule_id", schedule.ID)
+	}
+	slog.Info("scheduled run", "schedule_id", schedule.ID, "status", run.StatusCode, "err", run.Error)
+}
+
+// invoke dispatches a request for the given schedule through the runtime
+// manager and waits for the response.
+func (s *Scheduler) invoke(engine *actor.Engine, schedule *types.Schedule, run *types.ScheduleRun) (*proto.HTTPResponse, error) {
+	endpoint, err := s.store.GetEndpoint(schedule.EndpointID)
+	if err != nil {
+		return nil, err
+	}
+	req := &proto.HTTPRequest{
+		ID:     uuid.NewString(),
+		Method: schedule.Method,
+		URL:    schedule.Path,
+		Header: map[string]*proto.HeaderFields{
+			ScheduleHeader: {Fields: []string{schedule.ID.String()}},
+		},
+		Scheduled: true,
+	}
+	if err := makeLiveRequest(req, endpoint); err != nil {
+		return nil, err
+	}
+	run.DeploymentID = endpoint.ActiveDeploymentID
+	return invokeRuntime(engine, s.runtimeManagerPID, req, scheduledRunTimeout)
+}
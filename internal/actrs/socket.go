@@ -0,0 +1,238 @@
+package actrs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/raptor/internal/runtime"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/proto"
+	"github.com/google/uuid"
+
+	prot "google.golang.org/protobuf/proto"
+)
+
+const KindSocket = "socket"
+
+// socketCloseTimeout is the time a guest has to exit after its connection was
+// closed before it is killed.
+var socketCloseTimeout = time.Second * 5
+
+type socketExited struct {
+	err error
+}
+
+// Socket is an actor that bridges a single WebSocket connection to a
+// long-lived instance of a deployment. Frames are exchanged with the guest
+// over its stdin and stdout.
+type Socket struct {
+	store   storage.Store
+	cache   storage.ModCacher
+	connID  string
+	connPID *actor.PID
+	runtime *runtime.Runtime
+	cancel  context.CancelFunc
+	frames  chan shared.Frame
+	closed  bool
+}
+
+func NewSocket(store storage.Store, cache storage.ModCacher) actor.Producer {
+	return func() actor.Receiver {
+		return &Socket{
+			store:  store,
+			cache:  cache,
+			frames: make(chan shared.Frame, 64),
+		}
+	}
+}
+
+func (s *Socket) Receive(c *actor.Context) {
+	switch msg := c.Message().(type) {
+	case actor.Started:
+	case actor.Stopped:
+		s.close(c)
+		if s.cancel != nil {
+			s.cancel()
+		}
+		if s.runtime != nil {
+			s.runtime.Close()
+		}
+	case *proto.WebSocketOpen:
+		s.connID = msg.ConnectionID
+		s.connPID = msg.ConnPID
+		if err := s.start(c, msg.Request); err != nil {
+			slog.Warn("failed to start socket runtime", "err", err, "connection_id", s.connID)
+			c.Send(s.connPID, &proto.WebSocketClose{
+				ConnectionID: s.connID,
+				Reason:       "internal server error",
+				Code:         closeInternalError,
+			})
+			c.Engine().Poison(c.PID())
+		}
+	case *proto.WebSocketFrame:
+		frameType := shared.FrameText
+		if msg.Binary {
+			frameType = shared.FrameBinary
+		}
+		s.send(c, shared.Frame{Type: frameType, Data: msg.Data})
+	case *proto.WebSocketClose:
+		s.close(c)
+		s.shutdownLater(c)
+	case socketExited:
+		if msg.err != nil {
+			slog.Warn("socket runtime exited", "err", msg.err, "connection_id", s.connID)
+		}
+		c.Send(s.connPID, &proto.WebSocketClose{ConnectionID: s.connID})
+		c.Engine().Poison(c.PID())
+	case shutdown:
+		c.Engine().Poison(c.PID())
+	}
+}
+
+func (s *Socket) start(c *actor.Context, req *proto.HTTPRequest) error {
+	deploymentID, err := uuid.Parse(req.DeploymentID)
+	if err != nil {
+		return err
+	}
+	b, err := prot.Marshal(req)
+	if err != nil {
+		return err
+	}
+	out := &socketWriter{
+		engine:  c.Engine(),
+		connID:  s.connID,
+		connPID: s.connPID,
+	}
+	run, deploy, err := loadRuntime(s.store, s.cache, deploymentID, req, out)
+	if err != nil {
+		return err
+	}
+	s.runtime = run
+
+	// The socket runs with the same environment as the requests of the
+	// deployment, plus the mode.
+	deployEnv := deploymentEnv(deploy.Environment, req.Env)
+	env := make(map[string]string, len(deployEnv)+1)
+	for k, v := range deployEnv {
+		env[k] = v
+	}
+	env[shared.ModeEnv] = shared.ModeWebSocket
+	args, err := scriptArgs(req.Runtime, deploy.Blob, req, env)
+	if err != nil {
+		return err
+	}
+
+	stdin, stdinWriter := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	// The frames are written to stdin in their own goroutine, because the
+	// writes block until the guest reads them.
+	go func() {
+		defer stdinWriter.Close()
+		for frame := range s.frames {
+			if err := shared.WriteFrame(stdinWriter, frame); err != nil {
+				return
+			}
+		}
+	}()
+	s.send(c, shared.Frame{Type: shared.FrameOpen, Data: b})
+
+	pid := c.PID()
+	go func() {
+		err := run.Serve(ctx, stdin, out, env, args...)
+		// Unblock the goroutine writing the frames.
+		stdin.Close()
+		c.Engine().Send(pid, socketExited{err: err})
+	}()
+	return nil
+}
+
+// send queues the frame to be written to stdin of the guest. Guests that do
+// not read their frames fast enough would lose messages, hence their
+// connection is closed with a policy violation instead.
+func (s *Socket) send(c *actor.Context, frame shared.Frame) {
+	if s.closed {
+		return
+	}
+	select {
+	case s.frames <- frame:
+	default:
+		slog.Warn("socket frames overflowed, guest is not reading", "connection_id", s.connID)
+		c.Send(s.connPID, &proto.WebSocketClose{
+			ConnectionID: s.connID,
+			Reason:       "guest is not reading messages",
+			Code:         closePolicyViolation,
+		})
+		// The queued frames are still written, then stdin is closed.
+		s.closed = true
+		close(s.frames)
+		s.shutdownLater(c)
+	}
+}
+
+// close closes stdin of the guest after sending it the close frame.
+func (s *Socket) close(c *actor.Context) {
+	if s.closed {
+		return
+	}
+	s.send(c, shared.Frame{Type: shared.FrameClose})
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.frames)
+}
+
+// shutdownLater gives the guest some time to exit on its own before the
+// socket is shut down.
+func (s *Socket) shutdownLater(c *actor.Context) {
+	pid, engine := c.PID(), c.Engine()
+	time.AfterFunc(socketCloseTimeout, func() {
+		engine.Send(pid, shutdown{})
+	})
+}
+
+// socketWriter parses the frames the guest writes to stdout and sends them to
+// the connection on the ingress.
+type socketWriter struct {
+	engine  *actor.Engine
+	connID  string
+	connPID *actor.PID
+	buf     bytes.Buffer
+}
+
+func (w *socketWriter) Write(b []byte) (int, error) {
+	w.buf.Write(b)
+	for {
+		frame, err := shared.ReadFrame(bytes.NewReader(w.buf.Bytes()))
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// Wait for the rest of the frame.
+			return len(b), nil
+		}
+		if err != nil {
+			w.buf.Reset()
+			return 0, err
+		}
+		w.buf.Next(shared.FrameHeaderLen + len(frame.Data))
+		switch frame.Type {
+		case shared.FrameText, shared.FrameBinary:
+			w.engine.Send(w.connPID, &proto.WebSocketFrame{
+				ConnectionID: w.connID,
+				Data:         frame.Data,
+				Binary:       frame.Type == shared.FrameBinary,
+			})
+		case shared.FrameClose:
+			w.engine.Send(w.connPID, &proto.WebSocketClose{
+				ConnectionID: w.connID,
+				Reason:       string(frame.Data),
+			})
+		}
+	}
+}
@@ -1,22 +1,44 @@
 package actrs
 
 import (
+	"encoding/json"
+	"fmt"
 	"log"
 	"log/slog"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/anthdm/hollywood/actor"
 	"github.com/anthdm/hollywood/cluster"
+	"github.com/anthdm/raptor/internal/assets"
+	"github.com/anthdm/raptor/internal/certs"
+	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/httpcache"
 	"github.com/anthdm/raptor/internal/shared"
 	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
 	"github.com/anthdm/raptor/proto"
 	"github.com/google/uuid"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"golang.org/x/net/websocket"
 )
 
 const KindWasmServer = "wasm_server"
 
+// hostCacheTTL is the duration for which the endpoint a hostname routes to is cached.
+var hostCacheTTL = time.Second * 30
+
+// hostCacheSize is the number of hostnames that route to an endpoint which
+// are cached, and hostMissCacheSize the number of the ones that do not.
+var (
+	hostCacheSize     = 4096
+	hostMissCacheSize = 256
+)
+
 type requestWithResponse struct {
 	request  *proto.HTTPRequest
 	response chan *proto.HTTPResponse
@@ -32,6 +54,7 @@ func newRequestWithResponse(request *proto.HTTPRequest) requestWithResponse {
 // WasmServer is an HTTP server that will proxy and route the request to the corresponding function.
 type WasmServer struct {
 	server            *http.Server
+	tlsServer         *http.Server
 	self              *actor.PID
 	store             storage.Store
 	metricStore       storage.MetricStore
@@ -39,10 +62,16 @@ type WasmServer struct {
 	cluster           *cluster.Cluster
 	responses         map[string]chan *proto.HTTPResponse
 	runtimeManagerPID *actor.PID
+	hosts             *hostCache
+	assets            *assetCache
+	// httpCache caches the responses of LIVE endpoints, it is nil when
+	// caching is disabled.
+	httpCache *httpcache.Cache
 }
 
 // NewWasmServer return a new wasm server given a storage and a mod cache.
-func NewWasmServer(addr string, cluster *cluster.Cluster, store storage.Store, metricStore storage.MetricStore, cache storage.ModCacher) actor.Producer {
+// If a certificate manager is given, the server also serves HTTPS on tlsAddr.
+func NewWasmServer(addr string, cluster *cluster.Cluster, store storage.Store, metricStore storage.MetricStore, cache storage.ModCacher, tlsAddr string, certManager *certs.Manager) actor.Producer {
 	return func() actor.Receiver {
 		s := &WasmServer{
 			store:             store,
@@ -51,11 +80,27 @@ func NewWasmServer(addr string, cluster *cluster.Cluster, store storage.Store, m
 			cluster:           cluster,
 			responses:         make(map[string]chan *proto.HTTPResponse),
 			runtimeManagerPID: cluster.Engine().Registry.GetPID(KindRuntimeManager, "1"),
+			hosts:             newHostCache(hostCacheTTL, hostCacheSize, hostMissCacheSize),
+			assets:            newAssetCache(assetCacheSize),
+		}
+		if size := config.Get().Cache.MaxSizeMB; size > 0 {
+			s.httpCache = httpcache.New(int64(size) << 20)
 		}
 		server := &http.Server{
-			Handler: s,
+			// Allow HTTP/2 without TLS (h2c), HTTP/2 over TLS is negotiated
+			// by the TLS server.
+			Handler: h2c.NewHandler(s, &http2.Server{}),
 			Addr:    addr,
 		}
+		if certManager != nil {
+			// The plain HTTP server answers the ACME challenges.
+			server.Handler = certManager.HTTPHandler(server.Handler)
+			s.tlsServer = &http.Server{
+				Handler:   s,
+				Addr:      tlsAddr,
+				TLSConfig: certManager.TLSConfig(),
+			}
+		}
 		s.server = server
 		return s
 	}
@@ -90,6 +135,12 @@ func (s *WasmServer) initialize(c *actor.Context) {
 	go func() {
 		log.Fatal(s.server.ListenAndServe())
 	}()
+	if s.tlsServer != nil {
+		go func() {
+			// The certificates are provided by the TLS config.
+			log.Fatal(s.tlsServer.ListenAndServeTLS("", ""))
+		}()
+	}
 }
 
 // NOTE: There could be a case where we do not get a response in time, hence
@@ -110,8 +161,33 @@ func (s *WasmServer) requestRuntime(c *actor.Context, key string) *actor.PID {
 	return pid
 }
 
-// TODO(anthdm): Handle the favicon.ico
 func (s *WasmServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestID := uuid.NewString()
+	r.Header.Set("x-request-id", requestID)
+
+	// Requests on custom domains and app subdomains are routed by their host
+	// and are always served LIVE.
+	if endpointID, ok := s.endpointFromHost(r.Host); ok {
+		req, err := shared.MakeProtoRequest(requestID, r)
+		if err != nil {
+			writeResponse(w, http.StatusInternalServerError, []byte(err.Error()))
+			return
+		}
+		// The path is not prefixed with /live/<endpoint> hence we keep all of it.
+		req.URL = shared.WithQuery(r.URL.Path, r.URL)
+		endpoint, err := s.store.GetEndpoint(endpointID)
+		if err != nil {
+			writeResponse(w, http.StatusNotFound, []byte(err.Error()))
+			return
+		}
+		if err := makeLiveRequest(req, endpoint); err != nil {
+			writeResponse(w, http.StatusNotFound, []byte(err.Error()))
+			return
+		}
+		s.serveRequest(w, r, req, endpoint)
+		return
+	}
+
 	path := strings.TrimPrefix(r.URL.Path, "/")
 	path = strings.TrimSuffix(path, "/")
 	pathParts := strings.Split(path, "/")
@@ -120,44 +196,33 @@ func (s *WasmServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		writeResponse(w, http.StatusBadRequest, []byte("invalid request url"))
 		return
 	}
+	if pathParts[0] == "async" {
+		s.serveAsync(w, r, requestID, pathParts[1])
+		return
+	}
 	if pathParts[0] != "live" && pathParts[0] != "preview" {
 		writeResponse(w, http.StatusBadRequest, []byte("invalid request url"))
 		return
 	}
 
-	requestID := uuid.NewString()
-	r.Header.Set("x-request-id", requestID)
 	req, err := shared.MakeProtoRequest(requestID, r)
 	if err != nil {
 		writeResponse(w, http.StatusInternalServerError, []byte(err.Error()))
 		return
 	}
 
-	if err != nil {
-		writeResponse(w, http.StatusInternalServerError, []byte(err.Error()))
-		return
-	}
+	var endpoint *types.Endpoint
 	if pathParts[0] == "live" {
-		endpointID, err := uuid.Parse(pathParts[1])
-		if err != nil {
-			writeResponse(w, http.StatusBadRequest, []byte(err.Error()))
-			return
-		}
-		endpoint, err := s.store.GetEndpoint(endpointID)
+		// LIVE endpoints can be reached by their id or their slug.
+		endpoint, err = s.getEndpoint(pathParts[1])
 		if err != nil {
 			writeResponse(w, http.StatusNotFound, []byte(err.Error()))
 			return
 		}
-		if !endpoint.HasActiveDeploy() {
-			writeResponse(w, http.StatusNotFound, []byte("endpoint does not have any published deploy"))
+		if err := makeLiveRequest(req, endpoint); err != nil {
+			writeResponse(w, http.StatusNotFound, []byte(err.Error()))
 			return
 		}
-		req.Runtime = endpoint.Runtime
-		req.EndpointID = endpointID.String()
-		// When serving LIVE endpoints we use the active deployment id.
-		req.DeploymentID = endpoint.ActiveDeploymentID.String()
-		req.Env = endpoint.Environment
-		req.Preview = false
 	}
 	if pathParts[0] == "preview" {
 		deployID, err := uuid.Parse(pathParts[1])
@@ -170,7 +235,7 @@ func (s *WasmServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			writeResponse(w, http.StatusBadRequest, []byte(err.Error()))
 			return
 		}
-		endpoint, err := s.store.GetEndpoint(deploy.EndpointID)
+		endpoint, err = s.store.GetEndpoint(deploy.EndpointID)
 		if err != nil {
 			writeResponse(w, http.StatusBadRequest, []byte(err.Error()))
 			return
@@ -181,19 +246,236 @@ func (s *WasmServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		// request.
 		req.DeploymentID = deploy.ID.String()
 		req.Env = endpoint.Environment
+		req.AllowedHosts = endpoint.AllowedHosts
 		req.Preview = true
 	}
 
+	s.serveRequest(w, r, req, endpoint)
+}
+
+func (s *WasmServer) serveRequest(w http.ResponseWriter, r *http.Request, req *proto.HTTPRequest, endpoint *types.Endpoint) {
+	if isWebSocketUpgrade(r) {
+		s.serveWebSocket(w, r, req)
+		return
+	}
+	if s.serveAsset(w, r, req) {
+		return
+	}
+	// Responses of LIVE endpoints are cached per endpoint at its cache
+	// generation, which changes when a deployment is published or the cache
+	// is purged.
+	cacheable := s.httpCache != nil && !req.Preview
+	cacheKey := req.DeploymentID + " " + r.Host + " " + req.URL
+	if cacheable {
+		if cached, age, ok := s.httpCache.Get(endpoint.ID.String(), endpoint.CacheGeneration, cacheKey, r); ok {
+			writeCachedResponse(w, cached, age)
+			return
+		}
+	}
+
 	reqres := newRequestWithResponse(req)
 	s.cluster.Engine().Send(s.self, reqres)
 
 	resp := <-reqres.response
 
+	header := shared.ResponseHeader(resp.Header)
+	if cacheable {
+		s.httpCache.Put(endpoint.ID.String(), endpoint.CacheGeneration, cacheKey, r, &httpcache.Response{
+			StatusCode: int(resp.StatusCode),
+			Header:     header,
+			Body:       resp.Response,
+		})
+	}
+	for name, values := range header {
+		w.Header()[name] = values
+	}
 	w.WriteHeader(int(resp.StatusCode))
 	w.Write(resp.Response)
 }
 
+// writeCachedResponse writes the response that was cached age ago.
+func writeCachedResponse(w http.ResponseWriter, resp *httpcache.Response, age time.Duration) {
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Age", strconv.Itoa(int(age.Seconds())))
+	w.WriteHeader(resp.StatusCode)
+	w.Write(resp.Body)
+}
+
+// serveAsset serves the static asset of the deployment of the request that
+// matches its path, if any, and reports whether it did. Requests that do not
+// match an asset are served by the function.
+func (s *WasmServer) serveAsset(w http.ResponseWriter, r *http.Request, req *proto.HTTPRequest) bool {
+	// Only GET and HEAD requests are served from assets, so others do not
+	// need to look them up.
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	deployID, err := uuid.Parse(req.DeploymentID)
+	if err != nil {
+		return false
+	}
+	bundle, ok := s.assets.get(deployID)
+	if !ok {
+		deploy, err := s.store.GetDeployment(deployID)
+		if err != nil {
+			return false
+		}
+		if len(deploy.Assets) > 0 {
+			bundle, err = assets.Parse(deploy.Assets)
+			if err != nil {
+				slog.Warn("failed to parse the assets of the deployment", "deployment", deployID, "err", err)
+			}
+		}
+		s.assets.put(deployID, bundle)
+	}
+	if bundle == nil {
+		return false
+	}
+	path, _, _ := strings.Cut(req.URL, "?")
+	return bundle.Serve(w, r, path)
+}
+
+// serveAsync enqueues the request as an invocation of the LIVE endpoint and
+// responds with the id and the token of the invocation, which can be polled
+// with a GET request on /async/<invocation> with the token in the invocation
+// token header.
+func (s *WasmServer) serveAsync(w http.ResponseWriter, r *http.Request, requestID string, id string) {
+	if r.Method == http.MethodGet {
+		invocationID, err := uuid.Parse(id)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, []byte(err.Error()))
+			return
+		}
+		// Invocations with another token are not found, so their ids do not
+		// tell whether they exist.
+		invocation, err := s.store.GetInvocation(invocationID)
+		if err != nil || !invocation.ValidToken(r.Header.Get(InvocationTokenHeader)) {
+			writeResponse(w, http.StatusNotFound, []byte("invocation not found"))
+			return
+		}
+		writeJSON(w, http.StatusOK, invocation)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeResponse(w, http.StatusMethodNotAllowed, []byte("method not allowed"))
+		return
+	}
+	endpoint, err := s.getEndpoint(id)
+	if err != nil {
+		writeResponse(w, http.StatusNotFound, []byte(err.Error()))
+		return
+	}
+	if !endpoint.HasActiveDeploy() {
+		writeResponse(w, http.StatusNotFound, []byte("endpoint does not have any published deploy"))
+		return
+	}
+	req, err := shared.MakeProtoRequest(requestID, r)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, []byte(err.Error()))
+		return
+	}
+	invocation, token, err := newInvocation(endpoint, r, req)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+	if err := s.store.CreateInvocation(invocation); err != nil {
+		writeResponse(w, http.StatusInternalServerError, []byte(err.Error()))
+		return
+	}
+	// Wake up the queue so the invocation does not wait for the next tick.
+	if pid := s.cluster.Engine().Registry.GetPID(KindQueue, "1"); pid != nil {
+		s.cluster.Engine().Send(pid, queueTick{})
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{
+		"id":     invocation.ID.String(),
+		"status": invocation.Status,
+		"token":  token,
+	})
+}
+
+// serveWebSocket upgrades the connection and bridges it to a long-lived
+// instance of the deployment until either side closes the connection.
+func (s *WasmServer) serveWebSocket(w http.ResponseWriter, r *http.Request, req *proto.HTTPRequest) {
+	server := websocket.Server{
+		// The origin is not checked here, the guest can check the Origin
+		// header of the request itself.
+		Handshake: nil,
+		Handler: func(conn *websocket.Conn) {
+			done := make(chan struct{})
+			producer := newWebSocketConn(conn, req, s.cluster, done)
+			s.cluster.Engine().Spawn(producer, KindWebSocketConn, actor.WithID(req.ID))
+			<-done
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// makeLiveRequest prepares the request to be served by the active deployment
+// of the given endpoint.
+func makeLiveRequest(req *proto.HTTPRequest, endpoint *types.Endpoint) error {
+	if !endpoint.HasActiveDeploy() {
+		return fmt.Errorf("endpoint does not have any published deploy")
+	}
+	req.Runtime = endpoint.Runtime
+	req.EndpointID = endpoint.ID.String()
+	// When serving LIVE endpoints we use the active deployment id.
+	req.DeploymentID = endpoint.ActiveDeploymentID.String()
+	req.Env = endpoint.Environment
+	req.AllowedHosts = endpoint.AllowedHosts
+	req.Preview = false
+	return nil
+}
+
+// getEndpoint returns the endpoint by the given id or slug.
+func (s *WasmServer) getEndpoint(idOrSlug string) (*types.Endpoint, error) {
+	if id, err := uuid.Parse(idOrSlug); err == nil {
+		return s.store.GetEndpoint(id)
+	}
+	return s.store.GetEndpointBySlug(idOrSlug)
+}
+
+// endpointFromHost returns the id of the endpoint the given host routes to,
+// either by a verified custom domain or by a <slug>.<appsDomain> subdomain.
+func (s *WasmServer) endpointFromHost(host string) (uuid.UUID, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = types.NormalizeHostname(host)
+	if len(host) == 0 {
+		return uuid.Nil, false
+	}
+	if endpointID, found, ok := s.hosts.get(host); ok {
+		return endpointID, found
+	}
+
+	endpointID, found := uuid.Nil, false
+	appsDomain := config.Get().AppsDomain
+	if len(appsDomain) > 0 && strings.HasSuffix(host, "."+appsDomain) {
+		slug := strings.TrimSuffix(host, "."+appsDomain)
+		if endpoint, err := s.store.GetEndpointBySlug(slug); err == nil {
+			endpointID, found = endpoint.ID, true
+		}
+	} else if domain, err := s.store.GetDomain(host); err == nil && domain.Verified {
+		endpointID, found = domain.EndpointID, true
+	}
+	s.hosts.put(host, endpointID, found)
+	return endpointID, found
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
 func writeResponse(w http.ResponseWriter, code int, b []byte) {
-	w.WriteHeader(http.StatusNotFound)
+	w.WriteHeader(code)
 	w.Write(b)
 }
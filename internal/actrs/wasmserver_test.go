@@ -0,0 +1,73 @@
+package actrs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestWasmServer(store storage.Store) *WasmServer {
+	return &WasmServer{
+		store:  store,
+		hosts:  newHostCache(hostCacheTTL, hostCacheSize, hostMissCacheSize),
+		assets: newAssetCache(assetCacheSize),
+	}
+}
+
+func TestServeAsyncStatus(t *testing.T) {
+	store := storage.NewMemoryStore()
+	endpoint := types.NewEndpoint("My endpoint", "go", nil)
+	endpoint.ActiveDeploymentID = uuid.New()
+	require.Nil(t, store.CreateEndpoint(endpoint))
+	s := newTestWasmServer(store)
+
+	req := httptest.NewRequest("PUT", "/async/"+endpoint.ID.String(), nil)
+	resp := httptest.NewRecorder()
+	s.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+
+	req = httptest.NewRequest("GET", "/async/invalid", nil)
+	resp = httptest.NewRecorder()
+	s.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	req = httptest.NewRequest("POST", "/async/"+endpoint.ID.String(), nil)
+	req.Header.Set(MaxAttemptsHeader, "100")
+	resp = httptest.NewRecorder()
+	s.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	req = httptest.NewRequest("POST", "/async/"+uuid.NewString(), nil)
+	resp = httptest.NewRecorder()
+	s.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestServeAsyncRequiresToken(t *testing.T) {
+	store := storage.NewMemoryStore()
+	endpoint := types.NewEndpoint("My endpoint", "go", nil)
+	require.Nil(t, store.CreateEndpoint(endpoint))
+	invocation := types.NewInvocation(endpoint, "GET", "/", nil, nil)
+	invocation.TokenHash = types.HashInvocationToken("secret")
+	require.Nil(t, store.CreateInvocation(invocation))
+	s := newTestWasmServer(store)
+
+	for token, code := range map[string]int{
+		"":       http.StatusNotFound,
+		"other":  http.StatusNotFound,
+		"secret": http.StatusOK,
+	} {
+		req := httptest.NewRequest("GET", "/async/"+invocation.ID.String(), nil)
+		if len(token) > 0 {
+			req.Header.Set(InvocationTokenHeader, token)
+		}
+		resp := httptest.NewRecorder()
+		s.ServeHTTP(resp, req)
+		require.Equal(t, code, resp.Code, token)
+	}
+}
@@ -0,0 +1,129 @@
+package actrs
+
+import (
+	"encoding/binary"
+	"log/slog"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/hollywood/cluster"
+	"github.com/anthdm/raptor/proto"
+	"golang.org/x/net/websocket"
+)
+
+const KindWebSocketConn = "websocket_conn"
+
+type connClosed struct{}
+
+// The status codes connections are closed with, see RFC 6455 section 7.4.1.
+const (
+	closePolicyViolation = 1008
+	closeInternalError   = 1011
+)
+
+// maxCloseReason is the maximum length of the reason of a close frame, whose
+// payload is limited to 125 bytes including the status code.
+const maxCloseReason = 123
+
+// frameCodec sends and receives WebSocket messages as *proto.WebSocketFrame
+// keeping track of whether the message is text or binary.
+var frameCodec = websocket.Codec{
+	Marshal: func(v any) ([]byte, byte, error) {
+		frame := v.(*proto.WebSocketFrame)
+		if frame.Binary {
+			return frame.Data, websocket.BinaryFrame, nil
+		}
+		return frame.Data, websocket.TextFrame, nil
+	},
+	Unmarshal: func(data []byte, payloadType byte, v any) error {
+		frame := v.(*proto.WebSocketFrame)
+		frame.Data = data
+		frame.Binary = payloadType == websocket.BinaryFrame
+		return nil
+	},
+}
+
+// WebSocketConn is an actor that lives on the ingress and owns a single
+// WebSocket connection of a client. It forwards the messages of the client to
+// a socket actor in the cluster and the messages of the guest to the client.
+type WebSocketConn struct {
+	conn      *websocket.Conn
+	request   *proto.HTTPRequest
+	cluster   *cluster.Cluster
+	socketPID *actor.PID
+	done      chan struct{}
+	// closed is set when the connection was closed with a status code.
+	closed bool
+}
+
+func newWebSocketConn(conn *websocket.Conn, request *proto.HTTPRequest, cluster *cluster.Cluster, done chan struct{}) actor.Producer {
+	return func() actor.Receiver {
+		return &WebSocketConn{
+			conn:    conn,
+			request: request,
+			cluster: cluster,
+			done:    done,
+		}
+	}
+}
+
+func (wc *WebSocketConn) Receive(c *actor.Context) {
+	switch msg := c.Message().(type) {
+	case actor.Started:
+		wc.socketPID = wc.cluster.Activate(KindSocket, cluster.NewActivationConfig())
+		c.Send(wc.socketPID, &proto.WebSocketOpen{
+			ConnectionID: wc.request.ID,
+			Request:      wc.request,
+			ConnPID:      c.PID(),
+		})
+		go wc.readLoop(c.Engine(), c.PID())
+	case actor.Stopped:
+		// Connections that were closed with a status code are closed by
+		// the server once the handler returns.
+		if !wc.closed {
+			wc.conn.Close()
+		}
+		close(wc.done)
+	case *proto.WebSocketFrame:
+		if err := frameCodec.Send(wc.conn, msg); err != nil {
+			slog.Warn("failed to write websocket message", "err", err, "connection_id", wc.request.ID)
+		}
+	case *proto.WebSocketClose:
+		// The guest or the socket closed the connection.
+		if msg.Code != 0 {
+			if err := writeClose(wc.conn, int(msg.Code), msg.Reason); err != nil {
+				slog.Warn("failed to close websocket", "err", err, "connection_id", wc.request.ID)
+			}
+			wc.closed = true
+		}
+		c.Engine().Poison(c.PID())
+	case connClosed:
+		// The client closed the connection.
+		c.Send(wc.socketPID, &proto.WebSocketClose{ConnectionID: wc.request.ID})
+		c.Engine().Poison(c.PID())
+	}
+}
+
+func (wc *WebSocketConn) readLoop(engine *actor.Engine, pid *actor.PID) {
+	for {
+		frame := &proto.WebSocketFrame{ConnectionID: wc.request.ID}
+		if err := frameCodec.Receive(wc.conn, frame); err != nil {
+			engine.Send(pid, connClosed{})
+			return
+		}
+		engine.Send(wc.socketPID, frame)
+	}
+}
+
+// writeClose sends a close frame with the given status code and reason.
+// websocket.Conn only closes with 1000, hence the frame is written as is.
+func writeClose(conn *websocket.Conn, code int, reason string) error {
+	if len(reason) > maxCloseReason {
+		reason = reason[:maxCloseReason]
+	}
+	msg := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(msg, uint16(code))
+	copy(msg[2:], reason)
+	conn.PayloadType = websocket.CloseFrame
+	_, err := conn.Write(msg)
+	return err
+}
@@ -0,0 +1,51 @@
+package actrs
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func TestWriteClose(t *testing.T) {
+	server := httptest.NewServer(websocket.Server{
+		Handler: func(conn *websocket.Conn) {
+			writeClose(conn, closePolicyViolation, strings.Repeat("x", 200))
+		},
+	})
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	require.Nil(t, err)
+	defer conn.Close()
+	_, err = io.WriteString(conn, "GET / HTTP/1.1\r\n"+
+		"Host: "+server.Listener.Addr().String()+"\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+	require.Nil(t, err)
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	// The close frame holds the status code and the truncated reason.
+	header := make([]byte, 2)
+	_, err = io.ReadFull(r, header)
+	require.Nil(t, err)
+	require.Equal(t, byte(0x80|websocket.CloseFrame), header[0])
+	require.Equal(t, 2+maxCloseReason, int(header[1]))
+	payload := make([]byte, header[1])
+	_, err = io.ReadFull(r, payload)
+	require.Nil(t, err)
+	require.Equal(t, closePolicyViolation, int(binary.BigEndian.Uint16(payload)))
+	require.Equal(t, strings.Repeat("x", maxCloseReason), string(payload[2:]))
+}
@@ -3,28 +3,81 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 var (
 	ErrDecodeRequestBody = errors.New("could not decode the request body")
 )
 
+// Machine-readable codes of the error responses. Unless a handler sets one,
+// the code follows from the status of the response.
+const (
+	codeBadRequest       = "bad_request"
+	codeInvalidRequest   = "invalid_request"
+	codeUnauthorized     = "unauthorized"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeConflict         = "conflict"
+	codeVersionConflict  = "version_conflict"
+	codeUnprocessable    = "unprocessable"
+	codeInternal         = "internal"
+)
+
 type errorResponse struct {
 	Error string `json:"error"`
+	Code  string `json:"code"`
+	// Details holds the fields that failed validation.
+	Details []fieldError `json:"details,omitempty"`
 }
 
 func ErrorResponse(err error) errorResponse {
-	return errorResponse{
+	resp := errorResponse{
 		Error: err.Error(),
 	}
+	var verr validationError
+	if errors.As(err, &verr) {
+		resp.Code = codeInvalidRequest
+		resp.Details = verr
+	}
+	return resp
+}
+
+// errorCode returns the code of an error response with the given status.
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return codeBadRequest
+	case http.StatusUnauthorized:
+		return codeUnauthorized
+	case http.StatusNotFound:
+		return codeNotFound
+	case http.StatusMethodNotAllowed:
+		return codeMethodNotAllowed
+	case http.StatusConflict:
+		return codeConflict
+	case http.StatusPreconditionFailed:
+		return codeVersionConflict
+	case http.StatusUnprocessableEntity:
+		return codeUnprocessable
+	}
+	return codeInternal
 }
 
 type apiHandler func(w http.ResponseWriter, r *http.Request) error
 
+// makeAPIHandler validates the request against the OpenAPI document before
+// it is passed to the handler.
 func makeAPIHandler(h apiHandler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if err := spec.validateRequest(r); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
 		if err := h(w, r); err != nil {
 			// todo
 			slog.Error("api handler error", "err", err)
@@ -33,7 +86,34 @@ func makeAPIHandler(h apiHandler) http.HandlerFunc {
 }
 
 func writeJSON(w http.ResponseWriter, status int, v any) error {
+	if resp, ok := v.(errorResponse); ok && len(resp.Code) == 0 {
+		resp.Code = errorCode(status)
+		v = resp
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	return json.NewEncoder(w).Encode(v)
 }
+
+func handleNotFound(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusNotFound, ErrorResponse(fmt.Errorf("route not found: %s %s", r.Method, r.URL.Path)))
+}
+
+func handleMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse(fmt.Errorf("method %s not allowed on %s", r.Method, r.URL.Path)))
+}
+
+// makeETag returns the ETag of a resource at the given version.
+func makeETag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// parseETag returns the version of a resource from the given ETag.
+func parseETag(etag string) (int, error) {
+	etag = strings.TrimPrefix(etag, "W/")
+	version, err := strconv.Atoi(strings.Trim(etag, `"`))
+	if err != nil {
+		return 0, fmt.Errorf("invalid etag: %s", etag)
+	}
+	return version, nil
+}
@@ -0,0 +1,400 @@
+package api
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// openAPIDocument is the OpenAPI 3 document of the API, served at
+// /openapi.json. Requests are validated against it before they reach their
+// handler.
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+var spec = mustLoadSpec(openAPIDocument)
+
+// openAPISpec holds the parts of the OpenAPI document that are needed to
+// validate requests.
+type openAPISpec struct {
+	Info struct {
+		Version string `json:"version"`
+	} `json:"info"`
+	// Paths maps the path of each route to its operations by lowercase method.
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Schemas    map[string]*schema    `json:"schemas"`
+		Parameters map[string]*parameter `json:"parameters"`
+	} `json:"components"`
+}
+
+type operation struct {
+	Parameters  []*parameter `json:"parameters"`
+	RequestBody *struct {
+		Required bool `json:"required"`
+		Content  map[string]struct {
+			Schema *schema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+type parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *schema `json:"schema"`
+}
+
+// schema is the subset of the schema object of OpenAPI 3.0 the document
+// uses.
+type schema struct {
+	Ref                  string                `json:"$ref"`
+	Type                 string                `json:"type"`
+	Format               string                `json:"format"`
+	Nullable             bool                  `json:"nullable"`
+	Enum                 []any                 `json:"enum"`
+	Properties           map[string]*schema    `json:"properties"`
+	Required             []string              `json:"required"`
+	AdditionalProperties *additionalProperties `json:"additionalProperties"`
+	Items                *schema               `json:"items"`
+	AllOf                []*schema             `json:"allOf"`
+	MinLength            *int                  `json:"minLength"`
+	MaxLength            *int                  `json:"maxLength"`
+	Minimum              *float64              `json:"minimum"`
+	Maximum              *float64              `json:"maximum"`
+	Pattern              string                `json:"pattern"`
+
+	pattern *regexp.Regexp
+}
+
+func (s *schema) UnmarshalJSON(b []byte) error {
+	type plain schema
+	if err := json.Unmarshal(b, (*plain)(s)); err != nil {
+		return err
+	}
+	if len(s.Pattern) > 0 {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return err
+		}
+		s.pattern = pattern
+	}
+	return nil
+}
+
+// additionalProperties is either a boolean or the schema of the properties
+// that are not listed.
+type additionalProperties struct {
+	allowed bool
+	schema  *schema
+}
+
+func (a *additionalProperties) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &a.allowed); err == nil {
+		return nil
+	}
+	a.allowed = true
+	return json.Unmarshal(b, &a.schema)
+}
+
+func mustLoadSpec(b []byte) *openAPISpec {
+	var spec openAPISpec
+	if err := json.Unmarshal(b, &spec); err != nil {
+		panic(fmt.Sprintf("invalid openapi document: %s", err))
+	}
+	return &spec
+}
+
+// operation returns the operation of the given method on the route with the
+// given chi pattern. A trailing wildcard is the {key} parameter.
+func (s *openAPISpec) operation(method, pattern string) *operation {
+	path := strings.TrimSuffix(pattern, "/*")
+	if len(path) < len(pattern) {
+		path += "/{key}"
+	}
+	return s.Paths[path][strings.ToLower(method)]
+}
+
+func (s *openAPISpec) resolveParameter(p *parameter) *parameter {
+	if name, ok := strings.CutPrefix(p.Ref, "#/components/parameters/"); ok {
+		return s.Components.Parameters[name]
+	}
+	return p
+}
+
+func (s *openAPISpec) resolveSchema(sc *schema) *schema {
+	if name, ok := strings.CutPrefix(sc.Ref, "#/components/schemas/"); ok {
+		return s.Components.Schemas[name]
+	}
+	return sc
+}
+
+// fieldError is a field of a request that does not match the OpenAPI
+// document, like body.name or query.limit.
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// validationError holds all the fields of a request that are invalid.
+type validationError []fieldError
+
+func (e validationError) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + " " + fe.Message
+	}
+	return "invalid request: " + strings.Join(msgs, ", ")
+}
+
+// validateRequest validates the path, the query and the JSON body of the
+// request against the operation of its route. The body is put back so the
+// handler can decode it.
+func (s *openAPISpec) validateRequest(r *http.Request) error {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return nil
+	}
+	op := s.operation(r.Method, rctx.RoutePattern())
+	if op == nil {
+		return nil
+	}
+	var errs validationError
+	for _, p := range op.Parameters {
+		p = s.resolveParameter(p)
+		var values []string
+		switch p.In {
+		case "path":
+			value := chi.URLParam(r, p.Name)
+			if len(value) == 0 {
+				value = chi.URLParam(r, "*")
+			}
+			values = []string{value}
+		case "query":
+			values = r.URL.Query()[p.Name]
+		default:
+			continue
+		}
+		field := p.In + "." + p.Name
+		if len(values) == 0 || (p.In == "path" && len(values[0]) == 0) {
+			if p.Required {
+				errs = append(errs, fieldError{field, "is required"})
+			}
+			continue
+		}
+		errs = s.validateParameter(p.Schema, values, field, errs)
+	}
+
+	if op.RequestBody != nil {
+		if content, ok := op.RequestBody.Content["application/json"]; ok {
+			b, err := io.ReadAll(r.Body)
+			r.Body.Close()
+			if err != nil {
+				return err
+			}
+			r.Body = io.NopCloser(bytes.NewReader(b))
+			errs = s.validateBody(content.Schema, op.RequestBody.Required, b, errs)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (s *openAPISpec) validateBody(sc *schema, required bool, b []byte, errs validationError) validationError {
+	if len(bytes.TrimSpace(b)) == 0 {
+		if required {
+			errs = append(errs, fieldError{"body", "is required"})
+		}
+		return errs
+	}
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return append(errs, fieldError{"body", "is not valid JSON: " + err.Error()})
+	}
+	if dec.More() {
+		return append(errs, fieldError{"body", "holds more than one JSON value"})
+	}
+	return s.validateValue(sc, v, "body", errs)
+}
+
+// validateParameter converts the values of a path or query parameter to the
+// type of its schema and validates them.
+func (s *openAPISpec) validateParameter(sc *schema, values []string, field string, errs validationError) validationError {
+	sc = s.resolveSchema(sc)
+	if sc.Type == "array" {
+		for i, value := range values {
+			errs = s.validateParameter(sc.Items, []string{value}, fmt.Sprintf("%s[%d]", field, i), errs)
+		}
+		return errs
+	}
+	value := values[0]
+	var v any = value
+	switch sc.Type {
+	case "integer":
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return append(errs, fieldError{field, "must be an integer"})
+		}
+		v = json.Number(value)
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return append(errs, fieldError{field, "must be a boolean"})
+		}
+		v = b
+	}
+	return s.validateValue(sc, v, field, errs)
+}
+
+func (s *openAPISpec) validateValue(sc *schema, v any, field string, errs validationError) validationError {
+	if sc == nil {
+		return errs
+	}
+	sc = s.resolveSchema(sc)
+	for _, sub := range sc.AllOf {
+		errs = s.validateValue(sub, v, field, errs)
+	}
+	if v == nil {
+		if !sc.Nullable && len(sc.Type) > 0 {
+			errs = append(errs, fieldError{field, "must not be null"})
+		}
+		return errs
+	}
+	switch sc.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return append(errs, fieldError{field, "must be an object"})
+		}
+		return s.validateObject(sc, obj, field, errs)
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return append(errs, fieldError{field, "must be an array"})
+		}
+		for i, item := range arr {
+			errs = s.validateValue(sc.Items, item, fmt.Sprintf("%s[%d]", field, i), errs)
+		}
+		return errs
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return append(errs, fieldError{field, "must be a string"})
+		}
+		return validateString(sc, str, field, errs)
+	case "integer", "number":
+		n, ok := v.(json.Number)
+		if !ok {
+			return append(errs, fieldError{field, "must be a " + sc.Type})
+		}
+		return validateNumber(sc, n, field, errs)
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return append(errs, fieldError{field, "must be a boolean"})
+		}
+	}
+	return errs
+}
+
+func (s *openAPISpec) validateObject(sc *schema, obj map[string]any, field string, errs validationError) validationError {
+	for _, name := range sc.Required {
+		if _, ok := obj[name]; !ok {
+			errs = append(errs, fieldError{field + "." + name, "is required"})
+		}
+	}
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := obj[name]
+		if prop, ok := sc.Properties[name]; ok {
+			errs = s.validateValue(prop, value, field+"."+name, errs)
+			continue
+		}
+		switch additional := sc.AdditionalProperties; {
+		case additional == nil:
+		case !additional.allowed:
+			errs = append(errs, fieldError{field + "." + name, "is not a known field"})
+		default:
+			errs = s.validateValue(additional.schema, value, field+"."+name, errs)
+		}
+	}
+	return errs
+}
+
+func validateString(sc *schema, str string, field string, errs validationError) validationError {
+	if len(sc.Enum) > 0 && !enumContains(sc.Enum, str) {
+		return append(errs, fieldError{field, fmt.Sprintf("must be one of %v", sc.Enum)})
+	}
+	n := utf8.RuneCountInString(str)
+	if sc.MinLength != nil && n < *sc.MinLength {
+		errs = append(errs, fieldError{field, fmt.Sprintf("must be at least %d characters long", *sc.MinLength)})
+	}
+	if sc.MaxLength != nil && n > *sc.MaxLength {
+		errs = append(errs, fieldError{field, fmt.Sprintf("must be at most %d characters long", *sc.MaxLength)})
+	}
+	if sc.pattern != nil && !sc.pattern.MatchString(str) {
+		errs = append(errs, fieldError{field, fmt.Sprintf("must match %s", sc.Pattern)})
+	}
+	switch sc.Format {
+	case "uuid":
+		if _, err := uuid.Parse(str); err != nil {
+			errs = append(errs, fieldError{field, "must be a uuid"})
+		}
+	case "byte":
+		if _, err := base64.StdEncoding.DecodeString(str); err != nil {
+			errs = append(errs, fieldError{field, "must be base64 encoded"})
+		}
+	}
+	return errs
+}
+
+func validateNumber(sc *schema, n json.Number, field string, errs validationError) validationError {
+	if sc.Type == "integer" {
+		if _, err := n.Int64(); err != nil {
+			return append(errs, fieldError{field, "must be an integer"})
+		}
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return append(errs, fieldError{field, "must be a number"})
+	}
+	if sc.Minimum != nil && f < *sc.Minimum {
+		errs = append(errs, fieldError{field, fmt.Sprintf("must be at least %v", *sc.Minimum)})
+	}
+	if sc.Maximum != nil && f > *sc.Maximum {
+		errs = append(errs, fieldError{field, fmt.Sprintf("must be at most %v", *sc.Maximum)})
+	}
+	return errs
+}
+
+func enumContains(enum []any, str string) bool {
+	return slices.Contains(enum, any(str))
+}
+
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(openAPIDocument)
+	return err
+}
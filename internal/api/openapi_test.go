@@ -0,0 +1,195 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anthdm/raptor/internal/version"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	s := createServer()
+	require.Equal(t, version.Version, spec.Info.Version)
+
+	routes := map[string]bool{}
+	err := chi.Walk(s.router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		require.NotNil(t, spec.operation(method, route), "%s %s is not in the openapi document", method, route)
+		routes[strings.ToLower(method)+" "+route] = true
+		return nil
+	})
+	require.Nil(t, err)
+
+	for path, ops := range spec.Paths {
+		for method := range ops {
+			route := strings.Replace(path, "{key}", "*", 1)
+			require.True(t, routes[method+" "+route], "%s %s is not a route", method, path)
+		}
+	}
+}
+
+func TestOpenAPIRefsResolve(t *testing.T) {
+	var walk func(sc *schema)
+	walk = func(sc *schema) {
+		if sc == nil {
+			return
+		}
+		require.NotNil(t, spec.resolveSchema(sc), "unknown schema %s", sc.Ref)
+		for _, prop := range sc.Properties {
+			walk(prop)
+		}
+		for _, sub := range sc.AllOf {
+			walk(sub)
+		}
+		if sc.AdditionalProperties != nil {
+			walk(sc.AdditionalProperties.schema)
+		}
+		walk(sc.Items)
+	}
+	for _, sc := range spec.Components.Schemas {
+		walk(sc)
+	}
+	for _, ops := range spec.Paths {
+		for _, op := range ops {
+			for _, p := range op.Parameters {
+				p = spec.resolveParameter(p)
+				require.NotNil(t, p)
+				walk(p.Schema)
+			}
+			if op.RequestBody != nil {
+				for _, content := range op.RequestBody.Content {
+					walk(content.Schema)
+				}
+			}
+		}
+	}
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	s := createServer()
+	req := httptest.NewRequest("GET", "/openapi.json", nil)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	require.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+
+	var doc map[string]any
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&doc))
+	require.Equal(t, "3.0.3", doc["openapi"])
+}
+
+func TestValidateRequest(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	id := endpoint.ID.String()
+
+	tests := []struct {
+		method  string
+		target  string
+		body    string
+		details []fieldError
+	}{
+		{
+			method:  "POST",
+			target:  "/endpoint",
+			body:    `{"name":"my-endpoint","runtime":"go","enviroment":{"A":"B"}}`,
+			details: []fieldError{{"body.enviroment", "is not a known field"}},
+		},
+		{
+			method: "POST",
+			target: "/endpoint",
+			body:   `{"name":"ab","runtime":"ruby","slug":"No Slug"}`,
+			details: []fieldError{
+				{"body.name", "must be at least 3 characters long"},
+				{"body.runtime", "must be one of [go tinygo rust wasi js python]"},
+				{"body.slug", "must match ^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)?$"},
+			},
+		},
+		{
+			method:  "POST",
+			target:  "/endpoint",
+			body:    `{"runtime":"go","environment":{"A":1}}`,
+			details: []fieldError{{"body.name", "is required"}, {"body.environment.A", "must be a string"}},
+		},
+		{
+			method:  "POST",
+			target:  "/endpoint",
+			details: []fieldError{{"body", "is required"}},
+		},
+		{
+			method:  "PUT",
+			target:  "/endpoint/" + id,
+			body:    `{"version":1.5}`,
+			details: []fieldError{{"body.version", "must be an integer"}},
+		},
+		{
+			method:  "GET",
+			target:  "/endpoint/nope",
+			details: []fieldError{{"path.id", "must be a uuid"}},
+		},
+		{
+			method:  "GET",
+			target:  "/endpoint/" + id + "/logs?limit=-1",
+			details: []fieldError{{"query.limit", "must be at least 0"}},
+		},
+		{
+			method:  "GET",
+			target:  "/endpoint/" + id + "/invocation?status=gone",
+			details: []fieldError{{"query.status", "must be one of [ queued running succeeded dead]"}},
+		},
+		{
+			method:  "POST",
+			target:  "/endpoint/" + id + "/deployment?publish=maybe",
+			body:    "blob",
+			details: []fieldError{{"query.publish", "must be a boolean"}},
+		},
+		{
+			method:  "PUT",
+			target:  "/endpoint/" + id + "/kv/foo",
+			body:    `{"value":"not base64!"}`,
+			details: []fieldError{{"body.value", "must be base64 encoded"}},
+		},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusBadRequest, resp.Result().StatusCode, test.target)
+
+		var errResp errorResponse
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&errResp))
+		require.Equal(t, codeInvalidRequest, errResp.Code)
+		require.Equal(t, test.details, errResp.Details)
+		require.True(t, strings.HasPrefix(errResp.Error, "invalid request: "))
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	s := createServer()
+
+	tests := []struct {
+		method string
+		target string
+		status int
+		code   string
+	}{
+		{"GET", "/endpoint/00000000-0000-0000-0000-000000000000", http.StatusNotFound, codeNotFound},
+		{"GET", "/nope", http.StatusNotFound, codeNotFound},
+		{"PATCH", "/endpoint", http.StatusMethodNotAllowed, codeMethodNotAllowed},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.target, nil)
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, test.status, resp.Result().StatusCode, test.target)
+
+		var errResp errorResponse
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&errResp))
+		require.Equal(t, test.code, errResp.Code)
+		require.NotEmpty(t, errResp.Error)
+	}
+}
@@ -1,13 +1,23 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/anthdm/raptor/internal/assets"
 	"github.com/anthdm/raptor/internal/config"
+	"github.com/anthdm/raptor/internal/cpython"
 	"github.com/anthdm/raptor/internal/storage"
 	"github.com/anthdm/raptor/internal/types"
 	"github.com/go-chi/chi/v5"
@@ -20,6 +30,12 @@ type Server struct {
 	store       storage.Store
 	metricStore storage.MetricStore
 	cache       storage.ModCacher
+	// lookupTXT is used to verify the ownership of custom domains.
+	lookupTXT func(name string) ([]string, error)
+	// ingressURL is the URL the smoke tests of deployments are made against.
+	ingressURL string
+	// smokeTestClient makes the requests of the smoke tests.
+	smokeTestClient *http.Client
 }
 
 // NewServer returns a new server given a Store interface.
@@ -28,13 +44,23 @@ func NewServer(store storage.Store, metricStore storage.MetricStore, cache stora
 		store:       store,
 		cache:       cache,
 		metricStore: metricStore,
+		lookupTXT:   net.LookupTXT,
+		ingressURL:  config.IngressUrl(),
+		smokeTestClient: &http.Client{
+			Timeout: smokeTestTimeout,
+		},
 	}
 }
 
 // Listen starts listening on the given address.
 func (s *Server) Listen(addr string) error {
+	return http.ListenAndServe(addr, s.Handler())
+}
+
+// Handler returns the handler that serves the API.
+func (s *Server) Handler() http.Handler {
 	s.initRouter()
-	return http.ListenAndServe(addr, s.router)
+	return s.router
 }
 
 func (s *Server) initRouter() {
@@ -42,13 +68,36 @@ func (s *Server) initRouter() {
 	if config.Get().Authorization {
 		s.router.Use(s.withAPIToken)
 	}
+	s.router.NotFound(handleNotFound)
+	s.router.MethodNotAllowed(handleMethodNotAllowed)
 	s.router.Get("/status", handleStatus)
+	s.router.Get("/openapi.json", makeAPIHandler(handleOpenAPI))
 	s.router.Get("/endpoint/{id}", makeAPIHandler(s.handleGetEndpoint))
 	s.router.Get("/endpoint", makeAPIHandler(s.handleGetEndpoints))
 	s.router.Get("/endpoint/{id}/metrics", makeAPIHandler(s.handleGetEndpointMetrics))
+	s.router.Get("/endpoint/{id}/logs", makeAPIHandler(s.handleGetEndpointLogs))
 	s.router.Post("/endpoint", makeAPIHandler(s.handleCreateEndpoint))
 	s.router.Post("/endpoint/{id}/deployment", makeAPIHandler(s.handleCreateDeployment))
+	s.router.Post("/deployment/{id}/environment", makeAPIHandler(s.handleCreateEnvironmentDeployment))
 	s.router.Put("/endpoint/{id}", makeAPIHandler(s.handleUpdateEndpoint))
+	s.router.Post("/endpoint/{id}/purge", makeAPIHandler(s.handlePurgeEndpoint))
+	s.router.Delete("/endpoint/{id}", makeAPIHandler(s.handleDeleteEndpoint))
+	s.router.Get("/endpoint/{id}/domain", makeAPIHandler(s.handleGetDomains))
+	s.router.Post("/endpoint/{id}/domain", makeAPIHandler(s.handleCreateDomain))
+	s.router.Post("/domain/{hostname}/verify", makeAPIHandler(s.handleVerifyDomain))
+	s.router.Delete("/domain/{hostname}", makeAPIHandler(s.handleDeleteDomain))
+	s.router.Put("/domain/{hostname}/certificate", makeAPIHandler(s.handlePutCertificate))
+	s.router.Get("/endpoint/{id}/kv", makeAPIHandler(s.handleListKV))
+	s.router.Get("/endpoint/{id}/kv/*", makeAPIHandler(s.handleGetKV))
+	s.router.Put("/endpoint/{id}/kv/*", makeAPIHandler(s.handlePutKV))
+	s.router.Delete("/endpoint/{id}/kv/*", makeAPIHandler(s.handleDeleteKV))
+	s.router.Get("/endpoint/{id}/schedule", makeAPIHandler(s.handleGetSchedules))
+	s.router.Post("/endpoint/{id}/schedule", makeAPIHandler(s.handleCreateSchedule))
+	s.router.Get("/schedule/{id}/runs", makeAPIHandler(s.handleGetScheduleRuns))
+	s.router.Delete("/schedule/{id}", makeAPIHandler(s.handleDeleteSchedule))
+	s.router.Get("/endpoint/{id}/invocation", makeAPIHandler(s.handleGetInvocations))
+	s.router.Get("/invocation/{id}", makeAPIHandler(s.handleGetInvocation))
+	s.router.Post("/invocation/{id}/retry", makeAPIHandler(s.handleRetryInvocation))
 	s.router.Post("/publish", makeAPIHandler(s.handlePublish))
 }
 
@@ -65,10 +114,20 @@ func handleStatus(w http.ResponseWriter, r *http.Request) {
 type CreateEndpointParams struct {
 	// Name of the endpoint
 	Name string `json:"name"`
-	// Runtime on which the code will be invoked. (go or js for now)
+	// Slug used to reach the endpoint by name (/live/<slug> or <slug>.<appsDomain>).
+	// When empty, it is derived from the name.
+	Slug string `json:"slug"`
+	// Runtime on which the code will be invoked (go, tinygo, rust, wasi, js or
+	// python).
 	Runtime string `json:"runtime"`
 	// A map of environment variables
 	Environment map[string]string `json:"environment"`
+	// When true, each deployment stores a snapshot of the environment and runs
+	// with it, so environment changes only go LIVE when they are published.
+	SnapshotEnvironment bool `json:"snapshot_environment"`
+	// Hosts the endpoint may send outbound HTTP requests to. Entries are a
+	// hostname, a host:port or a wildcard like *.example.com.
+	AllowedHosts []string `json:"allowed_hosts"`
 }
 
 func (p CreateEndpointParams) validate() error {
@@ -82,11 +141,67 @@ func (p CreateEndpointParams) validate() error {
 	if _, ok := types.Runtimes[p.Runtime]; !ok {
 		return fmt.Errorf("invalid runtime given: %s", p.Runtime)
 	}
+	if err := runtimeAvailable(p.Runtime); err != nil {
+		return err
+	}
+	if len(p.Slug) > 0 && !types.ValidSlug(p.Slug) {
+		return fmt.Errorf("invalid slug given: %s", p.Slug)
+	}
+	return validateAllowedHosts(p.AllowedHosts)
+}
+
+// errPythonUnavailable is returned for the python runtime when its
+// interpreter is not vendored, see cpython.Available.
+var errPythonUnavailable = errors.New("the python runtime is not available: its interpreter is not part of this build")
+
+// runtimeAvailable returns an error when the engine of the runtime is not
+// part of this build, so deployments do not fail on every request instead.
+func runtimeAvailable(runtime string) error {
+	if runtime == "python" && !cpython.Available() {
+		return errPythonUnavailable
+	}
 	return nil
 }
 
+func validateAllowedHosts(hosts []string) error {
+	for _, host := range hosts {
+		if !types.ValidAllowedHost(host) {
+			return fmt.Errorf("invalid allowed host given: %s", host)
+		}
+	}
+	return nil
+}
+
+// UpdateEndpointParams holds all the fields that can be updated on an endpoint.
 type UpdateEndpointParams struct {
+	// Environment variables that will be set on the endpoint. Existing
+	// variables with the same key are overwritten.
 	Environment map[string]string `json:"environment"`
+	// Keys of the environment variables that will be removed.
+	UnsetEnvironment []string `json:"unset_environment"`
+	// When true, the whole environment of the endpoint is replaced by Environment.
+	ReplaceEnvironment bool `json:"replace_environment"`
+	// Toggles whether new deployments store a snapshot of the environment.
+	SnapshotEnvironment *bool `json:"snapshot_environment"`
+	// Replaces the hosts the endpoint may send outbound HTTP requests to.
+	// Omit to leave them untouched, an empty list removes all of them.
+	AllowedHosts []string `json:"allowed_hosts"`
+	// The version of the endpoint this update is based on. When provided, the
+	// update fails if the endpoint was modified in the meantime. The If-Match
+	// header can be used instead.
+	Version int `json:"version"`
+}
+
+func (p UpdateEndpointParams) validate() error {
+	if p.ReplaceEnvironment && len(p.UnsetEnvironment) > 0 {
+		return fmt.Errorf("unset_environment can not be combined with replace_environment")
+	}
+	for _, key := range p.UnsetEnvironment {
+		if _, ok := p.Environment[key]; ok {
+			return fmt.Errorf("environment variable %s can not be set and unset at the same time", key)
+		}
+	}
+	return validateAllowedHosts(p.AllowedHosts)
 }
 
 func (s *Server) handleUpdateEndpoint(w http.ResponseWriter, r *http.Request) error {
@@ -94,8 +209,7 @@ func (s *Server) handleUpdateEndpoint(w http.ResponseWriter, r *http.Request) er
 	if err != nil {
 		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
 	}
-	endpoint, err := s.store.GetEndpoint(endpointID)
-	if err != nil {
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
 		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
 	}
 	var params UpdateEndpointParams
@@ -103,18 +217,59 @@ func (s *Server) handleUpdateEndpoint(w http.ResponseWriter, r *http.Request) er
 		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
 	}
 	defer r.Body.Close()
-	if len(params.Environment) > 0 {
-		for k, v := range params.Environment {
-			endpoint.Environment[k] = v
+	if err := params.validate(); err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if etag := r.Header.Get("If-Match"); len(etag) > 0 {
+		version, err := parseETag(etag)
+		if err != nil {
+			return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
 		}
+		params.Version = version
 	}
 	updateParams := storage.UpdateEndpointParams{
-		Environment: endpoint.Environment,
+		Environment:         params.Environment,
+		UnsetEnvironment:    params.UnsetEnvironment,
+		ReplaceEnvironment:  params.ReplaceEnvironment,
+		SnapshotEnvironment: params.SnapshotEnvironment,
+		AllowedHosts:        params.AllowedHosts,
+		Version:             params.Version,
 	}
 	if err := s.store.UpdateEndpoint(endpointID, updateParams); err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			return writeJSON(w, http.StatusPreconditionFailed, ErrorResponse(err))
+		}
 		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
 	}
-	return writeJSON(w, http.StatusOK, map[string]string{"status": "OK"})
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	w.Header().Set("ETag", makeETag(endpoint.Version))
+	return writeJSON(w, http.StatusOK, endpoint)
+}
+
+// handlePurgeEndpoint purges the responses the ingress cached for the
+// endpoint. The ingress caches responses per cache generation of the
+// endpoint, so bumping it is enough. The version is left as it is, hence
+// purging does not fail concurrent updates made with If-Match.
+func (s *Server) handlePurgeEndpoint(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	if err := s.store.PurgeEndpointCache(endpointID); err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	w.Header().Set("ETag", makeETag(endpoint.Version))
+	return writeJSON(w, http.StatusOK, endpoint)
 }
 
 func (s *Server) handleCreateEndpoint(w http.ResponseWriter, r *http.Request) error {
@@ -129,14 +284,157 @@ func (s *Server) handleCreateEndpoint(w http.ResponseWriter, r *http.Request) er
 	}
 
 	endpoint := types.NewEndpoint(params.Name, params.Runtime, params.Environment)
+	endpoint.SnapshotEnvironment = params.SnapshotEnvironment
+	if params.AllowedHosts != nil {
+		endpoint.AllowedHosts = params.AllowedHosts
+	}
+	endpoint.Slug = params.Slug
+	if len(endpoint.Slug) == 0 {
+		endpoint.Slug = s.makeUniqueSlug(endpoint)
+	}
 	if err := s.store.CreateEndpoint(endpoint); err != nil {
 		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
 	}
 	return writeJSON(w, http.StatusOK, endpoint)
 }
 
+// makeUniqueSlug derives a slug from the name of the endpoint. If the slug is
+// already taken, part of the endpoint id is appended.
+func (s *Server) makeUniqueSlug(endpoint *types.Endpoint) string {
+	suffix := strings.Split(endpoint.ID.String(), "-")[0]
+	slug := types.MakeSlug(endpoint.Name)
+	if !types.ValidSlug(slug) {
+		return suffix
+	}
+	if _, err := s.store.GetEndpointBySlug(slug); err == nil {
+		return slug + "-" + suffix
+	}
+	return slug
+}
+
 // CreateDeploymentParams holds all the necessary fields to deploy a new function.
-type CreateDeploymentParams struct{}
+// Since the body of the request is the blob, they are passed in the query.
+type CreateDeploymentParams struct {
+	// When true, the deployment is published once it passes its smoke tests.
+	Publish bool `json:"publish"`
+	// Requests that are made against the preview of the deployment before it
+	// is published, in the form of "[METHOD] PATH [STATUS]".
+	SmokeTests []types.SmokeTest `json:"smoke_tests"`
+	// Assets is an archive of static assets that are served together with
+	// the deployment. It is sent with the blob, see Body.
+	Assets []byte `json:"-"`
+}
+
+// The parts of the multipart body of a deployment with assets.
+const (
+	deploymentPartBlob   = "blob"
+	deploymentPartAssets = "assets"
+)
+
+// Body returns the body and its content type of the request that creates a
+// deployment of the given blob. Deployments with assets are sent as a
+// multipart form with the blob and the assets as its parts.
+func (p CreateDeploymentParams) Body(blob []byte) ([]byte, string, error) {
+	if len(p.Assets) == 0 {
+		return blob, "application/octet-stream", nil
+	}
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, part := range []struct {
+		name string
+		b    []byte
+	}{{deploymentPartBlob, blob}, {deploymentPartAssets, p.Assets}} {
+		w, err := mw.CreateFormFile(part.name, part.name)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := w.Write(part.b); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), mw.FormDataContentType(), nil
+}
+
+// readDeploymentBody returns the blob and the assets of the request that
+// creates a deployment, see CreateDeploymentParams.Body.
+func readDeploymentBody(r *http.Request) (blob []byte, bundle []byte, err error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		blob, err = io.ReadAll(r.Body)
+		return blob, nil, err
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, nil, err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return blob, bundle, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		switch part.FormName() {
+		case deploymentPartBlob:
+			blob, err = io.ReadAll(part)
+		case deploymentPartAssets:
+			bundle, err = io.ReadAll(part)
+		default:
+			err = fmt.Errorf("unknown part %q", part.FormName())
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// Query returns the query of the request that creates the deployment.
+func (p CreateDeploymentParams) Query() url.Values {
+	query := url.Values{}
+	if p.Publish {
+		query.Set("publish", "true")
+	}
+	for _, t := range p.SmokeTests {
+		query.Add("smoke_test", t.String())
+	}
+	return query
+}
+
+func parseCreateDeploymentParams(query url.Values) (CreateDeploymentParams, error) {
+	var params CreateDeploymentParams
+	if value := query.Get("publish"); len(value) > 0 {
+		publish, err := strconv.ParseBool(value)
+		if err != nil {
+			return params, fmt.Errorf("invalid publish value %q", value)
+		}
+		params.Publish = publish
+	}
+	for _, value := range query["smoke_test"] {
+		t, err := types.ParseSmokeTest(value)
+		if err != nil {
+			return params, err
+		}
+		params.SmokeTests = append(params.SmokeTests, t)
+	}
+	if len(params.SmokeTests) > 0 && !params.Publish {
+		return params, fmt.Errorf("smoke tests can only be used together with publish")
+	}
+	return params, nil
+}
+
+// CreateDeploymentResponse is the deployment that was created and whether it
+// was published.
+type CreateDeploymentResponse struct {
+	*types.Deployment
+	Published bool `json:"published"`
+	// The LIVE url of the endpoint when the deployment was published.
+	URL        string                  `json:"url,omitempty"`
+	SmokeTests []types.SmokeTestResult `json:"smoke_tests,omitempty"`
+}
 
 func (s *Server) handleCreateDeployment(w http.ResponseWriter, r *http.Request) error {
 	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
@@ -147,11 +445,18 @@ func (s *Server) handleCreateDeployment(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
 	}
+	if err := runtimeAvailable(endpoint.Runtime); err != nil {
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	params, err := parseCreateDeploymentParams(r.URL.Query())
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
 
 	// TODO:
 	// 1. validate the contents of the blob.
 	// 2. make sure we have a limit on the maximum blob size.
-	b, err := io.ReadAll(r.Body)
+	b, bundle, err := readDeploymentBody(r)
 	if err != nil {
 		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
 	}
@@ -159,11 +464,71 @@ func (s *Server) handleCreateDeployment(w http.ResponseWriter, r *http.Request)
 		err := fmt.Errorf("no blob")
 		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
 	}
+	// Invalid assets would only fail once they are served.
+	if len(bundle) > 0 {
+		if _, err := assets.Parse(bundle); err != nil {
+			return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+		}
+	}
 	deploy := types.NewDeployment(endpoint, b)
+	deploy.Assets = bundle
 	if err := s.store.CreateDeployment(deploy); err != nil {
 		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
 	}
-	return writeJSON(w, http.StatusOK, deploy)
+	resp := CreateDeploymentResponse{Deployment: deploy}
+	if !params.Publish {
+		return writeJSON(w, http.StatusOK, resp)
+	}
+	results, passed := s.runSmokeTests(deploy, params.SmokeTests)
+	resp.SmokeTests = results
+	if !passed {
+		return writeJSON(w, http.StatusOK, resp)
+	}
+	published, err := s.publish(deploy)
+	if err != nil {
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	resp.Published = true
+	resp.URL = published.URL
+	return writeJSON(w, http.StatusOK, resp)
+}
+
+// CreateEnvironmentDeploymentParams holds all the necessary fields to create a
+// deployment that runs the code of an existing deployment with another environment.
+type CreateEnvironmentDeploymentParams struct {
+	// The environment of the new deployment. When nil, the current environment
+	// of the endpoint is used.
+	Environment map[string]string `json:"environment"`
+}
+
+func (s *Server) handleCreateEnvironmentDeployment(w http.ResponseWriter, r *http.Request) error {
+	deployID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	deploy, err := s.store.GetDeployment(deployID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	var params CreateEnvironmentDeploymentParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(ErrDecodeRequestBody))
+	}
+	defer r.Body.Close()
+
+	env := params.Environment
+	if env == nil {
+		endpoint, err := s.store.GetEndpoint(deploy.EndpointID)
+		if err != nil {
+			return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+		}
+		env = endpoint.Environment
+	}
+	newDeploy := types.NewDeploymentWithEnvironment(deploy, env)
+	if err := s.store.CreateDeployment(newDeploy); err != nil {
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, newDeploy)
 }
 
 func (s *Server) handleGetEndpoint(w http.ResponseWriter, r *http.Request) error {
@@ -175,16 +540,32 @@ func (s *Server) handleGetEndpoint(w http.ResponseWriter, r *http.Request) error
 	if err != nil {
 		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
 	}
+	w.Header().Set("ETag", makeETag(endpoint.Version))
 	return writeJSON(w, http.StatusOK, endpoint)
 }
 
 func (s *Server) handleGetEndpoints(w http.ResponseWriter, r *http.Request) error {
-	return nil
-	// endpoints, err := s.store.GetEndpoints()
-	// if err != nil {
-	// 	return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
-	// }
-	// return writeJSON(w, http.StatusOK, endpoints)
+	endpoints, err := s.store.GetEndpoints()
+	if err != nil {
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, endpoints)
+}
+
+func (s *Server) handleDeleteEndpoint(w http.ResponseWriter, r *http.Request) error {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	endpoint, err := s.store.GetEndpoint(id)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	if err := s.store.DeleteEndpoint(id); err != nil {
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	s.cache.Delete(endpoint.ActiveDeploymentID)
+	return writeJSON(w, http.StatusOK, map[string]string{"status": "OK"})
 }
 
 // PublishParams holds all the necessary fields to publish a specific
@@ -208,44 +589,442 @@ func (s *Server) handlePublish(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
 	}
-	endpoint, err := s.store.GetEndpoint(deploy.EndpointID)
+	resp, err := s.publish(deploy)
 	if err != nil {
 		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
 	}
+	return writeJSON(w, http.StatusOK, resp)
+}
+
+// publish makes the given deployment the active deployment of its endpoint.
+func (s *Server) publish(deploy *types.Deployment) (*PublishResponse, error) {
+	endpoint, err := s.store.GetEndpoint(deploy.EndpointID)
+	if err != nil {
+		return nil, err
+	}
 
 	currentDeploymentID := endpoint.ActiveDeploymentID
 
 	if currentDeploymentID.String() == deploy.ID.String() {
-		err := fmt.Errorf("deploy %s already active", deploy.ID)
-		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+		return nil, fmt.Errorf("deploy %s already active", deploy.ID)
 	}
 
+	// Updating the endpoint bumps its cache generation, which purges the
+	// responses the ingress cached for it.
 	updateParams := storage.UpdateEndpointParams{
 		ActiveDeployID: deploy.ID,
 	}
 	if err := s.store.UpdateEndpoint(deploy.EndpointID, updateParams); err != nil {
-		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+		return nil, err
 	}
 
 	s.cache.Delete(currentDeploymentID)
 
-	resp := PublishResponse{
+	return &PublishResponse{
 		DeploymentID: deploy.ID,
-		URL:          fmt.Sprintf("%s/live/%s", config.IngressUrl(), endpoint.ID),
+		URL:          fmt.Sprintf("%s/live/%s", s.ingressURL, endpoint.ID),
+	}, nil
+}
+
+func (s *Server) handleGetEndpointMetrics(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	metrics, err := s.metricStore.GetRequestMetrics(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, metrics)
+}
+
+func (s *Server) handleGetEndpointLogs(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	limit := 0
+	if value := r.URL.Query().Get("limit"); len(value) > 0 {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return writeJSON(w, http.StatusBadRequest, ErrorResponse(fmt.Errorf("invalid limit given: %s", value)))
+		}
+	}
+	logs, err := s.metricStore.GetRuntimeLogs(endpointID, limit)
+	if err != nil {
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, logs)
+}
+
+// CreateDomainParams holds all the necessary fields to attach a custom
+// domain to an endpoint.
+type CreateDomainParams struct {
+	Hostname string `json:"hostname"`
+}
+
+func (p CreateDomainParams) validate() error {
+	if !types.ValidHostname(p.Hostname) {
+		return fmt.Errorf("invalid hostname given: %s", p.Hostname)
+	}
+	appsDomain := config.Get().AppsDomain
+	if len(appsDomain) > 0 && strings.HasSuffix(types.NormalizeHostname(p.Hostname), "."+appsDomain) {
+		return fmt.Errorf("hostnames under %s are reserved", appsDomain)
+	}
+	return nil
+}
+
+// DomainResponse holds a custom domain together with the DNS TXT record
+// that needs to hold the verification token.
+type DomainResponse struct {
+	*types.Domain
+	VerificationRecord string `json:"verification_record"`
+}
+
+func makeDomainResponse(domain *types.Domain) DomainResponse {
+	return DomainResponse{
+		Domain:             domain,
+		VerificationRecord: domain.VerificationRecord(),
+	}
+}
+
+func (s *Server) handleCreateDomain(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	var params CreateDomainParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(ErrDecodeRequestBody))
+	}
+	defer r.Body.Close()
+	if err := params.validate(); err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	domain := types.NewDomain(endpoint, params.Hostname)
+	if err := s.store.CreateDomain(domain); err != nil {
+		if errors.Is(err, storage.ErrDomainExists) {
+			return writeJSON(w, http.StatusConflict, ErrorResponse(err))
+		}
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, makeDomainResponse(domain))
+}
+
+func (s *Server) handleGetDomains(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	domains, err := s.store.GetDomains(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusInternalServerError, ErrorResponse(err))
+	}
+	resp := make([]DomainResponse, len(domains))
+	for i, domain := range domains {
+		resp[i] = makeDomainResponse(domain)
 	}
 	return writeJSON(w, http.StatusOK, resp)
 }
 
-func (s *Server) handleGetEndpointMetrics(w http.ResponseWriter, r *http.Request) error {
+// handleVerifyDomain verifies that the caller owns the domain by looking up
+// the verification token in the DNS TXT record of the domain.
+func (s *Server) handleVerifyDomain(w http.ResponseWriter, r *http.Request) error {
+	hostname := types.NormalizeHostname(chi.URLParam(r, "hostname"))
+	domain, err := s.store.GetDomain(hostname)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	if !domain.Verified {
+		records, err := s.lookupTXT(domain.VerificationRecord())
+		if err != nil {
+			err := fmt.Errorf("could not lookup TXT record %s: %s", domain.VerificationRecord(), err)
+			return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+		}
+		found := false
+		for _, record := range records {
+			if strings.TrimSpace(record) == domain.VerificationToken {
+				found = true
+				break
+			}
+		}
+		if !found {
+			err := fmt.Errorf("TXT record %s does not contain the verification token", domain.VerificationRecord())
+			return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+		}
+		if err := s.store.VerifyDomain(hostname); err != nil {
+			return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+		}
+		domain.Verified = true
+	}
+	return writeJSON(w, http.StatusOK, makeDomainResponse(domain))
+}
+
+func (s *Server) handleDeleteDomain(w http.ResponseWriter, r *http.Request) error {
+	hostname := types.NormalizeHostname(chi.URLParam(r, "hostname"))
+	if _, err := s.store.GetDomain(hostname); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	if err := s.store.DeleteDomain(hostname); err != nil {
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, map[string]string{"status": "OK"})
+}
+
+// PutCertificateParams holds the PEM encoded certificate and private key of a
+// custom domain that is served by the ingress over HTTPS.
+type PutCertificateParams struct {
+	Certificate string `json:"certificate"`
+	PrivateKey  string `json:"private_key"`
+}
+
+func (s *Server) handlePutCertificate(w http.ResponseWriter, r *http.Request) error {
+	hostname := types.NormalizeHostname(chi.URLParam(r, "hostname"))
+	if _, err := s.store.GetDomain(hostname); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	var params PutCertificateParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(ErrDecodeRequestBody))
+	}
+	defer r.Body.Close()
+	cert, err := types.NewCertificate(hostname, []byte(params.Certificate), []byte(params.PrivateKey))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if err := s.store.PutCertificate(cert); err != nil {
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, cert)
+}
+
+// PutKVParams holds the value of a key in the key-value store of an endpoint.
+type PutKVParams struct {
+	// The value of the key, base64 encoded in JSON.
+	Value []byte `json:"value"`
+	// Number of seconds after which the key expires. Zero never expires.
+	TTL int `json:"ttl"`
+}
+
+func (s *Server) handleListKV(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	limit := 0
+	if value := r.URL.Query().Get("limit"); len(value) > 0 {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return writeJSON(w, http.StatusBadRequest, ErrorResponse(fmt.Errorf("invalid limit given: %s", value)))
+		}
+	}
+	entries, err := s.store.ListKV(endpointID, r.URL.Query().Get("prefix"), limit)
+	if err != nil {
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *Server) handleGetKV(w http.ResponseWriter, r *http.Request) error {
 	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
 		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
 	}
-	metrics, err := s.metricStore.GetRuntimeMetrics(endpointID)
+	entry, err := s.store.GetKV(endpointID, chi.URLParam(r, "*"))
 	if err != nil {
 		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
 	}
-	return writeJSON(w, http.StatusOK, metrics)
+	return writeJSON(w, http.StatusOK, entry)
+}
+
+func (s *Server) handlePutKV(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	var params PutKVParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(ErrDecodeRequestBody))
+	}
+	defer r.Body.Close()
+	ttl := time.Duration(params.TTL) * time.Second
+	entry, err := types.NewKVEntry(endpointID, chi.URLParam(r, "*"), params.Value, ttl)
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if err := s.store.PutKV(entry); err != nil {
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, entry)
+}
+
+func (s *Server) handleDeleteKV(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	key := chi.URLParam(r, "*")
+	if _, err := s.store.GetKV(endpointID, key); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	if err := s.store.DeleteKV(endpointID, key); err != nil {
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, map[string]string{"status": "OK"})
+}
+
+// CreateScheduleParams holds all the fields to invoke an endpoint on a cron schedule.
+type CreateScheduleParams struct {
+	// A 5 field cron expression (minute hour day-of-month month day-of-week)
+	// or a descriptor like @hourly, evaluated in UTC.
+	Cron string `json:"cron"`
+	// The method of the request, defaults to POST.
+	Method string `json:"method"`
+	// The path of the request, defaults to /.
+	Path string `json:"path"`
+}
+
+func (s *Server) handleCreateSchedule(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	endpoint, err := s.store.GetEndpoint(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	var params CreateScheduleParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(ErrDecodeRequestBody))
+	}
+	defer r.Body.Close()
+	schedule, err := types.NewSchedule(endpoint, params.Cron, params.Method, params.Path)
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if err := s.store.CreateSchedule(schedule); err != nil {
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, schedule)
+}
+
+func (s *Server) handleGetSchedules(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	schedules, err := s.store.GetSchedules(endpointID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, schedules)
+}
+
+func (s *Server) handleGetScheduleRuns(w http.ResponseWriter, r *http.Request) error {
+	scheduleID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if _, err := s.store.GetSchedule(scheduleID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	runs, err := s.store.GetScheduleRuns(scheduleID)
+	if err != nil {
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, runs)
+}
+
+func (s *Server) handleDeleteSchedule(w http.ResponseWriter, r *http.Request) error {
+	scheduleID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if _, err := s.store.GetSchedule(scheduleID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	if err := s.store.DeleteSchedule(scheduleID); err != nil {
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, map[string]string{"status": "OK"})
+}
+
+func (s *Server) handleGetInvocations(w http.ResponseWriter, r *http.Request) error {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	if _, err := s.store.GetEndpoint(endpointID); err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", types.InvocationQueued, types.InvocationRunning, types.InvocationSucceeded, types.InvocationDead:
+	default:
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(fmt.Errorf("invalid invocation status %q", status)))
+	}
+	invocations, err := s.store.GetInvocations(endpointID, status)
+	if err != nil {
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, invocations)
+}
+
+func (s *Server) handleGetInvocation(w http.ResponseWriter, r *http.Request) error {
+	invocationID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	invocation, err := s.store.GetInvocation(invocationID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, invocation)
+}
+
+// handleRetryInvocation puts a dead invocation back in the queue with all
+// its attempts.
+func (s *Server) handleRetryInvocation(w http.ResponseWriter, r *http.Request) error {
+	invocationID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	invocation, err := s.store.GetInvocation(invocationID)
+	if err != nil {
+		return writeJSON(w, http.StatusNotFound, ErrorResponse(err))
+	}
+	if invocation.Status != types.InvocationDead {
+		err := fmt.Errorf("only dead invocations can be retried, invocation is %s", invocation.Status)
+		return writeJSON(w, http.StatusBadRequest, ErrorResponse(err))
+	}
+	now := time.Now().UTC()
+	invocation.Status = types.InvocationQueued
+	invocation.Attempts = 0
+	invocation.NextAttemptAT = now
+	invocation.UpdatedAT = now
+	if err := s.store.UpdateInvocation(invocation); err != nil {
+		return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse(err))
+	}
+	return writeJSON(w, http.StatusOK, invocation)
 }
 
 var errUnauthorized = errors.New("unauthorized")
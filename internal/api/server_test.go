@@ -3,14 +3,22 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/anthdm/raptor/internal/assets"
+	"github.com/anthdm/raptor/internal/cpython"
 	"github.com/anthdm/raptor/internal/shared"
 	"github.com/anthdm/raptor/internal/storage"
 	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 )
 
@@ -33,6 +41,54 @@ func TestUpdateEndpoint(t *testing.T) {
 	require.Equal(t, expected, endpoint.Environment)
 }
 
+func TestUpdateEndpointUnsetEnvironment(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	params := UpdateEndpointParams{
+		Environment:      map[string]string{"A": "B"},
+		UnsetEnvironment: []string{"FOO"},
+	}
+	resp := updateEndpoint(t, s, endpoint, params, "")
+
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	require.Equal(t, map[string]string{"A": "B"}, endpoint.Environment)
+	require.Equal(t, 2, endpoint.Version)
+	require.Equal(t, `"2"`, resp.Header().Get("ETag"))
+}
+
+func TestUpdateEndpointReplaceEnvironment(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	params := UpdateEndpointParams{
+		Environment:        map[string]string{"A": "B"},
+		ReplaceEnvironment: true,
+	}
+	resp := updateEndpoint(t, s, endpoint, params, "")
+
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	require.Equal(t, map[string]string{"A": "B"}, endpoint.Environment)
+}
+
+func TestUpdateEndpointVersionConflict(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	params := UpdateEndpointParams{
+		Environment: map[string]string{"A": "B"},
+	}
+	resp := updateEndpoint(t, s, endpoint, params, `"1"`)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+
+	// The endpoint is now at version 2, hence updating version 1 should fail.
+	params.Version = 1
+	params.Environment = map[string]string{"A": "C"}
+	resp = updateEndpoint(t, s, endpoint, params, "")
+	require.Equal(t, http.StatusPreconditionFailed, resp.Result().StatusCode)
+	require.Equal(t, "B", endpoint.Environment["A"])
+}
+
 func TestCreateEndpoint(t *testing.T) {
 	s := createServer()
 
@@ -63,6 +119,127 @@ func TestCreateEndpoint(t *testing.T) {
 	require.True(t, shared.IsZeroUUID(endpoint.ActiveDeploymentID))
 }
 
+func TestCreateEndpointPythonUnavailable(t *testing.T) {
+	if cpython.Available() {
+		t.Skip("python.wasm is vendored")
+	}
+	s := createServer()
+	b, err := json.Marshal(CreateEndpointParams{Name: "My endpoint", Runtime: "python"})
+	require.Nil(t, err)
+	req := httptest.NewRequest("POST", "/endpoint", bytes.NewReader(b))
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusBadRequest, resp.Result().StatusCode)
+	require.Contains(t, resp.Body.String(), errPythonUnavailable.Error())
+
+	// Endpoints created by builds with the interpreter can not deploy.
+	endpoint := types.NewEndpoint("My endpoint", "python", nil)
+	require.Nil(t, s.store.CreateEndpoint(endpoint))
+	req = httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/deployment", bytes.NewReader([]byte("print(1)")))
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusUnprocessableEntity, resp.Result().StatusCode)
+}
+
+func TestUpdateEndpointAllowedHosts(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	params := UpdateEndpointParams{
+		AllowedHosts: []string{"api.example.com", "*.example.org", "localhost:8080"},
+	}
+	resp := updateEndpoint(t, s, endpoint, params, "")
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	require.Equal(t, params.AllowedHosts, endpoint.AllowedHosts)
+
+	// Updates that do not provide the hosts leave them untouched.
+	resp = updateEndpoint(t, s, endpoint, UpdateEndpointParams{Environment: map[string]string{"A": "B"}}, "")
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	require.Equal(t, params.AllowedHosts, endpoint.AllowedHosts)
+
+	params.AllowedHosts = []string{"https://api.example.com/"}
+	resp = updateEndpoint(t, s, endpoint, params, "")
+	require.Equal(t, http.StatusBadRequest, resp.Result().StatusCode)
+}
+
+func TestCreateEndpointSlug(t *testing.T) {
+	s := createServer()
+
+	params := CreateEndpointParams{
+		Name:    "My endpoint",
+		Runtime: "go",
+	}
+	b, err := json.Marshal(params)
+	require.Nil(t, err)
+
+	var slugs []string
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/endpoint", bytes.NewReader(b))
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+
+		var endpoint types.Endpoint
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&endpoint))
+		slugs = append(slugs, endpoint.Slug)
+	}
+	require.Equal(t, "my-endpoint", slugs[0])
+	require.NotEqual(t, slugs[0], slugs[1])
+	require.True(t, types.ValidSlug(slugs[1]))
+}
+
+func TestCreateAndVerifyDomain(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	b, err := json.Marshal(CreateDomainParams{Hostname: "App.Example.com"})
+	require.Nil(t, err)
+	req := httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/domain", bytes.NewReader(b))
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+
+	var domain DomainResponse
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&domain))
+	require.Equal(t, "app.example.com", domain.Hostname)
+	require.Equal(t, "_raptor-challenge.app.example.com", domain.VerificationRecord)
+	require.False(t, domain.Verified)
+
+	s.lookupTXT = func(name string) ([]string, error) {
+		return nil, errors.New("no such host")
+	}
+	req = httptest.NewRequest("POST", "/domain/app.example.com/verify", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusUnprocessableEntity, resp.Result().StatusCode)
+
+	s.lookupTXT = func(name string) ([]string, error) {
+		require.Equal(t, domain.VerificationRecord, name)
+		return []string{domain.VerificationToken}, nil
+	}
+	req = httptest.NewRequest("POST", "/domain/app.example.com/verify", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+
+	stored, err := s.store.GetDomain("app.example.com")
+	require.Nil(t, err)
+	require.True(t, stored.Verified)
+
+	req = httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/domain", bytes.NewReader(b))
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusConflict, resp.Result().StatusCode)
+}
+
+func TestGetDomainsUnknownEndpoint(t *testing.T) {
+	s := createServer()
+	req := httptest.NewRequest("GET", "/endpoint/"+uuid.NewString()+"/domain", nil)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusNotFound, resp.Result().StatusCode)
+}
+
 func TestGetEndpoint(t *testing.T) {
 	s := createServer()
 	endpoint := seedEndpoint(t, s)
@@ -81,6 +258,114 @@ func TestGetEndpoint(t *testing.T) {
 	require.Equal(t, *endpoint, other)
 }
 
+func TestPurgeEndpoint(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	version := endpoint.Version
+	generation := endpoint.CacheGeneration
+
+	req := httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/purge", nil)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+
+	// Purging bumps the generation the ingress caches responses by, but not
+	// the version, so updates based on the version before still succeed.
+	var purged types.Endpoint
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&purged))
+	require.Equal(t, version, purged.Version)
+	require.Equal(t, generation+1, purged.CacheGeneration)
+
+	resp = updateEndpoint(t, s, endpoint, UpdateEndpointParams{Environment: map[string]string{"BAR": "baz"}}, makeETag(version))
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var updated types.Endpoint
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&updated))
+	require.Equal(t, version+1, updated.Version)
+	require.Equal(t, generation+2, updated.CacheGeneration)
+
+	req = httptest.NewRequest("POST", "/endpoint/"+uuid.NewString()+"/purge", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusNotFound, resp.Result().StatusCode)
+}
+
+func TestGetEndpoints(t *testing.T) {
+	s := createServer()
+
+	req := httptest.NewRequest("GET", "/endpoint", nil)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	require.Equal(t, "[]", strings.TrimSpace(resp.Body.String()))
+
+	endpoint := seedEndpoint(t, s)
+	req = httptest.NewRequest("GET", "/endpoint", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+
+	var endpoints []types.Endpoint
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&endpoints))
+	require.Len(t, endpoints, 1)
+	require.Equal(t, endpoint.ID, endpoints[0].ID)
+}
+
+func TestDeleteEndpoint(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	deploy := types.NewDeployment(endpoint, []byte("a"))
+	require.Nil(t, s.store.CreateDeployment(deploy))
+	schedule, err := types.NewSchedule(endpoint, "@hourly", "", "")
+	require.Nil(t, err)
+	require.Nil(t, s.store.CreateSchedule(schedule))
+
+	req := httptest.NewRequest("DELETE", "/endpoint/"+endpoint.ID.String(), nil)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+
+	_, err = s.store.GetEndpoint(endpoint.ID)
+	require.NotNil(t, err)
+	_, err = s.store.GetDeployment(deploy.ID)
+	require.NotNil(t, err)
+	_, err = s.store.GetSchedule(schedule.ID)
+	require.NotNil(t, err)
+
+	req = httptest.NewRequest("DELETE", "/endpoint/"+endpoint.ID.String(), nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusNotFound, resp.Result().StatusCode)
+}
+
+func TestGetEndpointLogs(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	for i := 0; i < 3; i++ {
+		log := &types.RuntimeLog{
+			ID:         uuid.New(),
+			EndpointID: endpoint.ID,
+			Data:       fmt.Sprintf("log %d", i),
+			CreatedAT:  time.Now(),
+		}
+		require.Nil(t, s.metricStore.CreateRuntimeLog(log))
+	}
+
+	req := httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String()+"/logs?limit=2", nil)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+
+	var logs []types.RuntimeLog
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&logs))
+	require.Len(t, logs, 2)
+	require.Equal(t, "log 2", logs[0].Data)
+
+	req = httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String()+"/logs?limit=foo", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusBadRequest, resp.Result().StatusCode)
+}
+
 func TestCreateDeploy(t *testing.T) {
 	s := createServer()
 	endpoint := seedEndpoint(t, s)
@@ -99,6 +384,149 @@ func TestCreateDeploy(t *testing.T) {
 	require.Equal(t, 32, len(deploy.Hash))
 }
 
+func TestCreateDeployAssets(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	dir := t.TempDir()
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "index.html"), []byte("<h1>hi</h1>"), 0644))
+	bundle, err := assets.Archive(dir)
+	require.Nil(t, err)
+
+	createDeploy := func(bundle []byte) *httptest.ResponseRecorder {
+		body, contentType, err := CreateDeploymentParams{Assets: bundle}.Body([]byte("a"))
+		require.Nil(t, err)
+		req := httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/deployment", bytes.NewReader(body))
+		req.Header.Set("content-type", contentType)
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := createDeploy(bundle)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var deploy types.Deployment
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&deploy))
+	stored, err := s.store.GetDeployment(deploy.ID)
+	require.Nil(t, err)
+	require.Equal(t, []byte("a"), stored.Blob)
+	require.Equal(t, bundle, stored.Assets)
+
+	resp = createDeploy([]byte("not an archive"))
+	require.Equal(t, http.StatusBadRequest, resp.Result().StatusCode)
+	var errResp errorResponse
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&errResp))
+	require.Equal(t, assets.ErrInvalidBundle.Error(), errResp.Error)
+}
+
+func TestCreateDeploySnapshotEnvironment(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	endpoint.SnapshotEnvironment = true
+
+	req := httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/deployment", bytes.NewReader([]byte("a")))
+	req.Header.Set("content-type", "application/octet-stream")
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+
+	var deploy types.Deployment
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&deploy))
+	require.Equal(t, map[string]string{"FOO": "BAR"}, deploy.Environment)
+
+	// Changing the environment of the endpoint should not change the snapshot.
+	endpoint.Environment["FOO"] = "BAZ"
+	stored, err := s.store.GetDeployment(deploy.ID)
+	require.Nil(t, err)
+	require.Equal(t, "BAR", stored.Environment["FOO"])
+}
+
+func TestCreateDeployPublish(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	ingress := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/health") {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ingress.Close()
+	s.ingressURL = ingress.URL
+
+	createDeploy := func(params CreateDeploymentParams) CreateDeploymentResponse {
+		url := "/endpoint/" + endpoint.ID.String() + "/deployment?" + params.Query().Encode()
+		req := httptest.NewRequest("POST", url, bytes.NewReader([]byte("a")))
+		req.Header.Set("content-type", "application/octet-stream")
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+		var deploy CreateDeploymentResponse
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&deploy))
+		return deploy
+	}
+
+	// A failing smoke test stops the publish.
+	deploy := createDeploy(CreateDeploymentParams{
+		Publish: true,
+		SmokeTests: []types.SmokeTest{
+			{Method: "GET", Path: "/health"},
+			{Method: "POST", Path: "/users", Status: 201},
+		},
+	})
+	require.False(t, deploy.Published)
+	require.Len(t, deploy.SmokeTests, 2)
+	require.True(t, deploy.SmokeTests[0].Passed)
+	require.False(t, deploy.SmokeTests[1].Passed)
+	require.Equal(t, http.StatusInternalServerError, deploy.SmokeTests[1].StatusCode)
+	require.False(t, endpoint.HasActiveDeploy())
+
+	deploy = createDeploy(CreateDeploymentParams{
+		Publish:    true,
+		SmokeTests: []types.SmokeTest{{Method: "GET", Path: "/health", Status: 200}},
+	})
+	require.True(t, deploy.Published)
+	require.Equal(t, ingress.URL+"/live/"+endpoint.ID.String(), deploy.URL)
+	require.Equal(t, deploy.ID, endpoint.ActiveDeploymentID)
+}
+
+func TestCreateDeployInvalidSmokeTest(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	for _, query := range []string{"smoke_test=/health", "publish=true&smoke_test=health", "publish=yes"} {
+		req := httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/deployment?"+query, bytes.NewReader([]byte("a")))
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusBadRequest, resp.Result().StatusCode, query)
+	}
+}
+
+func TestCreateEnvironmentDeployment(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	deployment := types.NewDeployment(endpoint, []byte("somefakeblob"))
+	require.Nil(t, s.store.CreateDeployment(deployment))
+
+	params := CreateEnvironmentDeploymentParams{
+		Environment: map[string]string{"FOO": "BAZ"},
+	}
+	b, err := json.Marshal(params)
+	require.Nil(t, err)
+
+	req := httptest.NewRequest("POST", "/deployment/"+deployment.ID.String()+"/environment", bytes.NewReader(b))
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+
+	var deploy types.Deployment
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&deploy))
+	require.NotEqual(t, deployment.ID, deploy.ID)
+	require.Equal(t, deployment.Hash, deploy.Hash)
+	require.Equal(t, endpoint.ID, deploy.EndpointID)
+	require.Equal(t, params.Environment, deploy.Environment)
+}
+
 func TestPublish(t *testing.T) {
 	s := createServer()
 	endpoint := seedEndpoint(t, s)
@@ -126,6 +554,214 @@ func TestPublish(t *testing.T) {
 	require.Equal(t, "http://0.0.0.0:80/live/"+endpoint.ID.String(), publishResp.URL)
 }
 
+func TestKV(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	kvURL := "/endpoint/" + endpoint.ID.String() + "/kv/"
+
+	for _, key := range []string{"users/1", "users/2", "config"} {
+		b, err := json.Marshal(PutKVParams{Value: []byte("value of " + key)})
+		require.Nil(t, err)
+		req := httptest.NewRequest("PUT", kvURL+key, bytes.NewReader(b))
+		resp := httptest.NewRecorder()
+		s.router.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	}
+
+	req := httptest.NewRequest("GET", kvURL+"users/2", nil)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var entry types.KVEntry
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&entry))
+	require.Equal(t, "value of users/2", string(entry.Value))
+
+	req = httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String()+"/kv?prefix=users/", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var entries []types.KVEntry
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&entries))
+	require.Len(t, entries, 2)
+	require.Equal(t, "users/1", entries[0].Key)
+
+	req = httptest.NewRequest("DELETE", kvURL+"users/1", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	_, err := s.store.GetKV(endpoint.ID, "users/1")
+	require.ErrorIs(t, err, storage.ErrKeyNotFound)
+}
+
+func TestKVExpires(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	entry, err := types.NewKVEntry(endpoint.ID, "session", []byte("foo"), time.Millisecond)
+	require.Nil(t, err)
+	require.Nil(t, s.store.PutKV(entry))
+	time.Sleep(time.Millisecond * 5)
+
+	_, err = s.store.GetKV(endpoint.ID, "session")
+	require.ErrorIs(t, err, storage.ErrKeyNotFound)
+	entries, err := s.store.ListKV(endpoint.ID, "", 0)
+	require.Nil(t, err)
+	require.Empty(t, entries)
+}
+
+func TestCreateSchedule(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	params := CreateScheduleParams{Cron: "*/5 * * * *", Path: "/cleanup"}
+	b, err := json.Marshal(params)
+	require.Nil(t, err)
+	req := httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/schedule", bytes.NewReader(b))
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+
+	var schedule types.Schedule
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&schedule))
+	require.Equal(t, http.MethodPost, schedule.Method)
+	require.Equal(t, "/cleanup", schedule.Path)
+	require.Equal(t, 0, schedule.NextRunAT.Minute()%5)
+	require.True(t, schedule.NextRunAT.After(time.Now()))
+
+	run := &types.ScheduleRun{ID: uuid.New(), ScheduleID: schedule.ID, StatusCode: http.StatusOK, StartedAT: time.Now()}
+	require.Nil(t, s.store.CreateScheduleRun(run))
+
+	req = httptest.NewRequest("GET", "/schedule/"+schedule.ID.String()+"/runs", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var runs []types.ScheduleRun
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&runs))
+	require.Len(t, runs, 1)
+
+	stored, err := s.store.GetSchedule(schedule.ID)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, stored.LastStatus)
+}
+
+func TestCreateScheduleInvalidCron(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+
+	b, err := json.Marshal(CreateScheduleParams{Cron: "every minute"})
+	require.Nil(t, err)
+	req := httptest.NewRequest("POST", "/endpoint/"+endpoint.ID.String()+"/schedule", bytes.NewReader(b))
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusBadRequest, resp.Result().StatusCode)
+}
+
+func TestClaimSchedule(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	schedule, err := types.NewSchedule(endpoint, "@hourly", "", "")
+	require.Nil(t, err)
+	require.Nil(t, s.store.CreateSchedule(schedule))
+
+	now := schedule.NextRunAT
+	due, err := s.store.GetDueSchedules(now)
+	require.Nil(t, err)
+	require.Len(t, due, 1)
+
+	// Only the first claim of a run succeeds.
+	next, err := schedule.Next(now)
+	require.Nil(t, err)
+	claimed, err := s.store.ClaimSchedule(schedule.ID, now, next)
+	require.Nil(t, err)
+	require.True(t, claimed)
+	claimed, err = s.store.ClaimSchedule(schedule.ID, now, next)
+	require.Nil(t, err)
+	require.False(t, claimed)
+
+	due, err = s.store.GetDueSchedules(now)
+	require.Nil(t, err)
+	require.Empty(t, due)
+}
+
+func TestClaimInvocations(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	invocation := types.NewInvocation(endpoint, "POST", "/", nil, []byte("hello"))
+	invocation.MaxAttempts = 3
+	require.Nil(t, s.store.CreateInvocation(invocation))
+
+	now := time.Now().UTC()
+	claimed, err := s.store.ClaimInvocations(now, time.Minute, 10)
+	require.Nil(t, err)
+	require.Len(t, claimed, 1)
+	require.Equal(t, types.InvocationRunning, claimed[0].Status)
+	require.Equal(t, 1, claimed[0].Attempts)
+
+	// Running invocations are leased and not claimed again.
+	claimed, err = s.store.ClaimInvocations(now, time.Minute, 10)
+	require.Nil(t, err)
+	require.Empty(t, claimed)
+
+	// Unless their runner did not finish them within the lease.
+	claimed, err = s.store.ClaimInvocations(now.Add(time.Minute*2), time.Minute, 10)
+	require.Nil(t, err)
+	require.Len(t, claimed, 1)
+	require.Equal(t, 2, claimed[0].Attempts)
+}
+
+func TestRetryInvocation(t *testing.T) {
+	s := createServer()
+	endpoint := seedEndpoint(t, s)
+	invocation := types.NewInvocation(endpoint, "POST", "/", nil, nil)
+	require.Nil(t, s.store.CreateInvocation(invocation))
+
+	// Only dead invocations can be retried.
+	req := httptest.NewRequest("POST", "/invocation/"+invocation.ID.String()+"/retry", nil)
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusBadRequest, resp.Result().StatusCode)
+
+	invocation.Status = types.InvocationDead
+	invocation.Attempts = 1
+	require.Nil(t, s.store.UpdateInvocation(invocation))
+
+	req = httptest.NewRequest("GET", "/endpoint/"+endpoint.ID.String()+"/invocation?status=dead", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var dead []types.Invocation
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&dead))
+	require.Len(t, dead, 1)
+	require.Equal(t, invocation.ID, dead[0].ID)
+
+	req = httptest.NewRequest("POST", "/invocation/"+invocation.ID.String()+"/retry", nil)
+	resp = httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Result().StatusCode)
+
+	retried, err := s.store.GetInvocation(invocation.ID)
+	require.Nil(t, err)
+	require.Equal(t, types.InvocationQueued, retried.Status)
+	require.Equal(t, 0, retried.Attempts)
+
+	deadAfterRetry, err := s.store.GetInvocations(endpoint.ID, types.InvocationDead)
+	require.Nil(t, err)
+	require.Empty(t, deadAfterRetry)
+}
+
+func updateEndpoint(t *testing.T, s *Server, endpoint *types.Endpoint, params UpdateEndpointParams, etag string) *httptest.ResponseRecorder {
+	b, err := json.Marshal(params)
+	require.Nil(t, err)
+
+	req := httptest.NewRequest("PUT", "/endpoint/"+endpoint.ID.String(), bytes.NewReader(b))
+	if len(etag) > 0 {
+		req.Header.Set("If-Match", etag)
+	}
+	resp := httptest.NewRecorder()
+	s.router.ServeHTTP(resp, req)
+	return resp
+}
+
 func seedEndpoint(t *testing.T, s *Server) *types.Endpoint {
 	e := types.NewEndpoint("My endpoint", "go", map[string]string{"FOO": "BAR"})
 	require.Nil(t, s.store.CreateEndpoint(e))
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/anthdm/raptor/internal/types"
+)
+
+// smokeTestTimeout is the time a smoke test may take, which includes the
+// compilation of the deployment by the first test.
+var smokeTestTimeout = time.Second * 30
+
+// runSmokeTests makes the smoke tests against the preview of the deployment
+// through the ingress and reports whether all of them passed. The tests stop
+// at the first failure.
+func (s *Server) runSmokeTests(deploy *types.Deployment, tests []types.SmokeTest) ([]types.SmokeTestResult, bool) {
+	results := make([]types.SmokeTestResult, 0, len(tests))
+	for _, t := range tests {
+		result := s.runSmokeTest(deploy, t)
+		results = append(results, result)
+		if !result.Passed {
+			return results, false
+		}
+	}
+	return results, true
+}
+
+func (s *Server) runSmokeTest(deploy *types.Deployment, t types.SmokeTest) types.SmokeTestResult {
+	result := types.SmokeTestResult{SmokeTest: t}
+	url := fmt.Sprintf("%s/preview/%s%s", s.ingressURL, deploy.ID, t.Path)
+	req, err := http.NewRequest(t.Method, url, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	resp, err := s.smokeTestClient.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	result.StatusCode = resp.StatusCode
+	result.Passed = t.Passes(resp.StatusCode)
+	if !result.Passed {
+		result.Error = fmt.Sprintf("expected %s, got status %d", expectedStatus(t), resp.StatusCode)
+	}
+	return result
+}
+
+func expectedStatus(t types.SmokeTest) string {
+	if t.Status == 0 {
+		return "a 2xx status"
+	}
+	return fmt.Sprintf("status %d", t.Status)
+}
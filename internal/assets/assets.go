@@ -0,0 +1,241 @@
+// Package assets serves the static assets that are deployed together with
+// the code of an endpoint.
+package assets
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CacheControl is the Cache-Control header of assets. Browsers and proxies
+// revalidate them with their ETag, which keeps them cheap while a new
+// deployment takes effect right away.
+const CacheControl = "public, max-age=0, must-revalidate"
+
+// MaxSize is the maximum size of the unpacked files of a bundle.
+const MaxSize = 64 << 20
+
+// ErrInvalidBundle is returned by Parse for bundles that are not a tar, a
+// gzipped tar or a zip archive.
+var ErrInvalidBundle = errors.New("assets should be a tar, tar.gz or zip archive")
+
+// File is a static asset.
+type File struct {
+	Data        []byte
+	ContentType string
+	ETag        string
+	ModTime     time.Time
+}
+
+// Bundle holds the static assets of a deployment by their path.
+type Bundle struct {
+	files map[string]*File
+}
+
+// Parse reads the files of a tar, gzipped tar or zip archive. Directories
+// and other entries that are not regular files are skipped.
+func Parse(b []byte) (*Bundle, error) {
+	bundle := &Bundle{files: make(map[string]*File)}
+	var err error
+	switch {
+	case bytes.HasPrefix(b, []byte("PK\x03\x04")):
+		err = bundle.readZip(b)
+	case bytes.HasPrefix(b, []byte{0x1f, 0x8b}):
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(bytes.NewReader(b))
+		if err == nil {
+			err = bundle.readTar(gz)
+		}
+	case len(b) >= 512 && string(b[257:262]) == "ustar":
+		err = bundle.readTar(bytes.NewReader(b))
+	default:
+		return nil, ErrInvalidBundle
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid assets: %s", err)
+	}
+	return bundle, nil
+}
+
+func (b *Bundle) readTar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	var size int64
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if size += hdr.Size; size > MaxSize {
+			return fmt.Errorf("assets are larger than %d bytes", MaxSize)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		b.add(hdr.Name, data, hdr.ModTime)
+	}
+}
+
+func (b *Bundle) readZip(data []byte) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	var size uint64
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		if size += f.UncompressedSize64; size > MaxSize {
+			return fmt.Errorf("assets are larger than %d bytes", MaxSize)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(io.LimitReader(rc, MaxSize))
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		b.add(f.Name, data, f.Modified)
+	}
+	return nil
+}
+
+func (b *Bundle) add(name string, data []byte, modTime time.Time) {
+	// Cleaning the rooted name keeps paths like ../x inside the bundle.
+	name = path.Clean("/" + strings.TrimPrefix(filepath.ToSlash(name), "./"))
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if len(contentType) == 0 {
+		contentType = http.DetectContentType(data)
+	}
+	sum := sha256.Sum256(data)
+	b.files[name] = &File{
+		Data:        data,
+		ContentType: contentType,
+		ETag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+		ModTime:     modTime,
+	}
+}
+
+// Len returns the number of files of the bundle.
+func (b *Bundle) Len() int {
+	return len(b.files)
+}
+
+// Size returns the number of bytes the files of the bundle hold in memory.
+func (b *Bundle) Size() int64 {
+	var size int64
+	for name, f := range b.files {
+		size += int64(len(name) + len(f.Data) + len(f.ContentType) + len(f.ETag))
+	}
+	return size
+}
+
+// Lookup returns the file of the given URL path. Paths of directories, like
+// / or /docs/, resolve to their index.html.
+func (b *Bundle) Lookup(urlPath string) (*File, bool) {
+	name := path.Clean("/" + urlPath)
+	if !strings.HasSuffix(urlPath, "/") {
+		if f, ok := b.files[name]; ok {
+			return f, true
+		}
+	}
+	f, ok := b.files[path.Join(name, "index.html")]
+	return f, ok
+}
+
+// Serve writes the file of the given URL path and reports whether there is
+// one. Only GET and HEAD requests are served, so the others fall through to
+// the function. Conditional and range requests are handled by
+// http.ServeContent.
+func (b *Bundle) Serve(w http.ResponseWriter, r *http.Request, urlPath string) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	f, ok := b.Lookup(urlPath)
+	if !ok {
+		return false
+	}
+	w.Header().Set("Content-Type", f.ContentType)
+	w.Header().Set("ETag", f.ETag)
+	w.Header().Set("Cache-Control", CacheControl)
+	http.ServeContent(w, r, "", f.ModTime, bytes.NewReader(f.Data))
+	return true
+}
+
+// Archive returns the files of the given directory as a gzipped tar, which
+// is the bundle that is deployed. Hidden files are skipped.
+func Archive(dir string) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
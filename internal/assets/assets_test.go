@@ -0,0 +1,102 @@
+package assets
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveAndParse(t *testing.T) {
+	dir := t.TempDir()
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, "css"), 0755))
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, ".git"), 0755))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "index.html"), []byte("<h1>hi</h1>"), 0644))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "css", "main.css"), []byte("body{}"), 0644))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref"), 0644))
+
+	b, err := Archive(dir)
+	require.Nil(t, err)
+	bundle, err := Parse(b)
+	require.Nil(t, err)
+	require.Equal(t, 2, bundle.Len())
+	require.Greater(t, bundle.Size(), int64(len("<h1>hi</h1>")+len("body{}")))
+
+	f, ok := bundle.Lookup("/")
+	require.True(t, ok)
+	require.Equal(t, "<h1>hi</h1>", string(f.Data))
+	require.Equal(t, "text/html; charset=utf-8", f.ContentType)
+
+	f, ok = bundle.Lookup("/css/main.css")
+	require.True(t, ok)
+	require.Equal(t, "text/css; charset=utf-8", f.ContentType)
+
+	// Paths can not escape the bundle, and directories without an index
+	// do not resolve.
+	_, ok = bundle.Lookup("/../css/main.css")
+	require.True(t, ok)
+	_, ok = bundle.Lookup("/css/")
+	require.False(t, ok)
+	_, ok = bundle.Lookup("/.git/HEAD")
+	require.False(t, ok)
+}
+
+func TestParseZip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("./docs/index.html")
+	require.Nil(t, err)
+	w.Write([]byte("docs"))
+	_, err = zw.Create("img/")
+	require.Nil(t, err)
+	require.Nil(t, zw.Close())
+
+	bundle, err := Parse(buf.Bytes())
+	require.Nil(t, err)
+	require.Equal(t, 1, bundle.Len())
+	f, ok := bundle.Lookup("/docs/")
+	require.True(t, ok)
+	require.Equal(t, "docs", string(f.Data))
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse([]byte("not an archive"))
+	require.ErrorIs(t, err, ErrInvalidBundle)
+
+	_, err = Parse([]byte{0x1f, 0x8b, 0x00})
+	require.NotNil(t, err)
+}
+
+func TestServe(t *testing.T) {
+	dir := t.TempDir()
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "app.js"), []byte("console.log(1)"), 0644))
+	b, err := Archive(dir)
+	require.Nil(t, err)
+	bundle, err := Parse(b)
+	require.Nil(t, err)
+
+	rec := httptest.NewRecorder()
+	require.True(t, bundle.Serve(rec, httptest.NewRequest("GET", "/live/x/app.js", nil), "/app.js"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "console.log(1)", rec.Body.String())
+	require.Equal(t, "text/javascript; charset=utf-8", rec.Header().Get("Content-Type"))
+	require.Equal(t, CacheControl, rec.Header().Get("Cache-Control"))
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	// Revalidations with the ETag are not modified.
+	req := httptest.NewRequest("GET", "/live/x/app.js", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	require.True(t, bundle.Serve(rec, req, "/app.js"))
+	require.Equal(t, http.StatusNotModified, rec.Code)
+
+	// Other paths and methods fall through to the function.
+	require.False(t, bundle.Serve(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), "/api"))
+	require.False(t, bundle.Serve(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil), "/app.js"))
+}
@@ -0,0 +1,142 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Detect returns the runtime of the sources in the given directory. A
+// directory with a Cargo.toml is built for the rust runtime, one with a
+// go.mod or Go files for the go runtime, one with Python files for the
+// python runtime and one with JS files for the js runtime. Go sources are
+// only built for the tinygo runtime when asked for.
+func Detect(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var hasJS, hasPython bool
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if name == "Cargo.toml" {
+			return "rust", nil
+		}
+		if name == "go.mod" || (strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go")) {
+			return "go", nil
+		}
+		if strings.HasSuffix(name, ".js") {
+			hasJS = true
+		}
+		if strings.HasSuffix(name, ".py") {
+			hasPython = true
+		}
+	}
+	if hasPython {
+		return "python", nil
+	}
+	if hasJS {
+		return "js", nil
+	}
+	return "", fmt.Errorf("could not detect the runtime of %s, expected Go, Rust, Python or JS sources", dir)
+}
+
+// Build builds the sources in the given directory for the given runtime and
+// returns the blob to deploy.
+func Build(dir string, runtime string) ([]byte, error) {
+	switch runtime {
+	case "go":
+		return Go(dir)
+	case "tinygo":
+		return TinyGo(dir)
+	case "rust":
+		return Rust(dir)
+	case "js":
+		return JS(dir)
+	case "python":
+		return Python(dir)
+	default:
+		return nil, fmt.Errorf("can not build sources for the %s runtime", runtime)
+	}
+}
+
+// Go compiles the main package in the given directory into a WASI module
+// with the local Go toolchain.
+func Go(dir string) ([]byte, error) {
+	tmp, err := os.MkdirTemp("", "raptor-build")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	out := filepath.Join(tmp, "app.wasm")
+	cmd := exec.Command("go", "build", "-o", out, ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("go build failed: %s\n%s", err, output)
+	}
+	return os.ReadFile(out)
+}
+
+// TinyGo compiles the main package in the given directory into a WASI
+// module with the local TinyGo toolchain.
+func TinyGo(dir string) ([]byte, error) {
+	tmp, err := os.MkdirTemp("", "raptor-build")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	out := filepath.Join(tmp, "app.wasm")
+	cmd := exec.Command("tinygo", "build", "-target=wasip1", "-o", out, ".")
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("tinygo build failed: %s\n%s", err, output)
+	}
+	return os.ReadFile(out)
+}
+
+// Rust compiles the crate in the given directory into a WASI module with
+// the local Rust toolchain, which needs the wasm32-wasip1 target. The
+// module is the only .wasm file of the release build.
+func Rust(dir string) ([]byte, error) {
+	tmp, err := os.MkdirTemp("", "raptor-build")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	cmd := exec.Command("cargo", "build", "--release", "--target", "wasm32-wasip1", "--target-dir", tmp)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("cargo build failed: %s\n%s", err, output)
+	}
+	matches, err := filepath.Glob(filepath.Join(tmp, "wasm32-wasip1", "release", "*.wasm"))
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) != 1 {
+		return nil, fmt.Errorf("cargo build produced %d wasm modules, expected 1", len(matches))
+	}
+	return os.ReadFile(matches[0])
+}
+
+// FormatSize formats the given number of bytes for humans.
+func FormatSize(n int) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := unit, 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "KMGT"[exp])
+}
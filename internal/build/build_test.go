@@ -0,0 +1,119 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"main.go": "package main"})
+	runtime, err := Detect(dir)
+	require.Nil(t, err)
+	require.Equal(t, "go", runtime)
+
+	dir = writeFiles(t, map[string]string{"index.js": ""})
+	runtime, err = Detect(dir)
+	require.Nil(t, err)
+	require.Equal(t, "js", runtime)
+
+	dir = writeFiles(t, map[string]string{"Cargo.toml": "", "src/main.rs": "", "build.js": ""})
+	runtime, err = Detect(dir)
+	require.Nil(t, err)
+	require.Equal(t, "rust", runtime)
+
+	dir = writeFiles(t, map[string]string{"main.py": "", "static/app.js": ""})
+	runtime, err = Detect(dir)
+	require.Nil(t, err)
+	require.Equal(t, "python", runtime)
+
+	dir = writeFiles(t, map[string]string{"README.md": ""})
+	_, err = Detect(dir)
+	require.NotNil(t, err)
+}
+
+func TestJS(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"index.js":       `var greet = require("./lib/greet"); greet.hello();`,
+		"lib/greet.js":   `var util = require('../util'); exports.hello = function() { util.respond("hi") };`,
+		"util/index.js":  `exports.respond = function(s) {};`,
+		"unused.js":      `throw "not bundled";`,
+		"lib/unused2.js": ``,
+	})
+	b, err := JS(dir)
+	require.Nil(t, err)
+	bundle := string(b)
+	require.Contains(t, bundle, `"index.js": [{"./lib/greet":"lib/greet.js"}, function`)
+	require.Contains(t, bundle, `"lib/greet.js": [{"../util":"util/index.js"}, function`)
+	require.Contains(t, bundle, `"util/index.js": [{}, function`)
+	require.NotContains(t, bundle, "not bundled")
+	require.True(t, strings.HasSuffix(bundle, "__load(\"index.js\");\n})();\n"))
+}
+
+func TestJSEntryPoint(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"package.json": `{"main": "src/app"}`,
+		"src/app.js":   ``,
+	})
+	b, err := JS(dir)
+	require.Nil(t, err)
+	require.Contains(t, string(b), `__load("src/app.js");`)
+}
+
+func TestJSInvalidRequire(t *testing.T) {
+	for _, source := range []string{
+		`require("lodash")`,
+		`require("./missing")`,
+		`require("../outside")`,
+	} {
+		dir := writeFiles(t, map[string]string{"index.js": source})
+		_, err := JS(dir)
+		require.NotNil(t, err, source)
+	}
+}
+
+func TestPython(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.py":              "import greet\ngreet.hello()\n",
+		"greet.py":             "from lib import util\ndef hello(): util.respond(\"hi\")\n",
+		"lib/__init__.py":      "",
+		"lib/util.py":          "def respond(s): pass\n",
+		"__pycache__/greet.py": "not bundled",
+	})
+	b, err := Python(dir)
+	require.Nil(t, err)
+	bundle := string(b)
+	require.Contains(t, bundle, `"greet": "from lib import util\ndef hello(): util.respond(\"hi\")\n",`)
+	require.Contains(t, bundle, `"lib.__init__": "",`)
+	require.Contains(t, bundle, `"lib.util": "def respond(s): pass\n",`)
+	require.NotContains(t, bundle, "not bundled")
+	require.True(t, strings.HasSuffix(bundle, "sys.meta_path.insert(0, _RaptorImporter())\nimport greet\ngreet.hello()\n"))
+
+	// A single script is deployed as is.
+	dir = writeFiles(t, map[string]string{"main.py": "print(1)\n"})
+	b, err = Python(dir)
+	require.Nil(t, err)
+	require.Equal(t, "print(1)\n", string(b))
+
+	_, err = Python(writeFiles(t, map[string]string{"app.py": ""}))
+	require.NotNil(t, err)
+}
+
+func TestFormatSize(t *testing.T) {
+	require.Equal(t, "512B", FormatSize(512))
+	require.Equal(t, "1.5KB", FormatSize(1536))
+	require.Equal(t, "2.0MB", FormatSize(2*1024*1024))
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.Nil(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+		require.Nil(t, os.WriteFile(path, []byte(content), os.ModePerm))
+	}
+	return dir
+}
@@ -0,0 +1,151 @@
+package build
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// requireRegexp matches the CommonJS require calls with a string literal.
+var requireRegexp = regexp.MustCompile(`\brequire\(\s*["']([^"']+)["']\s*\)`)
+
+// The entry points that are tried when there is no package.json with a main
+// field, in order.
+var jsEntryPoints = []string{"index.js", "main.js"}
+
+type jsModule struct {
+	name     string
+	source   []byte
+	requires map[string]string
+}
+
+// JS bundles the JS modules in the given directory into a single script,
+// since the runtime executes one script. Modules are CommonJS modules that
+// require each other with relative paths, starting at the entry point.
+func JS(dir string) ([]byte, error) {
+	entry, err := jsEntryPoint(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		modules = map[string]*jsModule{}
+		queue   = []string{entry}
+	)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if _, ok := modules[name]; ok {
+			continue
+		}
+		source, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return nil, err
+		}
+		mod := &jsModule{
+			name:     name,
+			source:   source,
+			requires: map[string]string{},
+		}
+		for _, match := range requireRegexp.FindAllSubmatch(source, -1) {
+			spec := string(match[1])
+			resolved, err := resolveJSModule(dir, name, spec)
+			if err != nil {
+				return nil, err
+			}
+			mod.requires[spec] = resolved
+			queue = append(queue, resolved)
+		}
+		modules[name] = mod
+	}
+
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("(function() {\nvar __modules = {\n")
+	for _, name := range names {
+		mod := modules[name]
+		requires, err := json.Marshal(mod.requires)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, "%q: [%s, function(module, exports, require) {\n", name, requires)
+		b.Write(mod.source)
+		b.WriteString("\n}],\n")
+	}
+	b.WriteString(`};
+var __cache = {};
+function __load(name) {
+  if (__cache[name]) {
+    return __cache[name].exports;
+  }
+  var module = { exports: {} };
+  __cache[name] = module;
+  var requires = __modules[name][0];
+  __modules[name][1](module, module.exports, function(spec) {
+    return __load(requires[spec]);
+  });
+  return module.exports;
+}
+`)
+	fmt.Fprintf(&b, "__load(%q);\n})();\n", entry)
+	return []byte(b.String()), nil
+}
+
+// jsEntryPoint returns the name of the module the bundle starts at.
+func jsEntryPoint(dir string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err == nil {
+		var pkg struct {
+			Main string `json:"main"`
+		}
+		if err := json.Unmarshal(b, &pkg); err != nil {
+			return "", fmt.Errorf("invalid package.json: %s", err)
+		}
+		if len(pkg.Main) > 0 {
+			return resolveJSModule(dir, "", "./"+strings.TrimPrefix(pkg.Main, "./"))
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	for _, name := range jsEntryPoints {
+		if fileExists(filepath.Join(dir, name)) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no entry point found in %s, expected %s", dir, strings.Join(jsEntryPoints, " or "))
+}
+
+// resolveJSModule resolves the module that is required by the given module.
+// Like node, the spec can leave out the .js extension or point to a directory
+// with an index.js.
+func resolveJSModule(dir string, from string, spec string) (string, error) {
+	if !strings.HasPrefix(spec, "./") && !strings.HasPrefix(spec, "../") {
+		return "", fmt.Errorf("%s: can not require %q, only relative paths are supported", from, spec)
+	}
+	name := path.Join(path.Dir(from), spec)
+	if name == ".." || strings.HasPrefix(name, "../") {
+		return "", fmt.Errorf("%s: can not require %q outside of %s", from, spec, dir)
+	}
+	for _, candidate := range []string{name, name + ".js", path.Join(name, "index.js")} {
+		if fileExists(filepath.Join(dir, filepath.FromSlash(candidate))) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%s: can not find module %q", from, spec)
+}
+
+func fileExists(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,110 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// pythonEntryPoint is the script that is run, the other modules are
+// imported by it.
+const pythonEntryPoint = "main.py"
+
+// pythonLoader registers the bundled modules with an importer, so the
+// entry point imports them like it does from the directory.
+const pythonLoader = `import importlib.abc, importlib.util, sys
+
+class _RaptorImporter(importlib.abc.MetaPathFinder, importlib.abc.Loader):
+    def find_spec(self, name, path, target=None):
+        if name in _raptor_modules:
+            return importlib.util.spec_from_loader(name, self, is_package=False)
+        if name + ".__init__" in _raptor_modules:
+            return importlib.util.spec_from_loader(name, self, is_package=True)
+        return None
+
+    def create_module(self, spec):
+        return None
+
+    def exec_module(self, module):
+        name = module.__name__
+        source = _raptor_modules.get(name)
+        if source is None:
+            source = _raptor_modules[name + ".__init__"]
+            module.__path__ = []
+        exec(compile(source, name.replace(".", "/") + ".py", "exec"), module.__dict__)
+
+sys.meta_path.insert(0, _RaptorImporter())
+`
+
+// Python bundles the Python modules in the given directory into a single
+// script, since the runtime executes one script. The script runs main.py,
+// which imports the other modules of the directory, packages included, by
+// their name.
+func Python(dir string) ([]byte, error) {
+	entry, err := os.ReadFile(filepath.Join(dir, pythonEntryPoint))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("could not find the entry point %s in %s", pythonEntryPoint, dir)
+		}
+		return nil, err
+	}
+
+	modules := map[string]string{}
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != dir && (strings.HasPrefix(d.Name(), ".") || d.Name() == "__pycache__") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !strings.HasSuffix(rel, ".py") || rel == pythonEntryPoint {
+			return nil
+		}
+		source, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		name := strings.ReplaceAll(strings.TrimSuffix(rel, ".py"), "/", ".")
+		modules[name] = string(source)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(modules) == 0 {
+		return entry, nil
+	}
+
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("_raptor_modules = {\n")
+	for _, name := range names {
+		// JSON strings are valid Python string literals.
+		source, err := json.Marshal(modules[name])
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, "    %q: %s,\n", name, source)
+	}
+	b.WriteString("}\n")
+	b.WriteString(pythonLoader)
+	b.Write(entry)
+	return []byte(b.String()), nil
+}
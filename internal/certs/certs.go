@@ -0,0 +1,399 @@
+package certs
+
+import (
+	"container/list"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anthdm/raptor/internal/types"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// storedCertTTL is the duration a certificate from the store is cached before
+// it is fetched again, which picks up certificates uploaded through the API.
+var storedCertTTL = time.Minute
+
+// missedCertTTL is the duration a hostname without a certificate in the
+// store, or that is not allowed by the ACME client, is cached. It is short,
+// so new custom domains are picked up soon.
+var missedCertTTL = time.Second * 10
+
+// Server names come from the clients, hence the caches of the store are
+// bounded. storedCertCacheSize is the number of certificates of the store
+// that are cached, and missedCertCacheSize the number of hostnames that
+// missed.
+var (
+	storedCertCacheSize = 1024
+	missedCertCacheSize = 256
+)
+
+var errNoCertificate = errors.New("no certificate available")
+
+// Store is the storage the manager loads the certificates of custom domains from.
+type Store interface {
+	GetCertificate(hostname string) (*types.Certificate, error)
+	GetDomain(hostname string) (*types.Domain, error)
+}
+
+// FilePair is a certificate and key that are loaded from disk.
+type FilePair struct {
+	CertFile string
+	KeyFile  string
+}
+
+type fileCert struct {
+	pair    FilePair
+	modTime time.Time
+	cert    *tls.Certificate
+}
+
+// certCache caches up to size certificates by hostname for a ttl. The least
+// recently used hostnames are evicted first.
+type certCache struct {
+	size    int
+	ttl     time.Duration
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type certCacheEntry struct {
+	name    string
+	cert    *tls.Certificate
+	expires time.Time
+}
+
+func newCertCache(size int, ttl time.Duration) *certCache {
+	return &certCache{
+		size:    size,
+		ttl:     ttl,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *certCache) get(name string) (*tls.Certificate, bool) {
+	elem, ok := c.entries[name]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*certCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return entry.cert, true
+}
+
+func (c *certCache) put(name string, cert *tls.Certificate) {
+	if elem, ok := c.entries[name]; ok {
+		c.remove(elem)
+	}
+	for c.lru.Len() > 0 && c.lru.Len() >= c.size {
+		c.remove(c.lru.Back())
+	}
+	c.entries[name] = c.lru.PushFront(&certCacheEntry{
+		name:    name,
+		cert:    cert,
+		expires: time.Now().Add(c.ttl),
+	})
+}
+
+func (c *certCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*certCacheEntry).name)
+}
+
+// Manager selects the certificate for incoming TLS connections by SNI. It
+// looks in the certificates stored for custom domains, then in the
+// certificates loaded from disk and finally asks the ACME client if any.
+type Manager struct {
+	store Store
+	acme  *autocert.Manager
+
+	mu    sync.RWMutex
+	files []*fileCert
+	names map[string]*tls.Certificate
+	// cacheMu guards the caches of the store, whose lookups modify them.
+	cacheMu sync.Mutex
+	stored  *certCache
+	missed  *certCache
+	denied  *certCache
+}
+
+// NewManager returns a new certificate manager. The store is optional.
+func NewManager(store Store) *Manager {
+	return &Manager{
+		store:  store,
+		names:  make(map[string]*tls.Certificate),
+		stored: newCertCache(storedCertCacheSize, storedCertTTL),
+		missed: newCertCache(missedCertCacheSize, missedCertTTL),
+		denied: newCertCache(missedCertCacheSize, missedCertTTL),
+	}
+}
+
+// LoadFiles loads the given certificate pairs. The first pair is the default
+// certificate that is used when no other certificate matches.
+func (m *Manager) LoadFiles(pairs ...FilePair) error {
+	m.mu.Lock()
+	for _, pair := range pairs {
+		m.files = append(m.files, &fileCert{pair: pair})
+	}
+	m.mu.Unlock()
+	_, err := m.Reload()
+	return err
+}
+
+// LoadDir loads all <name>.crt and <name>.key pairs in the given directory.
+func (m *Manager) LoadDir(dir string) error {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.crt"))
+	if err != nil {
+		return err
+	}
+	pairs := make([]FilePair, 0, len(matches))
+	for _, certFile := range matches {
+		pairs = append(pairs, FilePair{
+			CertFile: certFile,
+			KeyFile:  strings.TrimSuffix(certFile, ".crt") + ".key",
+		})
+	}
+	return m.LoadFiles(pairs...)
+}
+
+// Reload loads the certificate files that changed on disk since they were
+// last loaded. It reports whether any file was reloaded. The certificates of
+// the store expire from their cache on their own.
+func (m *Manager) Reload() (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	changed := false
+	for _, file := range m.files {
+		info, err := os.Stat(file.pair.CertFile)
+		if err != nil {
+			return changed, err
+		}
+		if file.cert != nil && !info.ModTime().After(file.modTime) {
+			continue
+		}
+		cert, err := tls.LoadX509KeyPair(file.pair.CertFile, file.pair.KeyFile)
+		if err != nil {
+			return changed, fmt.Errorf("could not load certificate %s: %s", file.pair.CertFile, err)
+		}
+		if cert.Leaf == nil {
+			if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+				return changed, err
+			}
+		}
+		file.cert = &cert
+		file.modTime = info.ModTime()
+		changed = true
+	}
+	if changed {
+		m.names = make(map[string]*tls.Certificate)
+		for _, file := range m.files {
+			if file.cert == nil {
+				continue
+			}
+			for _, name := range file.cert.Leaf.DNSNames {
+				name = types.NormalizeHostname(name)
+				if _, ok := m.names[name]; !ok {
+					m.names[name] = file.cert
+				}
+			}
+		}
+	}
+	return changed, nil
+}
+
+// Watch reloads the certificate files every interval until the done channel
+// is closed, so renewed certificates are picked up without a restart.
+func (m *Manager) Watch(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			changed, err := m.Reload()
+			if err != nil {
+				slog.Warn("failed to reload certificates", "err", err)
+				continue
+			}
+			if changed {
+				slog.Info("certificates reloaded")
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// ACMEConfig holds the configuration of the ACME client.
+type ACMEConfig struct {
+	DirectoryURL string
+	Email        string
+	CacheDir     string
+	// CAFile is an optional root CA to trust for the ACME server.
+	CAFile string
+}
+
+// EnableACME obtains certificates for verified custom domains that do not have
+// a certificate of their own.
+func (m *Manager) EnableACME(config ACMEConfig) error {
+	client := &acme.Client{
+		DirectoryURL: config.DirectoryURL,
+	}
+	if len(config.CAFile) > 0 {
+		b, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return fmt.Errorf("no certificates found in %s", config.CAFile)
+		}
+		client.HTTPClient = &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool},
+			},
+		}
+	}
+	m.acme = &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(config.CacheDir),
+		Email:      config.Email,
+		Client:     client,
+		HostPolicy: m.hostPolicy,
+	}
+	return nil
+}
+
+// hostPolicy only allows the ACME client to obtain certificates for verified
+// custom domains. Hosts that are not allowed are cached briefly, so unknown
+// server names do not hit the store on every handshake.
+func (m *Manager) hostPolicy(_ context.Context, host string) error {
+	if m.store == nil {
+		return fmt.Errorf("host %s not allowed", host)
+	}
+	name := types.NormalizeHostname(host)
+	m.cacheMu.Lock()
+	_, denied := m.denied.get(name)
+	m.cacheMu.Unlock()
+	if denied {
+		return fmt.Errorf("host %s not allowed", host)
+	}
+	domain, err := m.store.GetDomain(name)
+	if err != nil || !domain.Verified {
+		m.cacheMu.Lock()
+		m.denied.put(name, nil)
+		m.cacheMu.Unlock()
+		return fmt.Errorf("host %s not allowed", host)
+	}
+	return nil
+}
+
+// HTTPHandler answers ACME HTTP-01 challenges when ACME is enabled and passes
+// all other requests to the given handler.
+func (m *Manager) HTTPHandler(fallback http.Handler) http.Handler {
+	if m.acme == nil {
+		return fallback
+	}
+	return m.acme.HTTPHandler(fallback)
+}
+
+// TLSConfig returns a TLS config that selects the certificate by SNI.
+func (m *Manager) TLSConfig() *tls.Config {
+	config := &tls.Config{
+		GetCertificate: m.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+	if m.acme != nil {
+		config.NextProtos = append(config.NextProtos, acme.ALPNProto)
+	}
+	return config
+}
+
+// GetCertificate returns the certificate for the server name of the given hello.
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := types.NormalizeHostname(hello.ServerName)
+
+	if cert := m.getStored(name); cert != nil {
+		return cert, nil
+	}
+	if cert := m.getFile(name); cert != nil {
+		return cert, nil
+	}
+	if m.acme != nil && len(name) > 0 {
+		cert, err := m.acme.GetCertificate(hello)
+		if err == nil {
+			return cert, nil
+		}
+		slog.Debug("acme could not provide a certificate", "host", name, "err", err)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.files) > 0 && m.files[0].cert != nil {
+		return m.files[0].cert, nil
+	}
+	return nil, errNoCertificate
+}
+
+func (m *Manager) getFile(name string) *tls.Certificate {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if cert, ok := m.names[name]; ok {
+		return cert
+	}
+	// Try the wildcard certificate of the parent domain.
+	if i := strings.Index(name, "."); i > 0 {
+		if cert, ok := m.names["*"+name[i:]]; ok {
+			return cert
+		}
+	}
+	return nil
+}
+
+func (m *Manager) getStored(name string) *tls.Certificate {
+	if m.store == nil || len(name) == 0 {
+		return nil
+	}
+	m.cacheMu.Lock()
+	cert, ok := m.stored.get(name)
+	if !ok {
+		_, ok = m.missed.get(name)
+	}
+	m.cacheMu.Unlock()
+	if ok {
+		return cert
+	}
+
+	if stored, err := m.store.GetCertificate(name); err == nil {
+		pair, err := tls.X509KeyPair(stored.CertPEM, stored.KeyPEM)
+		if err != nil {
+			slog.Warn("invalid stored certificate", "host", name, "err", err)
+		} else {
+			cert = &pair
+		}
+	}
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+	if cert == nil {
+		m.missed.put(name, nil)
+	} else {
+		m.stored.put(name, cert)
+	}
+	return cert
+}
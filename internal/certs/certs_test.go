@@ -0,0 +1,206 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anthdm/raptor/internal/storage"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCertificateBySNI(t *testing.T) {
+	dir := t.TempDir()
+	defaultPair := writeCert(t, dir, "default", "raptor.example.com")
+	wildcardPair := writeCert(t, dir, "wildcard", "*.apps.example.com")
+
+	m := NewManager(nil)
+	require.Nil(t, m.LoadFiles(defaultPair, wildcardPair))
+
+	cert, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "foo.apps.example.com"})
+	require.Nil(t, err)
+	require.Equal(t, []string{"*.apps.example.com"}, leaf(t, cert).DNSNames)
+
+	cert, err = m.GetCertificate(&tls.ClientHelloInfo{ServerName: "unknown.org"})
+	require.Nil(t, err)
+	require.Equal(t, []string{"raptor.example.com"}, leaf(t, cert).DNSNames)
+}
+
+func TestGetCertificateFromStore(t *testing.T) {
+	dir := t.TempDir()
+	defaultPair := writeCert(t, dir, "default", "raptor.example.com")
+	certPEM, keyPEM := makeCert(t, "app.example.com")
+
+	store := storage.NewMemoryStore()
+	endpoint := types.NewEndpoint("My endpoint", "go", nil)
+	require.Nil(t, store.CreateEndpoint(endpoint))
+	require.Nil(t, store.CreateDomain(types.NewDomain(endpoint, "app.example.com")))
+	stored, err := types.NewCertificate("app.example.com", certPEM, keyPEM)
+	require.Nil(t, err)
+	require.Nil(t, store.PutCertificate(stored))
+
+	m := NewManager(store)
+	require.Nil(t, m.LoadFiles(defaultPair))
+
+	cert, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "APP.example.com"})
+	require.Nil(t, err)
+	require.Equal(t, []string{"app.example.com"}, leaf(t, cert).DNSNames)
+}
+
+func TestReloadChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	pair := writeCert(t, dir, "default", "old.example.com")
+
+	m := NewManager(nil)
+	require.Nil(t, m.LoadFiles(pair))
+
+	changed, err := m.Reload()
+	require.Nil(t, err)
+	require.False(t, changed)
+
+	writeCert(t, dir, "default", "new.example.com")
+	future := time.Now().Add(time.Minute)
+	require.Nil(t, os.Chtimes(pair.CertFile, future, future))
+
+	changed, err = m.Reload()
+	require.Nil(t, err)
+	require.True(t, changed)
+
+	cert, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "new.example.com"})
+	require.Nil(t, err)
+	require.Equal(t, []string{"new.example.com"}, leaf(t, cert).DNSNames)
+}
+
+// countingStore counts the lookups of certificates.
+type countingStore struct {
+	Store
+	lookups int
+}
+
+func (s *countingStore) GetCertificate(hostname string) (*types.Certificate, error) {
+	s.lookups++
+	return s.Store.GetCertificate(hostname)
+}
+
+func TestStoredCertificatesAreBounded(t *testing.T) {
+	defer func(size int) { missedCertCacheSize = size }(missedCertCacheSize)
+	missedCertCacheSize = 2
+
+	store := &countingStore{Store: storage.NewMemoryStore()}
+	m := NewManager(store)
+
+	// Misses are cached, so an unknown name only hits the store once.
+	require.Nil(t, m.getStored("unknown.org"))
+	require.Nil(t, m.getStored("unknown.org"))
+	require.Equal(t, 1, store.lookups)
+
+	// Random names do not grow the cache.
+	for i := 0; i < 100; i++ {
+		m.getStored(fmt.Sprintf("random-%d.org", i))
+	}
+	require.Equal(t, 2, len(m.missed.entries))
+	require.Equal(t, 0, len(m.stored.entries))
+
+	// Reloading the files does not drop the cache.
+	_, err := m.Reload()
+	require.Nil(t, err)
+	require.Equal(t, 2, len(m.missed.entries))
+}
+
+func TestMissedCertificatesExpire(t *testing.T) {
+	defer func(ttl time.Duration) { missedCertTTL = ttl }(missedCertTTL)
+	missedCertTTL = -time.Second
+
+	store := &countingStore{Store: storage.NewMemoryStore()}
+	m := NewManager(store)
+	require.Nil(t, m.getStored("app.example.com"))
+	require.Nil(t, m.getStored("app.example.com"))
+	require.Equal(t, 2, store.lookups)
+}
+
+// TestACMEPebble obtains and renews a certificate from a Pebble server,
+// which is started with make pebble. RAPTOR_PEBBLE_URL is its directory URL
+// and RAPTOR_PEBBLE_CA the root CA of its API.
+func TestACMEPebble(t *testing.T) {
+	directoryURL := os.Getenv("RAPTOR_PEBBLE_URL")
+	if len(directoryURL) == 0 {
+		t.Skip("RAPTOR_PEBBLE_URL is not set")
+	}
+	store := storage.NewMemoryStore()
+	endpoint := types.NewEndpoint("My endpoint", "go", nil)
+	require.Nil(t, store.CreateEndpoint(endpoint))
+	domain := types.NewDomain(endpoint, "app.example.com")
+	domain.Verified = true
+	require.Nil(t, store.CreateDomain(domain))
+
+	m := NewManager(store)
+	require.Nil(t, m.EnableACME(ACMEConfig{
+		DirectoryURL: directoryURL,
+		Email:        "admin@example.com",
+		CacheDir:     t.TempDir(),
+		CAFile:       os.Getenv("RAPTOR_PEBBLE_CA"),
+	}))
+	// Certificates are renewed right after they are issued.
+	m.acme.RenewBefore = 100 * 365 * 24 * time.Hour
+
+	cert, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "app.example.com"})
+	require.Nil(t, err)
+	issued := leaf(t, cert)
+	require.Equal(t, []string{"app.example.com"}, issued.DNSNames)
+
+	require.Eventually(t, func() bool {
+		cert, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "app.example.com"})
+		return err == nil && leaf(t, cert).SerialNumber.Cmp(issued.SerialNumber) != 0
+	}, time.Minute, time.Second)
+
+	// Domains that are not verified are not issued a certificate.
+	_, err = m.acme.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.example.com"})
+	require.NotNil(t, err)
+}
+
+func leaf(t *testing.T, cert *tls.Certificate) *x509.Certificate {
+	l, err := x509.ParseCertificate(cert.Certificate[0])
+	require.Nil(t, err)
+	return l
+}
+
+func writeCert(t *testing.T, dir, name, host string) FilePair {
+	certPEM, keyPEM := makeCert(t, host)
+	pair := FilePair{
+		CertFile: filepath.Join(dir, name+".crt"),
+		KeyFile:  filepath.Join(dir, name+".key"),
+	}
+	require.Nil(t, os.WriteFile(pair.CertFile, certPEM, 0600))
+	require.Nil(t, os.WriteFile(pair.KeyFile, keyPEM, 0600))
+	return pair
+}
+
+func makeCert(t *testing.T, host string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM
+}
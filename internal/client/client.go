@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/anthdm/raptor/internal/api"
 	"github.com/anthdm/raptor/internal/types"
@@ -56,7 +58,7 @@ func (c *Client) Publish(params api.PublishParams) (*api.PublishResponse, error)
 		return nil, err
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+		return nil, readError(resp)
 	}
 	var publishResponse api.PublishResponse
 	if err := json.NewDecoder(resp.Body).Decode(&publishResponse); err != nil {
@@ -82,7 +84,7 @@ func (c *Client) CreateEndpoint(params api.CreateEndpointParams) (*types.Endpoin
 		return nil, err
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+		return nil, readError(resp)
 	}
 	var endpoint types.Endpoint
 	if err := json.NewDecoder(resp.Body).Decode(&endpoint); err != nil {
@@ -92,21 +94,29 @@ func (c *Client) CreateEndpoint(params api.CreateEndpointParams) (*types.Endpoin
 	return &endpoint, nil
 }
 
-func (c *Client) CreateDeployment(endpointID uuid.UUID, blob io.Reader, params api.CreateDeploymentParams) (*types.Deployment, error) {
-	url := fmt.Sprintf("%s/endpoint/%s/deployment", c.config.url, endpointID)
-	req, err := http.NewRequest("POST", url, blob)
+func (c *Client) CreateDeployment(endpointID uuid.UUID, blob io.Reader, params api.CreateDeploymentParams) (*api.CreateDeploymentResponse, error) {
+	b, err := io.ReadAll(blob)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Add("Content-Type", "application/octet-stream")
+	body, contentType, err := params.Body(b)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/endpoint/%s/deployment?%s", c.config.url, endpointID, params.Query().Encode())
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", contentType)
 	resp, err := c.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("api responded with a non 200 status code: %d", resp.StatusCode)
+		return nil, readError(resp)
 	}
-	var deploy types.Deployment
+	var deploy api.CreateDeploymentResponse
 	if err := json.NewDecoder(resp.Body).Decode(&deploy); err != nil {
 		return nil, err
 	}
@@ -125,6 +135,9 @@ func (c *Client) ListEndpoints() ([]types.Endpoint, error) {
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
 	var endpoints []types.Endpoint
 	if err := json.NewDecoder(resp.Body).Decode(&endpoints); err != nil {
 		return nil, err
@@ -132,3 +145,525 @@ func (c *Client) ListEndpoints() ([]types.Endpoint, error) {
 	resp.Body.Close()
 	return endpoints, nil
 }
+
+func (c *Client) GetEndpoint(id uuid.UUID) (*types.Endpoint, error) {
+	url := fmt.Sprintf("%s/endpoint/%s", c.config.url, id)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+	var endpoint types.Endpoint
+	if err := json.NewDecoder(resp.Body).Decode(&endpoint); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &endpoint, nil
+}
+
+func (c *Client) DeleteEndpoint(id uuid.UUID) error {
+	url := fmt.Sprintf("%s/endpoint/%s", c.config.url, id)
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return readError(resp)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// PurgeCache purges the responses the ingress cached for the endpoint.
+func (c *Client) PurgeCache(id uuid.UUID) (*types.Endpoint, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/purge", c.config.url, id)
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+	var endpoint types.Endpoint
+	if err := json.NewDecoder(resp.Body).Decode(&endpoint); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &endpoint, nil
+}
+
+// GetMetrics returns the metrics of the latest requests on LIVE of the endpoint.
+func (c *Client) GetMetrics(endpointID uuid.UUID) ([]types.RequestMetric, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/metrics", c.config.url, endpointID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+	var metrics []types.RequestMetric
+	if err := json.NewDecoder(resp.Body).Decode(&metrics); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return metrics, nil
+}
+
+// GetLogs returns up to limit of the latest runtime logs of the endpoint,
+// or the API default when limit is 0.
+func (c *Client) GetLogs(endpointID uuid.UUID, limit int) ([]types.RuntimeLog, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	url := fmt.Sprintf("%s/endpoint/%s/logs?%s", c.config.url, endpointID, query.Encode())
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+	var logs []types.RuntimeLog
+	if err := json.NewDecoder(resp.Body).Decode(&logs); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return logs, nil
+}
+
+func (c *Client) UpdateEndpoint(id uuid.UUID, params api.UpdateEndpointParams) (*types.Endpoint, error) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/endpoint/%s", c.config.url, id)
+	req, err := http.NewRequest("PUT", url, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return nil, fmt.Errorf("endpoint %s was modified by someone else, fetch it and try again", id)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+	var endpoint types.Endpoint
+	if err := json.NewDecoder(resp.Body).Decode(&endpoint); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &endpoint, nil
+}
+
+func (c *Client) CreateEnvironmentDeployment(deployID uuid.UUID, params api.CreateEnvironmentDeploymentParams) (*types.Deployment, error) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/deployment/%s/environment", c.config.url, deployID)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+	var deploy types.Deployment
+	if err := json.NewDecoder(resp.Body).Decode(&deploy); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &deploy, nil
+}
+
+func (c *Client) CreateDomain(endpointID uuid.UUID, params api.CreateDomainParams) (*api.DomainResponse, error) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/endpoint/%s/domain", c.config.url, endpointID)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+	var domain api.DomainResponse
+	if err := json.NewDecoder(resp.Body).Decode(&domain); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &domain, nil
+}
+
+func (c *Client) GetDomains(endpointID uuid.UUID) ([]api.DomainResponse, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/domain", c.config.url, endpointID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+	var domains []api.DomainResponse
+	if err := json.NewDecoder(resp.Body).Decode(&domains); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return domains, nil
+}
+
+func (c *Client) VerifyDomain(hostname string) (*api.DomainResponse, error) {
+	url := fmt.Sprintf("%s/domain/%s/verify", c.config.url, hostname)
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+	var domain api.DomainResponse
+	if err := json.NewDecoder(resp.Body).Decode(&domain); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &domain, nil
+}
+
+func (c *Client) DeleteDomain(hostname string) error {
+	url := fmt.Sprintf("%s/domain/%s", c.config.url, hostname)
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return readError(resp)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (c *Client) ListKV(endpointID uuid.UUID, prefix string) ([]types.KVEntry, error) {
+	query := url.Values{}
+	query.Set("prefix", prefix)
+	url := fmt.Sprintf("%s/endpoint/%s/kv?%s", c.config.url, endpointID, query.Encode())
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+	var entries []types.KVEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return entries, nil
+}
+
+func (c *Client) GetKV(endpointID uuid.UUID, key string) (*types.KVEntry, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/kv/%s", c.config.url, endpointID, key)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+	var entry types.KVEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entry); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &entry, nil
+}
+
+func (c *Client) PutKV(endpointID uuid.UUID, key string, params api.PutKVParams) (*types.KVEntry, error) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/endpoint/%s/kv/%s", c.config.url, endpointID, key)
+	req, err := http.NewRequest("PUT", url, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+	var entry types.KVEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entry); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &entry, nil
+}
+
+func (c *Client) DeleteKV(endpointID uuid.UUID, key string) error {
+	url := fmt.Sprintf("%s/endpoint/%s/kv/%s", c.config.url, endpointID, key)
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return readError(resp)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (c *Client) CreateSchedule(endpointID uuid.UUID, params api.CreateScheduleParams) (*types.Schedule, error) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/endpoint/%s/schedule", c.config.url, endpointID)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+	var schedule types.Schedule
+	if err := json.NewDecoder(resp.Body).Decode(&schedule); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &schedule, nil
+}
+
+func (c *Client) GetSchedules(endpointID uuid.UUID) ([]types.Schedule, error) {
+	url := fmt.Sprintf("%s/endpoint/%s/schedule", c.config.url, endpointID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+	var schedules []types.Schedule
+	if err := json.NewDecoder(resp.Body).Decode(&schedules); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return schedules, nil
+}
+
+func (c *Client) GetScheduleRuns(scheduleID uuid.UUID) ([]types.ScheduleRun, error) {
+	url := fmt.Sprintf("%s/schedule/%s/runs", c.config.url, scheduleID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+	var runs []types.ScheduleRun
+	if err := json.NewDecoder(resp.Body).Decode(&runs); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return runs, nil
+}
+
+func (c *Client) DeleteSchedule(scheduleID uuid.UUID) error {
+	url := fmt.Sprintf("%s/schedule/%s", c.config.url, scheduleID)
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return readError(resp)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (c *Client) GetInvocation(invocationID uuid.UUID) (*types.Invocation, error) {
+	url := fmt.Sprintf("%s/invocation/%s", c.config.url, invocationID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+	var invocation types.Invocation
+	if err := json.NewDecoder(resp.Body).Decode(&invocation); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &invocation, nil
+}
+
+// GetInvocations returns the latest invocations of the endpoint with the
+// given status, or of any status if empty.
+func (c *Client) GetInvocations(endpointID uuid.UUID, status string) ([]types.Invocation, error) {
+	query := url.Values{}
+	query.Set("status", status)
+	url := fmt.Sprintf("%s/endpoint/%s/invocation?%s", c.config.url, endpointID, query.Encode())
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+	var invocations []types.Invocation
+	if err := json.NewDecoder(resp.Body).Decode(&invocations); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return invocations, nil
+}
+
+func (c *Client) RetryInvocation(invocationID uuid.UUID) (*types.Invocation, error) {
+	url := fmt.Sprintf("%s/invocation/%s/retry", c.config.url, invocationID)
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+	var invocation types.Invocation
+	if err := json.NewDecoder(resp.Body).Decode(&invocation); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &invocation, nil
+}
+
+// Error is an error the API responded with.
+type Error struct {
+	StatusCode int
+	// Code is the machine-readable code of the error, like not_found.
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if len(e.Message) == 0 {
+		return fmt.Sprintf("api responded with a non 200 status code: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("api responded with status %d: %s", e.StatusCode, e.Message)
+}
+
+// readError returns the error of a non 200 response, with the reason the
+// API gave in its error response if any, and closes the body.
+func readError(resp *http.Response) error {
+	defer resp.Body.Close()
+	var errResp struct {
+		Error string `json:"error"`
+		Code  string `json:"code"`
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err := json.Unmarshal(b, &errResp); err != nil {
+		errResp.Error, errResp.Code = "", ""
+	}
+	return &Error{
+		StatusCode: resp.StatusCode,
+		Code:       errResp.Code,
+		Message:    errResp.Error,
+	}
+}
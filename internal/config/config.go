@@ -14,6 +14,7 @@ httpAPIAddr 		= "127.0.0.1:3000"
 storageDriver 		= "postgres"
 apiToken			= ""
 authorization		= false
+appsDomain			= ""
 
 [storage]
 user 				= "postgres"
@@ -22,6 +23,29 @@ name 				= "postgres"
 host				= "localhost"
 port				= "5432"
 sslmode 			= "disable"
+
+[tls]
+enabled				= false
+httpsIngressAddr	= "127.0.0.1:5443"
+certFile			= ""
+keyFile				= ""
+certDir				= ""
+acme				= false
+acmeDirectoryURL	= "https://acme-v02.api.letsencrypt.org/directory"
+acmeEmail			= ""
+acmeCacheDir		= "certs"
+acmeCAFile			= ""
+
+[async]
+maxAttempts			= 3
+backoffSeconds		= 1
+maxBackoffSeconds	= 300
+
+[runtime]
+reuseInstances		= false
+
+[cache]
+maxSizeMB			= 64
 `
 
 // Config holds the global configuration which is READONLY.
@@ -36,13 +60,63 @@ type Storage struct {
 	SSLMode  string
 }
 
+// TLS holds the configuration of the HTTPS listener of the ingress.
+type TLS struct {
+	Enabled          bool
+	HTTPSIngressAddr string
+	// CertFile and KeyFile are the default certificate of the ingress.
+	CertFile string
+	KeyFile  string
+	// CertDir is a directory of <name>.crt and <name>.key pairs that are
+	// selected by SNI.
+	CertDir string
+	// ACME enables obtaining certificates for verified custom domains.
+	ACME             bool
+	ACMEDirectoryURL string
+	ACMEEmail        string
+	ACMECacheDir     string
+	// ACMECAFile is an optional root CA of the ACME server, needed when
+	// testing against a local ACME server like Pebble.
+	ACMECAFile string
+}
+
+// Async holds the retry policy of asynchronous invocations. Invocations
+// that fail with a 5xx status or crash are retried with an exponential
+// backoff starting at BackoffSeconds.
+type Async struct {
+	MaxAttempts       int
+	BackoffSeconds    int
+	MaxBackoffSeconds int
+}
+
+// Runtime holds the configuration of the runtimes that invoke deployments.
+type Runtime struct {
+	// ReuseInstances serves the requests of a deployment with a long-lived
+	// instance of its guest instead of a new one per request, for guests
+	// that serve requests in a loop. State in the memory of the guest is
+	// then shared by its requests.
+	ReuseInstances bool
+}
+
+// Cache holds the configuration of the HTTP cache of the ingress, which
+// serves the responses of LIVE endpoints as long as their Cache-Control
+// header allows. A MaxSizeMB of zero disables the cache.
+type Cache struct {
+	MaxSizeMB int
+}
+
 type Config struct {
 	HTTPAPIAddr     string
 	HTTPIngressAddr string
 	StorageDriver   string
 	APIToken        string
 	Authorization   bool
+	AppsDomain      string
 	Storage         Storage
+	TLS             TLS
+	Async           Async
+	Runtime         Runtime
+	Cache           Cache
 }
 
 func Parse(path string) error {
@@ -0,0 +1,17 @@
+// Package cpython vendors a WASI build of the CPython interpreter, which
+// runs the scripts of the python runtime. The standard library is bundled
+// in the module, so it runs without a filesystem.
+//
+// python.wasm is fetched with make cpython.
+package cpython
+
+import _ "embed"
+
+//go:embed python.wasm
+var WasmBlob []byte
+
+// Available reports whether the interpreter is vendored. python.wasm is
+// empty until it is fetched, and the python runtime is not available then.
+func Available() bool {
+	return len(WasmBlob) > 0
+}
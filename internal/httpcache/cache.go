@@ -0,0 +1,296 @@
+// Package httpcache is the in-memory HTTP cache of the ingress. It caches
+// responses as a shared cache would, by their Cache-Control and Vary
+// headers.
+package httpcache
+
+import (
+	"container/list"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Response is a cached response.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// cacheableStatus holds the statuses that are cacheable by default.
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+type entry struct {
+	ns      string
+	key     string
+	resp    *Response
+	size    int64
+	stored  time.Time
+	expires time.Time
+}
+
+// namespace holds the responses of an endpoint at a generation.
+type namespace struct {
+	gen int
+	// vary holds the names of the request headers the response of a key
+	// varies on.
+	vary    map[string][]string
+	entries map[string]*list.Element
+}
+
+// Cache caches responses in namespaces, like the endpoints, up to a total
+// size. The least recently used responses are evicted first.
+//
+// Every namespace has a generation, like the version of the endpoint. A
+// request of a newer generation purges the responses of the older ones, so
+// the responses of a namespace are purged by moving it to a new
+// generation. Requests of an older generation are not served from nor
+// stored in the cache.
+type Cache struct {
+	mu         sync.Mutex
+	maxSize    int64
+	size       int64
+	lru        *list.List
+	namespaces map[string]*namespace
+	now        func() time.Time
+}
+
+// New returns a cache that holds up to maxSize bytes of responses.
+func New(maxSize int64) *Cache {
+	return &Cache{
+		maxSize:    maxSize,
+		lru:        list.New(),
+		namespaces: make(map[string]*namespace),
+		now:        time.Now,
+	}
+}
+
+// Get returns the fresh response of the request with the given key and its
+// age.
+func (c *Cache) Get(ns string, gen int, key string, r *http.Request) (*Response, time.Duration, bool) {
+	if !cacheableRequest(r) {
+		return nil, 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.namespace(ns, gen, false)
+	if n == nil {
+		return nil, 0, false
+	}
+	vary, ok := n.vary[key]
+	if !ok {
+		return nil, 0, false
+	}
+	elem, ok := n.entries[varyKey(key, vary, r)]
+	if !ok {
+		return nil, 0, false
+	}
+	e := elem.Value.(*entry)
+	now := c.now()
+	if !now.Before(e.expires) {
+		c.remove(elem)
+		return nil, 0, false
+	}
+	c.lru.MoveToFront(elem)
+	return e.resp, now.Sub(e.stored), true
+}
+
+// Put caches the response of the request with the given key when the
+// request and the response are cacheable, and reports whether it did.
+func (c *Cache) Put(ns string, gen int, key string, r *http.Request, resp *Response) bool {
+	if !cacheableRequest(r) || r.Method != http.MethodGet {
+		return false
+	}
+	ttl := freshness(resp)
+	if ttl <= 0 {
+		return false
+	}
+	vary, ok := varyHeaders(resp.Header)
+	if !ok {
+		return false
+	}
+	size := int64(len(key) + len(resp.Body))
+	for name, values := range resp.Header {
+		size += int64(len(name))
+		for _, v := range values {
+			size += int64(len(v))
+		}
+	}
+	if size > c.maxSize {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.namespace(ns, gen, true)
+	if n == nil {
+		return false
+	}
+	now := c.now()
+	e := &entry{
+		ns:      ns,
+		key:     varyKey(key, vary, r),
+		resp:    resp,
+		size:    size,
+		stored:  now,
+		expires: now.Add(ttl),
+	}
+	if elem, ok := n.entries[e.key]; ok {
+		c.size -= elem.Value.(*entry).size
+		elem.Value = e
+		c.lru.MoveToFront(elem)
+	} else {
+		n.entries[e.key] = c.lru.PushFront(e)
+	}
+	n.vary[key] = vary
+	c.size += size
+	for c.size > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+	return true
+}
+
+// Size returns the size of the cached responses.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// namespace returns the namespace at the given generation, which is purged
+// when it is at an older one. It returns nil for older generations, and for
+// namespaces without responses unless create is set.
+func (c *Cache) namespace(ns string, gen int, create bool) *namespace {
+	n, ok := c.namespaces[ns]
+	if ok && n.gen == gen {
+		return n
+	}
+	if ok && n.gen > gen {
+		return nil
+	}
+	if ok {
+		c.purge(n)
+	}
+	if !create {
+		return nil
+	}
+	n = &namespace{
+		gen:     gen,
+		vary:    make(map[string][]string),
+		entries: make(map[string]*list.Element),
+	}
+	c.namespaces[ns] = n
+	return n
+}
+
+func (c *Cache) purge(n *namespace) {
+	for _, elem := range n.entries {
+		c.remove(elem)
+	}
+}
+
+func (c *Cache) remove(elem *list.Element) {
+	e := c.lru.Remove(elem).(*entry)
+	c.size -= e.size
+	n := c.namespaces[e.ns]
+	delete(n.entries, e.key)
+	// Namespaces without responses are dropped, together with their
+	// generation, which is only needed to purge responses.
+	if len(n.entries) == 0 {
+		delete(c.namespaces, e.ns)
+	}
+}
+
+// cacheableRequest reports whether the response of the request can be
+// shared. Requests with credentials are personal.
+func cacheableRequest(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	return len(r.Header.Get("Authorization")) == 0 && len(r.Header.Get("Cookie")) == 0
+}
+
+// freshness returns for how long the response may be served from the cache
+// by its Cache-Control header. Responses without a max-age or s-maxage are
+// not cached.
+func freshness(resp *Response) time.Duration {
+	if !cacheableStatus[resp.StatusCode] || len(resp.Header.Get("Set-Cookie")) > 0 {
+		return 0
+	}
+	var maxAge, sMaxAge = -1, -1
+	for _, value := range resp.Header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			switch strings.ToLower(name) {
+			case "no-store", "no-cache", "private":
+				return 0
+			case "max-age":
+				maxAge = parseSeconds(arg)
+			case "s-maxage":
+				sMaxAge = parseSeconds(arg)
+			}
+		}
+	}
+	if sMaxAge >= 0 {
+		maxAge = sMaxAge
+	}
+	if maxAge <= 0 {
+		return 0
+	}
+	return time.Duration(maxAge) * time.Second
+}
+
+func parseSeconds(s string) int {
+	n, err := strconv.Atoi(strings.Trim(s, `"`))
+	if err != nil || n < 0 {
+		return -1
+	}
+	return n
+}
+
+// varyHeaders returns the canonical names of the request headers of the
+// Vary header of the response. Responses that vary on * are not cacheable.
+func varyHeaders(header http.Header) ([]string, bool) {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil, false
+			}
+			if len(name) > 0 {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+// varyKey returns the key of the response of the request, with the values
+// of the headers the response varies on.
+func varyKey(key string, vary []string, r *http.Request) string {
+	var sb strings.Builder
+	sb.WriteString(key)
+	for _, name := range vary {
+		sb.WriteString("\n")
+		sb.WriteString(name)
+		sb.WriteString(": ")
+		sb.WriteString(strings.Join(r.Header.Values(name), ", "))
+	}
+	return sb.String()
+}
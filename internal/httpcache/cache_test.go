@@ -0,0 +1,142 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newResponse(cacheControl string, body string) *Response {
+	header := http.Header{}
+	if len(cacheControl) > 0 {
+		header.Set("Cache-Control", cacheControl)
+	}
+	return &Response{StatusCode: http.StatusOK, Header: header, Body: []byte(body)}
+}
+
+func TestCacheGetPut(t *testing.T) {
+	c := New(1 << 20)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	r := httptest.NewRequest("GET", "/", nil)
+
+	_, _, ok := c.Get("e", 1, "/", r)
+	require.False(t, ok)
+	require.True(t, c.Put("e", 1, "/", r, newResponse("public, max-age=60", "a")))
+
+	now = now.Add(10 * time.Second)
+	resp, age, ok := c.Get("e", 1, "/", r)
+	require.True(t, ok)
+	require.Equal(t, "a", string(resp.Body))
+	require.Equal(t, 10*time.Second, age)
+
+	// HEAD requests are served from GET responses.
+	_, _, ok = c.Get("e", 1, "/", httptest.NewRequest("HEAD", "/", nil))
+	require.True(t, ok)
+
+	// Responses expire after their max-age.
+	now = now.Add(50 * time.Second)
+	_, _, ok = c.Get("e", 1, "/", r)
+	require.False(t, ok)
+	require.Equal(t, int64(0), c.Size())
+}
+
+func TestCacheUncacheable(t *testing.T) {
+	c := New(1 << 20)
+	r := httptest.NewRequest("GET", "/", nil)
+	for _, cacheControl := range []string{"", "no-store", "private, max-age=60", "no-cache", "max-age=0", "max-age=abc"} {
+		require.False(t, c.Put("e", 1, "/", r, newResponse(cacheControl, "a")), cacheControl)
+	}
+
+	resp := newResponse("max-age=60", "a")
+	resp.Header.Set("Set-Cookie", "session=1")
+	require.False(t, c.Put("e", 1, "/", r, resp))
+
+	resp = newResponse("max-age=60", "a")
+	resp.StatusCode = http.StatusInternalServerError
+	require.False(t, c.Put("e", 1, "/", r, resp))
+
+	resp = newResponse("max-age=60", "a")
+	resp.Header.Set("Vary", "*")
+	require.False(t, c.Put("e", 1, "/", r, resp))
+
+	require.False(t, c.Put("e", 1, "/", httptest.NewRequest("POST", "/", nil), newResponse("max-age=60", "a")))
+	withAuth := httptest.NewRequest("GET", "/", nil)
+	withAuth.Header.Set("Authorization", "Bearer x")
+	require.False(t, c.Put("e", 1, "/", withAuth, newResponse("max-age=60", "a")))
+
+	// s-maxage is for shared caches like this one.
+	require.True(t, c.Put("e", 1, "/", r, newResponse("max-age=0, s-maxage=60", "a")))
+}
+
+func TestCacheVary(t *testing.T) {
+	c := New(1 << 20)
+	request := func(lang string) *http.Request {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Language", lang)
+		return r
+	}
+	resp := newResponse("max-age=60", "hello")
+	resp.Header.Set("Vary", "accept-language")
+	require.True(t, c.Put("e", 1, "/", request("en"), resp))
+	resp = newResponse("max-age=60", "hallo")
+	resp.Header.Set("Vary", "Accept-Language")
+	require.True(t, c.Put("e", 1, "/", request("de"), resp))
+
+	got, _, ok := c.Get("e", 1, "/", request("en"))
+	require.True(t, ok)
+	require.Equal(t, "hello", string(got.Body))
+	got, _, ok = c.Get("e", 1, "/", request("de"))
+	require.True(t, ok)
+	require.Equal(t, "hallo", string(got.Body))
+	_, _, ok = c.Get("e", 1, "/", request("fr"))
+	require.False(t, ok)
+}
+
+func TestCacheGenerations(t *testing.T) {
+	c := New(1 << 20)
+	r := httptest.NewRequest("GET", "/", nil)
+	require.True(t, c.Put("e", 1, "/", r, newResponse("max-age=60", "a")))
+	require.True(t, c.Put("other", 1, "/", r, newResponse("max-age=60", "b")))
+
+	// A newer generation purges the namespace, older ones are ignored.
+	_, _, ok := c.Get("e", 2, "/", r)
+	require.False(t, ok)
+	_, _, ok = c.Get("e", 1, "/", r)
+	require.False(t, ok)
+	require.True(t, c.Put("e", 2, "/", r, newResponse("max-age=60", "c")))
+	require.False(t, c.Put("e", 1, "/", r, newResponse("max-age=60", "a")))
+	got, _, ok := c.Get("e", 2, "/", r)
+	require.True(t, ok)
+	require.Equal(t, "c", string(got.Body))
+
+	_, _, ok = c.Get("other", 1, "/", r)
+	require.True(t, ok)
+}
+
+func TestCacheEviction(t *testing.T) {
+	// Every response takes 30 bytes: its key, body and headers.
+	c := New(100)
+	r := httptest.NewRequest("GET", "/", nil)
+	body := strings.Repeat("x", 5)
+	for _, key := range []string{"/a", "/b", "/c"} {
+		require.True(t, c.Put("e", 1, key, r, newResponse("max-age=60", body)))
+	}
+	// Using /a makes /b the least recently used response.
+	_, _, ok := c.Get("e", 1, "/a", r)
+	require.True(t, ok)
+	require.True(t, c.Put("e", 1, "/d", r, newResponse("max-age=60", body)))
+	require.Equal(t, int64(90), c.Size())
+
+	_, _, ok = c.Get("e", 1, "/b", r)
+	require.False(t, ok)
+	_, _, ok = c.Get("e", 1, "/a", r)
+	require.True(t, ok)
+
+	// Responses larger than the cache are not cached.
+	require.False(t, c.Put("e", 1, "/big", r, newResponse("max-age=60", strings.Repeat("x", 200))))
+}
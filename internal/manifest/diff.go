@@ -0,0 +1,117 @@
+package manifest
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/anthdm/raptor/internal/api"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+)
+
+// Plan holds the changes that bring an endpoint in line with its manifest.
+type Plan struct {
+	// Update is nil when the endpoint itself is up to date.
+	Update          *api.UpdateEndpointParams
+	AddDomains      []string
+	RemoveDomains   []string
+	AddSchedules    []Schedule
+	RemoveSchedules []uuid.UUID
+	// Warnings are the differences that can not be applied, like a new name.
+	Warnings []string
+}
+
+// Empty reports whether the plan does not change anything.
+func (p Plan) Empty() bool {
+	return p.Update == nil &&
+		len(p.AddDomains) == 0 &&
+		len(p.RemoveDomains) == 0 &&
+		len(p.AddSchedules) == 0 &&
+		len(p.RemoveSchedules) == 0
+}
+
+// Diff returns the plan that reconciles the given endpoint, its domains and
+// its schedules with the manifest.
+func (m *Manifest) Diff(endpoint *types.Endpoint, domains []string, schedules []types.Schedule) (Plan, error) {
+	var plan Plan
+	if endpoint.Runtime != m.Runtime {
+		return plan, fmt.Errorf("the runtime of an endpoint can not be changed (%s to %s)", endpoint.Runtime, m.Runtime)
+	}
+	if endpoint.Name != m.Name {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("the name of an endpoint can not be changed (%s)", endpoint.Name))
+	}
+	if len(m.Slug) > 0 && endpoint.Slug != m.Slug {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("the slug of an endpoint can not be changed (%s)", endpoint.Slug))
+	}
+
+	update := api.UpdateEndpointParams{Version: endpoint.Version}
+	changed := false
+	if !maps.Equal(endpoint.Environment, m.Environment) {
+		update.Environment = m.Environment
+		update.ReplaceEnvironment = true
+		changed = true
+	}
+	if endpoint.SnapshotEnvironment != m.SnapshotEnvironment {
+		snapshot := m.SnapshotEnvironment
+		update.SnapshotEnvironment = &snapshot
+		changed = true
+	}
+	if !sameElements(endpoint.AllowedHosts, m.AllowedHosts) {
+		update.AllowedHosts = m.AllowedHosts
+		changed = true
+	}
+	if changed {
+		plan.Update = &update
+	}
+
+	declared := make([]string, len(m.Domains))
+	for i, hostname := range m.Domains {
+		declared[i] = types.NormalizeHostname(hostname)
+	}
+	for _, hostname := range declared {
+		if !slices.Contains(domains, hostname) && !slices.Contains(plan.AddDomains, hostname) {
+			plan.AddDomains = append(plan.AddDomains, hostname)
+		}
+	}
+	for _, hostname := range domains {
+		if !slices.Contains(declared, hostname) {
+			plan.RemoveDomains = append(plan.RemoveDomains, hostname)
+		}
+	}
+
+	// Schedules are matched by their cron expression, method and path. Each
+	// existing schedule matches at most one declared schedule.
+	existing := make(map[string][]uuid.UUID)
+	for _, s := range schedules {
+		key := Schedule{Cron: s.Cron, Method: s.Method, Path: s.Path}.String()
+		existing[key] = append(existing[key], s.ID)
+	}
+	for _, s := range m.Schedules {
+		key := s.String()
+		if ids := existing[key]; len(ids) > 0 {
+			existing[key] = ids[1:]
+			continue
+		}
+		plan.AddSchedules = append(plan.AddSchedules, s)
+	}
+	for _, s := range schedules {
+		key := Schedule{Cron: s.Cron, Method: s.Method, Path: s.Path}.String()
+		if slices.Contains(existing[key], s.ID) {
+			plan.RemoveSchedules = append(plan.RemoveSchedules, s.ID)
+		}
+	}
+	return plan, nil
+}
+
+func sameElements(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, v := range a {
+		if !slices.Contains(b, v) {
+			return false
+		}
+	}
+	return true
+}
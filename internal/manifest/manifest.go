@@ -0,0 +1,230 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/anthdm/raptor/internal/cron"
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// FileName is the name of the manifest in the root of a project.
+const FileName = "raptor.toml"
+
+// Manifest declares an endpoint and its configuration. The ID is written
+// to the manifest once the endpoint is created.
+type Manifest struct {
+	ID      string
+	Name    string
+	Slug    string
+	Runtime string
+	// Dir is the directory of the sources that are built on deploy, File a
+	// prebuilt blob. Both are relative to the manifest.
+	Dir  string
+	File string
+	// Assets is the directory of the static assets that are deployed with
+	// the blob, relative to the manifest.
+	Assets              string
+	SnapshotEnvironment bool
+	AllowedHosts        []string
+	Domains             []string
+	Environment         map[string]string
+	Schedules           []Schedule
+	// SmokeTests are made against the preview of a deployment before it is
+	// published with raptor deploy --publish, like "GET /health 200".
+	SmokeTests []string
+}
+
+// Schedule declares a cron schedule of the endpoint.
+type Schedule struct {
+	Cron   string
+	Method string
+	Path   string
+}
+
+// String returns the schedule with the default method and path applied. It
+// is used to match declared schedules with existing ones.
+func (s Schedule) String() string {
+	method := strings.ToUpper(s.Method)
+	if len(method) == 0 {
+		method = "POST"
+	}
+	path := s.Path
+	if len(path) == 0 {
+		path = "/"
+	}
+	return strings.Join([]string{strings.TrimSpace(s.Cron), method, path}, " ")
+}
+
+var template = `# The id of the endpoint is set by raptor deploy and raptor apply.
+name = %q
+runtime = %q
+
+# The directory of the sources that are built on deploy. Use file instead to
+# deploy a prebuilt blob.
+dir = "."
+
+# The directory of static assets, like HTML, CSS and images, that are served
+# together with the endpoint. Other paths are served by the endpoint.
+# assets = "public"
+
+# Store the environment with each deployment so changes go LIVE on publish.
+snapshotEnvironment = false
+
+# Hosts the endpoint may send outbound HTTP requests to.
+allowedHosts = []
+
+# Custom domains of the endpoint.
+domains = []
+
+# Requests that have to pass on the preview of a deployment before it is
+# published with raptor deploy --publish: "[METHOD] PATH [STATUS]".
+smokeTests = []
+
+[environment]
+# FOO = "bar"
+
+# [[schedules]]
+# cron = "@hourly"
+# method = "POST"
+# path = "/"
+`
+
+// Init writes a new manifest for an endpoint with the given name and runtime
+// to path. It fails if the file already exists.
+func Init(path string, name string, runtime string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	m := &Manifest{Name: name, Runtime: runtime, Dir: "."}
+	if err := m.validate(); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(fmt.Sprintf(template, name, runtime)), 0644)
+}
+
+// Load reads and validates the manifest at path.
+func Load(path string) (*Manifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := toml.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %s", path, err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %s", path, err)
+	}
+	if len(m.Dir) == 0 && len(m.File) == 0 {
+		m.Dir = "."
+	}
+	if m.Environment == nil {
+		m.Environment = map[string]string{}
+	}
+	if m.AllowedHosts == nil {
+		m.AllowedHosts = []string{}
+	}
+	return &m, nil
+}
+
+func (m *Manifest) validate() error {
+	if len(m.ID) > 0 {
+		if _, err := uuid.Parse(m.ID); err != nil {
+			return fmt.Errorf("invalid id %q", m.ID)
+		}
+	}
+	if len(m.Name) == 0 {
+		return fmt.Errorf("name is required")
+	}
+	if !types.ValidRuntime(m.Runtime) {
+		return fmt.Errorf("invalid runtime %q", m.Runtime)
+	}
+	if len(m.Dir) > 0 && len(m.File) > 0 {
+		return fmt.Errorf("dir and file can not be used together")
+	}
+	for _, host := range m.AllowedHosts {
+		if !types.ValidAllowedHost(host) {
+			return fmt.Errorf("invalid allowed host %q", host)
+		}
+	}
+	for _, hostname := range m.Domains {
+		if !types.ValidHostname(types.NormalizeHostname(hostname)) {
+			return fmt.Errorf("invalid domain %q", hostname)
+		}
+	}
+	for _, t := range m.SmokeTests {
+		if _, err := types.ParseSmokeTest(t); err != nil {
+			return err
+		}
+	}
+	for _, s := range m.Schedules {
+		if _, err := cron.Parse(s.Cron); err != nil {
+			return err
+		}
+		if len(s.Path) > 0 && !strings.HasPrefix(s.Path, "/") {
+			return fmt.Errorf("schedule path should start with a slash: %s", s.Path)
+		}
+	}
+	return nil
+}
+
+// EndpointID returns the id of the endpoint of the manifest, which is nil
+// when the endpoint was not created yet.
+func (m *Manifest) EndpointID() uuid.UUID {
+	id, err := uuid.Parse(m.ID)
+	if err != nil {
+		return uuid.Nil
+	}
+	return id
+}
+
+// Source returns the path of the sources or the blob to deploy, given the
+// path of the manifest.
+func (m *Manifest) Source(path string) string {
+	if len(m.File) > 0 {
+		return filepath.Join(filepath.Dir(path), m.File)
+	}
+	return filepath.Join(filepath.Dir(path), m.Dir)
+}
+
+// AssetsDir returns the path of the directory of the static assets, given
+// the path of the manifest. It is empty when the manifest has no assets.
+func (m *Manifest) AssetsDir(path string) string {
+	if len(m.Assets) == 0 {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(path), m.Assets)
+}
+
+var (
+	idRegexp    = regexp.MustCompile(`(?m)^id\s*=.*$`)
+	tableRegexp = regexp.MustCompile(`(?m)^\s*\[`)
+)
+
+// SetID stores the id of the endpoint in the manifest at path. The rest of
+// the file is left as is, including its comments.
+func SetID(path string, id uuid.UUID) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	line := []byte(fmt.Sprintf("id = %q", id.String()))
+	// Only the top level id is replaced, the id of a table is left alone.
+	end := len(b)
+	if loc := tableRegexp.FindIndex(b); loc != nil {
+		end = loc[0]
+	}
+	if loc := idRegexp.FindIndex(b[:end]); loc != nil {
+		b = append(b[:loc[0]:loc[0]], append(line, b[loc[1]:]...)...)
+	} else {
+		b = append(append(line, '\n'), b...)
+	}
+	return os.WriteFile(path, bytes.TrimLeft(b, "\n"), 0644)
+}
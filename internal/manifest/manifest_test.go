@@ -0,0 +1,111 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	require.Nil(t, Init(path, "my-endpoint", "go"))
+	require.NotNil(t, Init(path, "my-endpoint", "go"))
+
+	m, err := Load(path)
+	require.Nil(t, err)
+	require.Equal(t, "my-endpoint", m.Name)
+	require.Equal(t, "go", m.Runtime)
+	require.Equal(t, uuid.Nil, m.EndpointID())
+	require.Equal(t, filepath.Dir(path), m.Source(path))
+	require.Empty(t, m.AssetsDir(path))
+	require.Empty(t, m.Environment)
+	require.Empty(t, m.Schedules)
+}
+
+func TestAssetsDir(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	require.Nil(t, os.WriteFile(path, []byte("name = \"foo\"\nruntime = \"js\"\nassets = \"public\""), 0644))
+	m, err := Load(path)
+	require.Nil(t, err)
+	require.Equal(t, filepath.Join(filepath.Dir(path), "public"), m.AssetsDir(path))
+}
+
+func TestLoadInvalid(t *testing.T) {
+	for _, content := range []string{
+		`runtime = "go"`,
+		"name = \"foo\"\nruntime = \"ruby\"",
+		"name = \"foo\"\nruntime = \"go\"\nallowedHosts = [\"*\"]",
+		"name = \"foo\"\nruntime = \"go\"\n[[schedules]]\ncron = \"* *\"",
+		"name = \"foo\"\nruntime = \"go\"\ndir = \".\"\nfile = \"app.wasm\"",
+		"name = \"foo\"\nruntime = \"go\"\nsmokeTests = [\"health\"]",
+	} {
+		path := filepath.Join(t.TempDir(), FileName)
+		require.Nil(t, os.WriteFile(path, []byte(content), 0644))
+		_, err := Load(path)
+		require.NotNil(t, err, content)
+	}
+}
+
+func TestSetID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	require.Nil(t, Init(path, "my-endpoint", "js"))
+
+	id := uuid.New()
+	require.Nil(t, SetID(path, id))
+	m, err := Load(path)
+	require.Nil(t, err)
+	require.Equal(t, id, m.EndpointID())
+
+	// The id is replaced and the comments are kept.
+	id = uuid.New()
+	require.Nil(t, SetID(path, id))
+	m, err = Load(path)
+	require.Nil(t, err)
+	require.Equal(t, id, m.EndpointID())
+	b, err := os.ReadFile(path)
+	require.Nil(t, err)
+	require.Contains(t, string(b), "# Hosts the endpoint may send outbound HTTP requests to.")
+}
+
+func TestDiff(t *testing.T) {
+	endpoint := types.NewEndpoint("my-endpoint", "go", map[string]string{"FOO": "bar"})
+	hourly, err := types.NewSchedule(endpoint, "@hourly", "", "")
+	require.Nil(t, err)
+	daily, err := types.NewSchedule(endpoint, "@daily", "GET", "/report")
+	require.Nil(t, err)
+
+	m := &Manifest{
+		Name:        "my-endpoint",
+		Runtime:     "go",
+		Environment: map[string]string{"FOO": "bar"},
+		Domains:     []string{"example.com"},
+		Schedules:   []Schedule{{Cron: "@hourly", Method: "post"}},
+	}
+	plan, err := m.Diff(endpoint, []string{"example.com"}, []types.Schedule{*hourly})
+	require.Nil(t, err)
+	require.True(t, plan.Empty())
+
+	m.Environment = map[string]string{"BAR": "baz"}
+	m.AllowedHosts = []string{"api.example.com"}
+	m.Domains = []string{"Foo.com"}
+	m.Schedules = []Schedule{{Cron: "*/5 * * * *"}}
+	plan, err = m.Diff(endpoint, []string{"example.com"}, []types.Schedule{*hourly, *daily})
+	require.Nil(t, err)
+	require.NotNil(t, plan.Update)
+	require.True(t, plan.Update.ReplaceEnvironment)
+	require.Equal(t, m.Environment, plan.Update.Environment)
+	require.Equal(t, m.AllowedHosts, plan.Update.AllowedHosts)
+	require.Nil(t, plan.Update.SnapshotEnvironment)
+	require.Equal(t, []string{"foo.com"}, plan.AddDomains)
+	require.Equal(t, []string{"example.com"}, plan.RemoveDomains)
+	require.Equal(t, m.Schedules, plan.AddSchedules)
+	require.ElementsMatch(t, []uuid.UUID{hourly.ID, daily.ID}, plan.RemoveSchedules)
+
+	m.Runtime = "js"
+	_, err = m.Diff(endpoint, nil, nil)
+	require.NotNil(t, err)
+}
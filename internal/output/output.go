@@ -0,0 +1,136 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is the format in which values are written.
+type Format string
+
+const (
+	JSON  Format = "json"
+	Table Format = "table"
+	YAML  Format = "yaml"
+)
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case JSON, Table, YAML:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid output format %q, expected json, table or yaml", s)
+}
+
+// Write writes v in the given format to w. Values are first encoded as JSON
+// so the JSON tags of the API types decide the names of the fields in every
+// format.
+func Write(w io.Writer, format Format, v any) error {
+	b, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+	switch format {
+	case Table:
+		val, err := parse(b)
+		if err != nil {
+			return err
+		}
+		return writeTable(w, val)
+	case YAML:
+		val, err := parse(b)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		writeYAML(&buf, val, 0)
+		_, err = w.Write(buf.Bytes())
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+// object is a JSON object that keeps the order of its keys.
+type object struct {
+	keys   []string
+	values map[string]any
+}
+
+// parse decodes JSON into nil, bool, json.Number, string, []any and *object
+// values.
+func parse(b []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return parseValue(dec)
+}
+
+func parseValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := &object{values: make(map[string]any)}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := tok.(string)
+			val, err := parseValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.keys = append(obj.keys, key)
+			obj.values[key] = val
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Delim('['):
+		list := []any{}
+		for dec.More() {
+			val, err := parseValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, val)
+		}
+		_, err := dec.Token()
+		return list, err
+	}
+	return tok, nil
+}
+
+// compact returns the value as JSON on a single line.
+func compact(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		b, _ := json.Marshal(v)
+		return string(b)
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprint(v)
+	case []any:
+		parts := make([]string, len(v))
+		for i, val := range v {
+			parts[i] = compact(val)
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	case *object:
+		parts := make([]string, len(v.keys))
+		for i, key := range v.keys {
+			parts[i] = compact(key) + ":" + compact(v.values[key])
+		}
+		return "{" + strings.Join(parts, ",") + "}"
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,72 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type item struct {
+	ID     string            `json:"id"`
+	Name   string            `json:"name"`
+	Count  int               `json:"count"`
+	Labels map[string]string `json:"labels"`
+	Hosts  []string          `json:"hosts"`
+}
+
+var items = []item{
+	{ID: "1", Name: "foo", Count: 2, Labels: map[string]string{"a": "b"}, Hosts: []string{"example.com"}},
+	{ID: "2", Name: "bar: baz", Labels: map[string]string{}, Hosts: []string{}},
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("TABLE")
+	require.Nil(t, err)
+	require.Equal(t, Table, format)
+	_, err = ParseFormat("xml")
+	require.NotNil(t, err)
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.Nil(t, Write(&buf, JSON, items[0]))
+	require.Contains(t, buf.String(), `    "name": "foo",`)
+}
+
+func TestWriteTable(t *testing.T) {
+	var buf bytes.Buffer
+	require.Nil(t, Write(&buf, Table, items))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	require.Equal(t, []string{"ID", "NAME", "COUNT", "LABELS", "HOSTS"}, strings.Fields(lines[0]))
+	require.Equal(t, []string{"1", "foo", "2", `{"a":"b"}`, `["example.com"]`}, strings.Fields(lines[1]))
+
+	buf.Reset()
+	require.Nil(t, Write(&buf, Table, items[0]))
+	require.Contains(t, buf.String(), "NAME    foo\n")
+
+	buf.Reset()
+	require.Nil(t, Write(&buf, Table, map[string]string{"data": strings.Repeat("a", 100) + "\n"}))
+	require.Contains(t, buf.String(), strings.Repeat("a", maxCellWidth-3)+"...")
+}
+
+func TestWriteYAML(t *testing.T) {
+	var buf bytes.Buffer
+	require.Nil(t, Write(&buf, YAML, items))
+	expected := `- id: "1"
+  name: foo
+  count: 2
+  labels:
+    a: b
+  hosts:
+    - example.com
+- id: "2"
+  name: "bar: baz"
+  count: 0
+  labels: {}
+  hosts: []
+`
+	require.Equal(t, expected, buf.String())
+}
@@ -0,0 +1,82 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// maxCellWidth is the number of characters after which a cell is truncated.
+const maxCellWidth = 60
+
+// writeTable writes a list of objects as rows with a column for each key,
+// a single object as key and value rows and any other value as is.
+func writeTable(w io.Writer, v any) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	switch v := v.(type) {
+	case []any:
+		columns := []string{}
+		seen := map[string]bool{}
+		for _, row := range v {
+			obj, ok := row.(*object)
+			if !ok {
+				fmt.Fprintln(tw, cell(row))
+				continue
+			}
+			for _, key := range obj.keys {
+				if !seen[key] {
+					seen[key] = true
+					columns = append(columns, key)
+				}
+			}
+		}
+		if len(columns) > 0 {
+			header := make([]string, len(columns))
+			for i, column := range columns {
+				header[i] = strings.ToUpper(column)
+			}
+			fmt.Fprintln(tw, strings.Join(header, "\t"))
+		}
+		for _, row := range v {
+			obj, ok := row.(*object)
+			if !ok {
+				continue
+			}
+			cells := make([]string, len(columns))
+			for i, column := range columns {
+				if val, ok := obj.values[column]; ok {
+					cells[i] = cell(val)
+				}
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		}
+	case *object:
+		for _, key := range v.keys {
+			fmt.Fprintf(tw, "%s\t%s\n", strings.ToUpper(key), cell(v.values[key]))
+		}
+	default:
+		fmt.Fprintln(tw, cell(v))
+	}
+	return tw.Flush()
+}
+
+// cell returns the value on a single line, truncated to maxCellWidth.
+func cell(v any) string {
+	var s string
+	switch v := v.(type) {
+	case nil:
+		s = ""
+	case string:
+		s = strings.NewReplacer("\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(v)
+	case json.Number:
+		s = v.String()
+	default:
+		s = compact(v)
+	}
+	if runes := []rune(s); len(runes) > maxCellWidth {
+		s = string(runes[:maxCellWidth-3]) + "..."
+	}
+	return s
+}
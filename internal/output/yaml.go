@@ -0,0 +1,95 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// plainRegexp matches the strings that can be written without quotes.
+var plainRegexp = regexp.MustCompile(`^[A-Za-z0-9_./][A-Za-z0-9_./:@+ -]*$`)
+
+// writeYAML writes v as a YAML block at the given indentation.
+func writeYAML(buf *bytes.Buffer, v any, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch v := v.(type) {
+	case *object:
+		if len(v.keys) == 0 {
+			buf.WriteString(pad + "{}\n")
+			return
+		}
+		for _, key := range v.keys {
+			buf.WriteString(pad + scalar(key) + ":")
+			writeYAMLValue(buf, v.values[key], indent+1)
+		}
+	case []any:
+		if len(v) == 0 {
+			buf.WriteString(pad + "[]\n")
+			return
+		}
+		for _, val := range v {
+			buf.WriteString(pad + "-")
+			if obj, ok := val.(*object); ok && len(obj.keys) > 0 {
+				// The first key of an object is written on the line of its dash.
+				var nested bytes.Buffer
+				writeYAML(&nested, obj, indent+1)
+				buf.WriteString(" ")
+				buf.Write(bytes.TrimLeft(nested.Bytes(), " "))
+				continue
+			}
+			writeYAMLValue(buf, val, indent+1)
+		}
+	default:
+		buf.WriteString(pad + scalar(v) + "\n")
+	}
+}
+
+// writeYAMLValue writes v after a key or dash, inline when it is a scalar or
+// an empty collection.
+func writeYAMLValue(buf *bytes.Buffer, v any, indent int) {
+	switch val := v.(type) {
+	case *object:
+		if len(val.keys) > 0 {
+			buf.WriteString("\n")
+			writeYAML(buf, val, indent)
+			return
+		}
+	case []any:
+		if len(val) > 0 {
+			buf.WriteString("\n")
+			writeYAML(buf, val, indent)
+			return
+		}
+	}
+	buf.WriteString(" ")
+	writeYAML(buf, v, 0)
+}
+
+func scalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		if needsQuotes(v) {
+			b, _ := json.Marshal(v)
+			return string(b)
+		}
+		return v
+	}
+	return compact(v)
+}
+
+func needsQuotes(s string) bool {
+	if !plainRegexp.MatchString(s) || strings.HasSuffix(s, " ") || strings.Contains(s, ": ") ||
+		strings.HasSuffix(s, ":") || strings.Contains(s, " #") {
+		return true
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~":
+		return true
+	}
+	// Strings that look like numbers are quoted to keep them strings.
+	var n json.Number
+	return json.Unmarshal([]byte(s), &n) == nil
+}
@@ -0,0 +1,155 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/anthdm/raptor/internal/types"
+	"github.com/anthdm/raptor/proto"
+	"github.com/tetratelabs/wazero/api"
+	prot "google.golang.org/protobuf/proto"
+)
+
+const (
+	// DefaultFetchTimeout is the time an outbound request of a guest may take.
+	DefaultFetchTimeout = time.Second * 10
+	// DefaultMaxFetchResponseSize is the maximum size of the body of a
+	// response to an outbound request of a guest.
+	DefaultMaxFetchResponseSize = 4 << 20
+)
+
+// FetchConfig configures the outbound HTTP requests of guests.
+type FetchConfig struct {
+	// AllowedHosts are the hosts a guest may send requests to. An entry is
+	// either a hostname, a host:port or a wildcard like *.example.com. No
+	// requests are allowed when empty.
+	AllowedHosts []string
+	// Timeout defaults to DefaultFetchTimeout.
+	Timeout time.Duration
+	// MaxResponseSize defaults to DefaultMaxFetchResponseSize.
+	MaxResponseSize int64
+	// Transport defaults to http.DefaultTransport.
+	Transport http.RoundTripper
+}
+
+// fetcher implements the fetch host functions.
+type fetcher struct {
+	results
+	client          *http.Client
+	maxResponseSize int64
+
+	// mu guards allowedHosts, which change between invocations.
+	mu           sync.RWMutex
+	allowedHosts []string
+}
+
+func newFetcher(config FetchConfig) *fetcher {
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = DefaultFetchTimeout
+	}
+	maxResponseSize := config.MaxResponseSize
+	if maxResponseSize == 0 {
+		maxResponseSize = DefaultMaxFetchResponseSize
+	}
+	transport := config.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	f := &fetcher{
+		allowedHosts:    config.AllowedHosts,
+		maxResponseSize: maxResponseSize,
+	}
+	f.client = &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			if !f.allowed(req.URL) {
+				return fmt.Errorf("redirect to host %s is not allowed", req.URL.Host)
+			}
+			return nil
+		},
+	}
+	return f
+}
+
+func (f *fetcher) fetch(ctx context.Context, m api.Module, ptr, size uint32) uint32 {
+	b, ok := m.Memory().Read(ptr, size)
+	if !ok {
+		return f.put(m, &proto.FetchResponse{Error: "request out of memory range"})
+	}
+	var req proto.FetchRequest
+	if err := prot.Unmarshal(b, &req); err != nil {
+		return f.put(m, &proto.FetchResponse{Error: fmt.Sprintf("invalid request: %s", err)})
+	}
+	return f.put(m, f.do(ctx, &req))
+}
+
+func (f *fetcher) do(ctx context.Context, req *proto.FetchRequest) *proto.FetchResponse {
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return &proto.FetchResponse{Error: fmt.Sprintf("invalid url: %s", err)}
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return &proto.FetchResponse{Error: fmt.Sprintf("unsupported scheme %q", u.Scheme)}
+	}
+	if !f.allowed(u) {
+		return &proto.FetchResponse{Error: fmt.Sprintf("host %s is not allowed", u.Host)}
+	}
+	method := req.Method
+	if len(method) == 0 {
+		method = http.MethodGet
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(req.Body))
+	if err != nil {
+		return &proto.FetchResponse{Error: err.Error()}
+	}
+	for k, v := range req.Header {
+		httpReq.Header[http.CanonicalHeaderKey(k)] = v.Fields
+	}
+	resp, err := f.client.Do(httpReq)
+	if err != nil {
+		return &proto.FetchResponse{Error: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxResponseSize+1))
+	if err != nil {
+		return &proto.FetchResponse{Error: err.Error()}
+	}
+	if int64(len(body)) > f.maxResponseSize {
+		return &proto.FetchResponse{Error: fmt.Sprintf("response body exceeds the maximum of %d bytes", f.maxResponseSize)}
+	}
+	header := make(map[string]*proto.HeaderFields, len(resp.Header))
+	for k, v := range resp.Header {
+		header[k] = &proto.HeaderFields{Fields: v}
+	}
+	return &proto.FetchResponse{
+		StatusCode: int32(resp.StatusCode),
+		Header:     header,
+		Body:       body,
+	}
+}
+
+// allowed reports whether the host of the given url matches any of the
+// allowed hosts.
+func (f *fetcher) allowed(u *url.URL) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return types.HostAllowed(f.allowedHosts, u)
+}
+
+func (f *fetcher) setAllowedHosts(hosts []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.allowedHosts = hosts
+}
@@ -0,0 +1,64 @@
+package runtime
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	prot "google.golang.org/protobuf/proto"
+)
+
+// HostModuleName is the name of the module that holds the host functions
+// guests can import.
+const HostModuleName = "raptor"
+
+// instantiateHostModule instantiates the host functions guests can import.
+func instantiateHostModule(ctx context.Context, r wazero.Runtime, f *fetcher, kv *kvHost, l *looper) error {
+	_, err := r.NewHostModuleBuilder(HostModuleName).
+		NewFunctionBuilder().WithFunc(f.fetch).Export("fetch").
+		NewFunctionBuilder().WithFunc(f.collect).Export("fetch_response").
+		NewFunctionBuilder().WithFunc(kv.call).Export("kv").
+		NewFunctionBuilder().WithFunc(kv.collect).Export("kv_response").
+		NewFunctionBuilder().WithFunc(l.next).Export("next_request").
+		NewFunctionBuilder().WithFunc(l.collect).Export("next_request_response").
+		Instantiate(ctx)
+	return err
+}
+
+// results holds the encoded results of host function calls until the guest
+// collects them. A guest calls a host function with a protobuf encoded
+// request, which returns the size of the encoded result. The guest then
+// allocates a buffer of that size and calls the matching response function
+// to have the result copied into it.
+type results struct {
+	mu      sync.Mutex
+	pending map[api.Module][]byte
+}
+
+// put stores the result of the call of the given module and returns its size.
+func (r *results) put(m api.Module, msg prot.Message) uint32 {
+	out, _ := prot.Marshal(msg)
+	return r.putBytes(m, out)
+}
+
+// putBytes stores the encoded result of the call of the given module and
+// returns its size.
+func (r *results) putBytes(m api.Module, out []byte) uint32 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending == nil {
+		r.pending = make(map[api.Module][]byte)
+	}
+	r.pending[m] = out
+	return uint32(len(out))
+}
+
+// collect copies the pending result of the given module into its memory.
+func (r *results) collect(_ context.Context, m api.Module, ptr uint32) {
+	r.mu.Lock()
+	out := r.pending[m]
+	delete(r.pending, m)
+	r.mu.Unlock()
+	m.Memory().Write(ptr, out)
+}
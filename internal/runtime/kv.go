@@ -0,0 +1,85 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/anthdm/raptor/proto"
+	"github.com/tetratelabs/wazero/api"
+	prot "google.golang.org/protobuf/proto"
+)
+
+// The operations of the kv host function.
+const (
+	KVGet    = "get"
+	KVPut    = "put"
+	KVDelete = "delete"
+	KVList   = "list"
+)
+
+// ErrKVNotFound is returned by a KV when the key does not exist.
+var ErrKVNotFound = errors.New("key not found")
+
+// KV is the key-value store of the endpoint of a deployment.
+type KV interface {
+	Get(key string) ([]byte, error)
+	Put(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+	List(prefix string, limit int) ([]string, error)
+}
+
+// kvHost implements the kv host functions.
+type kvHost struct {
+	results
+	kv KV
+}
+
+func (h *kvHost) call(_ context.Context, m api.Module, ptr, size uint32) uint32 {
+	b, ok := m.Memory().Read(ptr, size)
+	if !ok {
+		return h.put(m, &proto.KVResponse{Error: "request out of memory range"})
+	}
+	var req proto.KVRequest
+	if err := prot.Unmarshal(b, &req); err != nil {
+		return h.put(m, &proto.KVResponse{Error: fmt.Sprintf("invalid request: %s", err)})
+	}
+	return h.put(m, h.do(&req))
+}
+
+func (h *kvHost) do(req *proto.KVRequest) *proto.KVResponse {
+	if h.kv == nil {
+		return &proto.KVResponse{Error: "kv store is not available"}
+	}
+	switch req.Op {
+	case KVGet:
+		value, err := h.kv.Get(req.Key)
+		if errors.Is(err, ErrKVNotFound) {
+			return &proto.KVResponse{}
+		}
+		if err != nil {
+			return &proto.KVResponse{Error: err.Error()}
+		}
+		return &proto.KVResponse{Value: value, Found: true}
+	case KVPut:
+		ttl := time.Duration(req.TtlMillis) * time.Millisecond
+		if err := h.kv.Put(req.Key, req.Value, ttl); err != nil {
+			return &proto.KVResponse{Error: err.Error()}
+		}
+		return &proto.KVResponse{}
+	case KVDelete:
+		if err := h.kv.Delete(req.Key); err != nil {
+			return &proto.KVResponse{Error: err.Error()}
+		}
+		return &proto.KVResponse{}
+	case KVList:
+		keys, err := h.kv.List(req.Prefix, int(req.Limit))
+		if err != nil {
+			return &proto.KVResponse{Error: err.Error()}
+		}
+		return &proto.KVResponse{Keys: keys}
+	default:
+		return &proto.KVResponse{Error: fmt.Sprintf("unknown kv operation %q", req.Op)}
+	}
+}
@@ -0,0 +1,168 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// ErrLoopUnsupported is returned by Loop for guests that exit without asking
+// for a request, like the ones built with an SDK that has no request loop.
+var ErrLoopUnsupported = errors.New("guest does not serve requests in a loop")
+
+// errLoopExited is returned by Loop.Invoke when the instance is gone.
+var errLoopExited = errors.New("guest exited")
+
+type loopKey struct{}
+
+// Loop is a long-lived instance of the module that serves many requests,
+// which saves the instantiation and the initialization of the guest, like
+// the start of the Go runtime, on every request. The guest asks for the
+// next request with the next_request host function, which blocks until
+// there is one. What the guest writes to stdout in between two requests is
+// the output of the first one.
+//
+// The memory of the guest outlives requests, hence state in globals is
+// shared by the requests of an instance.
+type Loop struct {
+	cancel    context.CancelFunc
+	requests  chan []byte
+	responses chan []byte
+	ready     chan struct{}
+	exited    chan struct{}
+	err       error
+
+	// stdout and serving are only used by the goroutine of the guest.
+	stdout  bytes.Buffer
+	serving bool
+}
+
+// Loop starts a long-lived instance of the module that serves requests
+// with Loop.Invoke until it is closed. It returns ErrLoopUnsupported when
+// the guest does not serve requests in a loop.
+func (r *Runtime) Loop(env map[string]string, args ...string) (*Loop, error) {
+	ctx, cancel := context.WithCancel(r.ctx)
+	l := &Loop{
+		cancel:    cancel,
+		requests:  make(chan []byte),
+		responses: make(chan []byte),
+		ready:     make(chan struct{}),
+		exited:    make(chan struct{}),
+	}
+	modConf := wazero.NewModuleConfig().
+		// Instances of the same module are anonymous, so they do not
+		// conflict with the ones of Invoke.
+		WithName("").
+		WithStdin(bytes.NewReader(nil)).
+		WithStdout(&l.stdout).
+		WithStderr(os.Stderr).
+		WithArgs(args...).
+		WithEnv(shared.ModeEnv, shared.ModeLoop)
+	for k, v := range env {
+		modConf = modConf.WithEnv(k, v)
+	}
+
+	go func() {
+		defer close(l.exited)
+		mod, err := r.runtime.InstantiateModule(context.WithValue(ctx, loopKey{}, l), r.mod, modConf)
+		if err == nil {
+			err = mod.Close(ctx)
+		}
+		l.err = err
+	}()
+
+	select {
+	case <-l.ready:
+		return l, nil
+	case <-l.exited:
+		cancel()
+		if l.err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrLoopUnsupported, l.err)
+		}
+		return nil, ErrLoopUnsupported
+	}
+}
+
+// Invoke hands the protobuf encoded request to the guest and returns what
+// the guest wrote to stdout while serving it.
+func (l *Loop) Invoke(req []byte) ([]byte, error) {
+	select {
+	case l.requests <- req:
+	case <-l.exited:
+		return nil, l.exitErr()
+	}
+	select {
+	case out := <-l.responses:
+		return out, nil
+	case <-l.exited:
+		return nil, l.exitErr()
+	}
+}
+
+// Close stops the instance and waits until it exited.
+func (l *Loop) Close() error {
+	l.cancel()
+	<-l.exited
+	return nil
+}
+
+func (l *Loop) exitErr() error {
+	if l.err != nil {
+		return fmt.Errorf("%w: %s", errLoopExited, l.err)
+	}
+	return errLoopExited
+}
+
+// next completes the request that is being served, if any, and blocks until
+// the next request. It reports false when the instance is closed.
+func (l *Loop) next(ctx context.Context) ([]byte, bool) {
+	if l.serving {
+		out := bytes.Clone(l.stdout.Bytes())
+		l.stdout.Reset()
+		l.serving = false
+		select {
+		case l.responses <- out:
+		case <-ctx.Done():
+			return nil, false
+		}
+	} else {
+		select {
+		case <-l.ready:
+		default:
+			close(l.ready)
+		}
+	}
+	select {
+	case req := <-l.requests:
+		l.serving = true
+		return req, true
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+// looper implements the next_request host functions of guests that serve
+// requests in a loop.
+type looper struct {
+	results
+}
+
+// next returns the size of the next request, or zero when the guest is not
+// in a loop or the loop is closed, after which the guest exits.
+func (h *looper) next(ctx context.Context, m api.Module) uint32 {
+	l, ok := ctx.Value(loopKey{}).(*Loop)
+	if !ok {
+		return 0
+	}
+	req, ok := l.next(ctx)
+	if !ok {
+		return 0
+	}
+	return h.putBytes(m, req)
+}
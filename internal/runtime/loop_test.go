@@ -0,0 +1,123 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/proto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tetratelabs/wazero"
+	pb "google.golang.org/protobuf/proto"
+)
+
+func newTestRuntime(t testing.TB, file string, stdout *bytes.Buffer) *Runtime {
+	b, err := os.ReadFile("../_testdata/" + file)
+	require.Nil(t, err)
+	r, err := New(context.Background(), Args{
+		Stdout:       stdout,
+		DeploymentID: uuid.New(),
+		Blob:         b,
+		Engine:       "go",
+		Cache:        wazero.NewCompilationCache(),
+	})
+	require.Nil(t, err)
+	return r
+}
+
+func TestRuntimeLoop(t *testing.T) {
+	r := newTestRuntime(t, "loop.wasm", &bytes.Buffer{})
+	defer r.Close()
+
+	l, err := r.Loop(nil)
+	require.Nil(t, err)
+	// The instance, and the counter of the guest, serves every request.
+	for i := 1; i <= 3; i++ {
+		path := fmt.Sprintf("/%d", i)
+		breq, err := pb.Marshal(&proto.HTTPRequest{Method: "GET", URL: path})
+		require.Nil(t, err)
+		out, err := l.Invoke(breq)
+		require.Nil(t, err)
+		logs, res, status, err := shared.ParseStdout(bytes.NewReader(out))
+		require.Nil(t, err)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, "serving "+path+"\n", string(logs))
+		require.Equal(t, fmt.Sprintf("%s %d", path, i), string(res))
+	}
+	require.Nil(t, l.Close())
+
+	// Requests after the close fail.
+	_, err = l.Invoke([]byte{})
+	require.NotNil(t, err)
+
+	// A new loop starts a new instance.
+	l, err = r.Loop(nil)
+	require.Nil(t, err)
+	defer l.Close()
+	breq, err := pb.Marshal(&proto.HTTPRequest{Method: "GET", URL: "/"})
+	require.Nil(t, err)
+	out, err := l.Invoke(breq)
+	require.Nil(t, err)
+	_, res, _, err := shared.ParseStdout(bytes.NewReader(out))
+	require.Nil(t, err)
+	require.Equal(t, "/ 1", string(res))
+}
+
+func TestRuntimeLoopUnsupported(t *testing.T) {
+	// The TinyGo SDK has no request loop.
+	r := newTestRuntime(t, "wasi.wasm", &bytes.Buffer{})
+	defer r.Close()
+
+	_, err := r.Loop(nil)
+	require.ErrorIs(t, err, ErrLoopUnsupported)
+
+	// Invoke still serves the request with a new instance.
+	stdout := &bytes.Buffer{}
+	r.stdout = stdout
+	breq, err := pb.Marshal(&proto.HTTPRequest{Method: "GET", URL: "/?name=bob"})
+	require.Nil(t, err)
+	require.Nil(t, r.Invoke(bytes.NewReader(breq), nil))
+	_, res, _, err := shared.ParseStdout(stdout)
+	require.Nil(t, err)
+	require.Equal(t, "GET hello bob ", string(res))
+}
+
+// BenchmarkRuntimeInvoke instantiates the guest for every request.
+func BenchmarkRuntimeInvoke(b *testing.B) {
+	stdout := &bytes.Buffer{}
+	r := newTestRuntime(b, "helloworld.wasm", stdout)
+	defer r.Close()
+	breq, err := pb.Marshal(&proto.HTTPRequest{Method: "GET", URL: "/"})
+	require.Nil(b, err)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stdout.Reset()
+		if err := r.Invoke(bytes.NewReader(breq), nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkRuntimeLoop serves all requests with a single instance.
+func BenchmarkRuntimeLoop(b *testing.B) {
+	r := newTestRuntime(b, "helloworld.wasm", &bytes.Buffer{})
+	defer r.Close()
+	breq, err := pb.Marshal(&proto.HTTPRequest{Method: "GET", URL: "/"})
+	require.Nil(b, err)
+	l, err := r.Loop(nil)
+	require.Nil(b, err)
+	defer l.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := l.Invoke(breq); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -17,6 +17,8 @@ type Args struct {
 	Engine       string
 	Blob         []byte
 	Cache        wazero.CompilationCache
+	Fetch        FetchConfig
+	KV           KV
 }
 
 type Runtime struct {
@@ -27,18 +29,27 @@ type Runtime struct {
 	blob         []byte
 	mod          wazero.CompiledModule
 	runtime      wazero.Runtime
+	fetch        *fetcher
+	kv           *kvHost
 }
 
 func New(ctx context.Context, args Args) (*Runtime, error) {
-	config := wazero.NewRuntimeConfigCompiler().WithCompilationCache(args.Cache)
+	config := wazero.NewRuntimeConfigCompiler().
+		WithCompilationCache(args.Cache).
+		WithCloseOnContextDone(true)
 	r := &Runtime{
 		runtime:      wazero.NewRuntimeWithConfig(ctx, config),
 		ctx:          ctx,
 		deploymentID: args.DeploymentID,
 		engine:       args.Engine,
 		stdout:       args.Stdout,
+		fetch:        newFetcher(args.Fetch),
+		kv:           &kvHost{kv: args.KV},
 	}
 	wasi_snapshot_preview1.MustInstantiate(ctx, r.runtime)
+	if err := instantiateHostModule(ctx, r.runtime, r.fetch, r.kv, &looper{}); err != nil {
+		return nil, fmt.Errorf("runtime failed to instantiate host module: %s", err)
+	}
 
 	mod, err := r.runtime.CompileModule(ctx, args.Blob)
 	if err != nil {
@@ -50,10 +61,17 @@ func New(ctx context.Context, args Args) (*Runtime, error) {
 }
 
 func (r *Runtime) Invoke(stdin io.Reader, env map[string]string, args ...string) error {
+	var stderr io.Writer = os.Stderr
+	// Scripts of the js runtime call the host over stdio.
+	if r.engine == "js" {
+		host := newStdioHost(r.ctx, r.fetch, r.kv, stdin, stderr)
+		defer host.flush()
+		stdin, stderr = host, host
+	}
 	modConf := wazero.NewModuleConfig().
 		WithStdin(stdin).
 		WithStdout(r.stdout).
-		WithStderr(os.Stderr).
+		WithStderr(stderr).
 		WithArgs(args...)
 	for k, v := range env {
 		modConf = modConf.WithEnv(k, v)
@@ -62,6 +80,31 @@ func (r *Runtime) Invoke(stdin io.Reader, env map[string]string, args ...string)
 	return err
 }
 
+// Serve runs a long-lived instance of the module that reads its input from
+// stdin and writes its output to stdout. It blocks until the module exits or
+// the given context is cancelled.
+func (r *Runtime) Serve(ctx context.Context, stdin io.Reader, stdout io.Writer, env map[string]string, args ...string) error {
+	modConf := wazero.NewModuleConfig().
+		WithStdin(stdin).
+		WithStdout(stdout).
+		WithStderr(os.Stderr).
+		WithArgs(args...)
+	for k, v := range env {
+		modConf = modConf.WithEnv(k, v)
+	}
+	mod, err := r.runtime.InstantiateModule(ctx, r.mod, modConf)
+	if err != nil {
+		return err
+	}
+	return mod.Close(ctx)
+}
+
+// SetAllowedHosts replaces the hosts the guest may send requests to, so
+// changes of the endpoint reach runtimes and instances that are reused.
+func (r *Runtime) SetAllowedHosts(hosts []string) {
+	r.fetch.setAllowedHosts(hosts)
+}
+
 func (r *Runtime) Close() error {
 	return r.runtime.Close(r.ctx)
 }
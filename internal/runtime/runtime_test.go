@@ -3,9 +3,16 @@ package runtime
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/anthdm/raptor/internal/shared"
 	"github.com/anthdm/raptor/internal/spidermonkey"
@@ -13,6 +20,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 	"github.com/tetratelabs/wazero"
+	"google.golang.org/protobuf/encoding/protojson"
 	pb "google.golang.org/protobuf/proto"
 )
 
@@ -78,3 +86,320 @@ func TestRuntimeInvokeGoCode(t *testing.T) {
 	require.Equal(t, "Hello world!", string(res))
 	require.Nil(t, r.Close())
 }
+
+// TestRuntimeInvokeWASIModules invokes the modules of the runtimes that run
+// a WASI module built with another toolchain than Go. They are built by
+// _testdata/build.sh, modules of toolchains that are not installed are
+// skipped.
+func TestRuntimeInvokeWASIModules(t *testing.T) {
+	for _, engine := range []string{"wasi", "tinygo", "rust"} {
+		t.Run(engine, func(t *testing.T) {
+			b, err := os.ReadFile("../_testdata/" + engine + ".wasm")
+			if os.IsNotExist(err) {
+				t.Skipf("%s.wasm is not built", engine)
+			}
+			require.Nil(t, err)
+
+			out := &bytes.Buffer{}
+			r, err := New(context.Background(), Args{
+				Stdout:       out,
+				DeploymentID: uuid.New(),
+				Blob:         b,
+				Engine:       engine,
+				Cache:        wazero.NewCompilationCache(),
+			})
+			require.Nil(t, err)
+			defer r.Close()
+
+			invoke := func(req *proto.HTTPRequest) ([]byte, []byte, int) {
+				breq, err := pb.Marshal(req)
+				require.Nil(t, err)
+				defer out.Reset()
+				require.Nil(t, r.Invoke(bytes.NewReader(breq), req.Env))
+				logs, res, status, err := shared.ParseStdout(out)
+				require.Nil(t, err)
+				return logs, res, status
+			}
+
+			_, res, status := invoke(&proto.HTTPRequest{
+				Method: "GET",
+				URL:    "/?name=bob",
+				Env:    map[string]string{"FOO": "bar"},
+			})
+			require.Equal(t, http.StatusCreated, status)
+			require.Equal(t, "GET hello bob bar", string(res))
+
+			logs, res, status := invoke(&proto.HTTPRequest{ID: "request-id", Method: "GET", URL: "/panic"})
+			require.Equal(t, http.StatusInternalServerError, status)
+			require.Contains(t, string(logs), "panic serving GET /panic: boom")
+			require.JSONEq(t, `{"error":"internal server error","request_id":"request-id"}`, string(res))
+		})
+	}
+}
+
+func TestRuntimeServeWebSocket(t *testing.T) {
+	b, err := os.ReadFile("../_testdata/websocket.wasm")
+	require.Nil(t, err)
+
+	args := Args{
+		Stdout:       io.Discard,
+		DeploymentID: uuid.New(),
+		Blob:         b,
+		Engine:       "go",
+		Cache:        wazero.NewCompilationCache(),
+	}
+	r, err := New(context.Background(), args)
+	require.Nil(t, err)
+
+	breq, err := pb.Marshal(&proto.HTTPRequest{Method: "GET", URL: "/"})
+	require.Nil(t, err)
+
+	stdin, stdinWriter := io.Pipe()
+	stdoutReader, stdout := io.Pipe()
+	env := map[string]string{shared.ModeEnv: shared.ModeWebSocket}
+	errch := make(chan error, 1)
+	go func() {
+		errch <- r.Serve(context.Background(), stdin, stdout, env)
+		stdout.Close()
+	}()
+
+	require.Nil(t, shared.WriteFrame(stdinWriter, shared.Frame{Type: shared.FrameOpen, Data: breq}))
+	for _, msg := range []string{"foo", "bar"} {
+		require.Nil(t, shared.WriteFrame(stdinWriter, shared.Frame{Type: shared.FrameText, Data: []byte(msg)}))
+		frame, err := shared.ReadFrame(stdoutReader)
+		require.Nil(t, err)
+		require.Equal(t, shared.FrameText, frame.Type)
+		require.Equal(t, "echo: "+msg, string(frame.Data))
+	}
+	// The guest exits once the connection is closed.
+	require.Nil(t, shared.WriteFrame(stdinWriter, shared.Frame{Type: shared.FrameClose}))
+	_, err = shared.ReadFrame(stdoutReader)
+	require.ErrorIs(t, err, io.EOF)
+	require.Nil(t, <-errch)
+	require.Nil(t, r.Close())
+}
+
+func TestRuntimeFetch(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/large" {
+			w.Write(bytes.Repeat([]byte("a"), 64))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello from upstream"))
+	}))
+	defer upstream.Close()
+	u, err := url.Parse(upstream.URL)
+	require.Nil(t, err)
+
+	b, err := os.ReadFile("../_testdata/fetch.wasm")
+	require.Nil(t, err)
+	breq, err := pb.Marshal(&proto.HTTPRequest{Method: "GET", URL: "/"})
+	require.Nil(t, err)
+
+	invoke := func(fetch FetchConfig, fetchURL string) (int, string) {
+		out := &bytes.Buffer{}
+		r, err := New(context.Background(), Args{
+			Stdout:       out,
+			DeploymentID: uuid.New(),
+			Blob:         b,
+			Engine:       "go",
+			Cache:        wazero.NewCompilationCache(),
+			Fetch:        fetch,
+		})
+		require.Nil(t, err)
+		defer r.Close()
+		env := map[string]string{"FETCH_URL": fetchURL}
+		require.Nil(t, r.Invoke(bytes.NewReader(breq), env))
+		_, res, status, err := shared.ParseStdout(out)
+		require.Nil(t, err)
+		return status, string(res)
+	}
+
+	status, res := invoke(FetchConfig{AllowedHosts: []string{u.Host}}, upstream.URL)
+	require.Equal(t, http.StatusCreated, status)
+	require.Equal(t, "hello from upstream", res)
+
+	status, res = invoke(FetchConfig{}, upstream.URL)
+	require.Equal(t, http.StatusBadGateway, status)
+	require.Contains(t, res, "is not allowed")
+
+	status, res = invoke(FetchConfig{AllowedHosts: []string{u.Hostname()}, MaxResponseSize: 32}, upstream.URL+"/large")
+	require.Equal(t, http.StatusBadGateway, status)
+	require.Contains(t, res, "exceeds the maximum")
+}
+
+func TestRuntimeSetAllowedHosts(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello from upstream"))
+	}))
+	defer upstream.Close()
+	u, err := url.Parse(upstream.URL)
+	require.Nil(t, err)
+
+	r := newTestRuntime(t, "fetch.wasm", &bytes.Buffer{})
+	defer r.Close()
+	// The allowed hosts change for the instance that serves every request.
+	l, err := r.Loop(map[string]string{"FETCH_URL": upstream.URL})
+	require.Nil(t, err)
+	defer l.Close()
+	breq, err := pb.Marshal(&proto.HTTPRequest{Method: "GET", URL: "/"})
+	require.Nil(t, err)
+	invoke := func() (int, string) {
+		out, err := l.Invoke(breq)
+		require.Nil(t, err)
+		_, res, status, err := shared.ParseStdout(bytes.NewReader(out))
+		require.Nil(t, err)
+		return status, string(res)
+	}
+
+	status, res := invoke()
+	require.Equal(t, http.StatusBadGateway, status)
+	require.Contains(t, res, "is not allowed")
+
+	r.SetAllowedHosts([]string{u.Host})
+	status, res = invoke()
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "hello from upstream", res)
+
+	r.SetAllowedHosts(nil)
+	status, _ = invoke()
+	require.Equal(t, http.StatusBadGateway, status)
+}
+
+func TestRuntimeStdioHostCalls(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream", "yes")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(r.Method + " " + r.Header.Get("X-Foo") + " h\u00e9llo"))
+	}))
+	defer upstream.Close()
+	u, err := url.Parse(upstream.URL)
+	require.Nil(t, err)
+
+	b, err := os.ReadFile("../_testdata/stdio.wasm")
+	require.Nil(t, err)
+
+	out := &bytes.Buffer{}
+	r, err := New(context.Background(), Args{
+		Stdout:       out,
+		DeploymentID: uuid.New(),
+		Blob:         b,
+		Engine:       "js",
+		Cache:        wazero.NewCompilationCache(),
+		Fetch:        FetchConfig{AllowedHosts: []string{u.Host}},
+		KV:           memoryKV{"counter": []byte("1")},
+	})
+	require.Nil(t, err)
+	defer r.Close()
+
+	call := func(call string) map[string]any {
+		out.Reset()
+		require.Nil(t, r.Invoke(bytes.NewReader(nil), map[string]string{"CALL": call}))
+		_, res, status, err := shared.ParseStdout(out)
+		require.Nil(t, err)
+		require.Equal(t, http.StatusOK, status)
+		var answer map[string]any
+		require.Nil(t, json.Unmarshal(res, &answer), string(res))
+		return answer
+	}
+
+	answer := call(`{"fetch":{"method":"POST","URL":"` + upstream.URL + `","header":{"x-foo":{"fields":["bar"]}},"body":"aGk="}}`)
+	require.Equal(t, float64(http.StatusCreated), answer["statusCode"])
+	body, err := base64.StdEncoding.DecodeString(answer["body"].(string))
+	require.Nil(t, err)
+	require.Equal(t, "POST bar h\u00e9llo", string(body))
+	require.Equal(t, map[string]any{"fields": []any{"yes"}}, answer["header"].(map[string]any)["X-Upstream"])
+
+	answer = call(`{"fetch":{"URL":"https://example.com"}}`)
+	require.Contains(t, answer["error"], "is not allowed")
+
+	answer = call(`{"kv":{"op":"get","key":"counter"}}`)
+	require.Equal(t, map[string]any{"value": "MQ==", "found": true}, answer)
+	answer = call(`{"kv":{"op":"put","key":"counter","value":"Mg=="}}`)
+	require.Empty(t, answer)
+	answer = call(`{"kv":{"op":"get","key":"counter"}}`)
+	require.Equal(t, "Mg==", answer["value"])
+	answer = call(`{"kv":{"op":"get","key":"missing"}}`)
+	require.Nil(t, answer["found"])
+
+	answer = call(`{"unknown":{}}`)
+	require.Equal(t, "unknown host call", answer["error"])
+}
+
+func TestStdioHostPassesOutputOn(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	h := newStdioHost(context.Background(), newFetcher(FetchConfig{}), &kvHost{}, bytes.NewReader([]byte("stdin")), stderr)
+	h.Write([]byte("log \xc3"))
+	h.Write([]byte("\xa9\n\x1brap"))
+	require.Equal(t, "log \xc3\xa9\n", stderr.String())
+	h.Write([]byte("tor:{\"fetch\":{\"URL\":\"http://h\u00e9st\"}}\nrest"))
+	h.flush()
+	require.Equal(t, "log \xc3\xa9\nrest", stderr.String())
+
+	// Answers are ASCII lines that are read before stdin.
+	b, err := io.ReadAll(h)
+	require.Nil(t, err)
+	answer, rest, ok := strings.Cut(string(b), "\n")
+	require.True(t, ok)
+	require.Equal(t, "stdin", rest)
+	require.Contains(t, answer, `h\u00e9st`)
+	var res proto.FetchResponse
+	require.Nil(t, protojson.Unmarshal([]byte(answer), &res))
+	require.Equal(t, "host h\u00e9st is not allowed", res.Error)
+}
+
+type memoryKV map[string][]byte
+
+func (kv memoryKV) Get(key string) ([]byte, error) {
+	value, ok := kv[key]
+	if !ok {
+		return nil, ErrKVNotFound
+	}
+	return value, nil
+}
+
+func (kv memoryKV) Put(key string, value []byte, _ time.Duration) error {
+	kv[key] = value
+	return nil
+}
+
+func (kv memoryKV) Delete(key string) error {
+	delete(kv, key)
+	return nil
+}
+
+func (kv memoryKV) List(prefix string, limit int) ([]string, error) {
+	return nil, nil
+}
+
+func TestRuntimeKV(t *testing.T) {
+	b, err := os.ReadFile("../_testdata/kv.wasm")
+	require.Nil(t, err)
+	breq, err := pb.Marshal(&proto.HTTPRequest{Method: "GET", URL: "/"})
+	require.Nil(t, err)
+
+	out := &bytes.Buffer{}
+	kv := memoryKV{}
+	r, err := New(context.Background(), Args{
+		Stdout:       out,
+		DeploymentID: uuid.New(),
+		Blob:         b,
+		Engine:       "go",
+		Cache:        wazero.NewCompilationCache(),
+		KV:           kv,
+	})
+	require.Nil(t, err)
+	defer r.Close()
+
+	// The counter outlives the instance of each invocation.
+	for _, expected := range []string{"1", "2", "3"} {
+		require.Nil(t, r.Invoke(bytes.NewReader(breq), nil))
+		_, res, status, err := shared.ParseStdout(out)
+		require.Nil(t, err)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, expected, string(res))
+		out.Reset()
+	}
+	require.Equal(t, "3", string(kv["counter"]))
+}
@@ -0,0 +1,91 @@
+package runtime
+
+import (
+	_ "embed"
+	"encoding/json"
+	"strings"
+
+	"github.com/anthdm/raptor/proto"
+)
+
+// jsSDK is the SDK of the js runtime, which is injected before the script of
+// every deployment.
+//
+//go:embed sdk.js
+var jsSDK string
+
+// jsRequest is the request as it is handed to the SDK.
+type jsRequest struct {
+	Method  string              `json:"method"`
+	URL     string              `json:"url"`
+	Headers map[string][]string `json:"headers"`
+	// Body is base64 encoded.
+	Body []byte            `json:"body"`
+	Env  map[string]string `json:"env"`
+}
+
+// JSScript returns the script the js runtime evaluates to handle req: the
+// SDK, initialized with the request and env, then the script of the
+// deployment and the dispatch of its fetch handler.
+func JSScript(script []byte, req *proto.HTTPRequest, env map[string]string) (string, error) {
+	jsReq := jsRequest{
+		Method:  strings.ToUpper(req.Method),
+		URL:     req.URL,
+		Headers: make(map[string][]string, len(req.Header)),
+		Body:    req.Body,
+		Env:     env,
+	}
+	for name, header := range req.Header {
+		jsReq.Headers[name] = header.Fields
+	}
+	if jsReq.Env == nil {
+		jsReq.Env = map[string]string{}
+	}
+	b, err := json.Marshal(jsReq)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(jsSDK)
+	sb.WriteString("__raptor.init(")
+	sb.Write(b)
+	sb.WriteString(");\n")
+	sb.Write(script)
+	sb.WriteString("\n;__raptor.dispatch();\n")
+	return sb.String(), nil
+}
+
+// JSArgs returns the arguments the js runtime is invoked with to handle req
+// with the given script.
+func JSArgs(script []byte, req *proto.HTTPRequest, env map[string]string) ([]string, error) {
+	s, err := JSScript(script, req, env)
+	if err != nil {
+		return nil, err
+	}
+	return []string{"", "-e", s}, nil
+}
+
+// pythonSDK is the SDK of the python runtime, which is injected before the
+// script of every deployment as the raptor module.
+//
+//go:embed sdk.py
+var pythonSDK string
+
+// PythonScript returns the script the python runtime evaluates: the SDK,
+// then the script of the deployment, which serves the request with
+// raptor.handle.
+func PythonScript(script []byte) string {
+	var sb strings.Builder
+	sb.WriteString(pythonSDK)
+	sb.WriteString("\n")
+	sb.Write(script)
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// PythonArgs returns the arguments the python runtime is invoked with to
+// run the given script.
+func PythonArgs(script []byte) []string {
+	return []string{"python", "-c", PythonScript(script)}
+}
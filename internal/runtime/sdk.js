@@ -0,0 +1,445 @@
+// The raptor SDK of the js runtime. It is injected by the runtime before the
+// script of every deployment and exposes a Workers like API:
+//
+//   addEventListener("fetch", (event) => {
+//     event.respondWith(new Response("Hello " + event.request.url));
+//   });
+//
+// The handler may also respond with a Promise of a Response. The environment
+// of the endpoint is available as event.env, fetch sends outbound requests
+// through the host and KV gives access to the key-value store of the
+// endpoint.
+(function (global) {
+    "use strict";
+
+    function utf8Encode(str) {
+        var bytes = [];
+        for (var i = 0; i < str.length; i++) {
+            var c = str.codePointAt(i);
+            if (c > 0xffff) {
+                i++;
+            }
+            if (c < 0x80) {
+                bytes.push(c);
+            } else if (c < 0x800) {
+                bytes.push(0xc0 | (c >> 6), 0x80 | (c & 0x3f));
+            } else if (c < 0x10000) {
+                bytes.push(0xe0 | (c >> 12), 0x80 | ((c >> 6) & 0x3f), 0x80 | (c & 0x3f));
+            } else {
+                bytes.push(0xf0 | (c >> 18), 0x80 | ((c >> 12) & 0x3f), 0x80 | ((c >> 6) & 0x3f), 0x80 | (c & 0x3f));
+            }
+        }
+        return new Uint8Array(bytes);
+    }
+
+    function utf8Decode(bytes) {
+        var str = "";
+        for (var i = 0; i < bytes.length;) {
+            var b = bytes[i++];
+            var c;
+            if (b < 0x80) {
+                c = b;
+            } else if (b < 0xe0) {
+                c = ((b & 0x1f) << 6) | (bytes[i++] & 0x3f);
+            } else if (b < 0xf0) {
+                c = ((b & 0x0f) << 12) | ((bytes[i++] & 0x3f) << 6) | (bytes[i++] & 0x3f);
+            } else {
+                c = ((b & 0x07) << 18) | ((bytes[i++] & 0x3f) << 12) | ((bytes[i++] & 0x3f) << 6) | (bytes[i++] & 0x3f);
+            }
+            str += String.fromCodePoint(c);
+        }
+        return str;
+    }
+
+    var base64Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/";
+
+    function base64Decode(str) {
+        var bytes = [];
+        var bits = 0;
+        var n = 0;
+        for (var i = 0; i < str.length; i++) {
+            var v = base64Alphabet.indexOf(str[i]);
+            if (v < 0) {
+                continue;
+            }
+            bits = (bits << 6) | v;
+            n += 6;
+            if (n >= 8) {
+                n -= 8;
+                bytes.push((bits >> n) & 0xff);
+            }
+        }
+        return new Uint8Array(bytes);
+    }
+
+    function base64Encode(bytes) {
+        var str = "";
+        for (var i = 0; i < bytes.length; i += 3) {
+            var n = (bytes[i] << 16) | ((bytes[i + 1] || 0) << 8) | (bytes[i + 2] || 0);
+            str += base64Alphabet[(n >> 18) & 63] + base64Alphabet[(n >> 12) & 63];
+            str += i + 1 < bytes.length ? base64Alphabet[(n >> 6) & 63] : "=";
+            str += i + 2 < bytes.length ? base64Alphabet[n & 63] : "=";
+        }
+        return str;
+    }
+
+    // HOST_CALL_PREFIX starts the lines that call the host, see
+    // runtime.HostCallPrefix.
+    var HOST_CALL_PREFIX = "\x1braptor:";
+
+    // hostCall calls the host with a line on stderr and returns the answer
+    // the host writes to stdin. Both lines are ASCII, hence the characters
+    // that are not are escaped.
+    function hostCall(call) {
+        var line = JSON.stringify(call).replace(/[\u007f-\uffff]/g, function (c) {
+            return "\\u" + ("0000" + c.charCodeAt(0).toString(16)).slice(-4);
+        });
+        printErr(HOST_CALL_PREFIX + line);
+        var answer = readline();
+        if (answer === null) {
+            throw new Error("raptor: the host did not answer");
+        }
+        return JSON.parse(answer);
+    }
+
+    // toBytes converts the body of a Request or Response to bytes.
+    function toBytes(body) {
+        if (body === null || body === undefined) {
+            return new Uint8Array(0);
+        }
+        if (body instanceof Uint8Array) {
+            return body;
+        }
+        if (body instanceof ArrayBuffer) {
+            return new Uint8Array(body);
+        }
+        if (ArrayBuffer.isView(body)) {
+            return new Uint8Array(body.buffer, body.byteOffset, body.byteLength);
+        }
+        return utf8Encode(String(body));
+    }
+
+    function Headers(init) {
+        this._map = {};
+        if (init instanceof Headers) {
+            init = init._map;
+        }
+        for (var name in init || {}) {
+            var values = init[name];
+            if (!Array.isArray(values)) {
+                values = [values];
+            }
+            for (var i = 0; i < values.length; i++) {
+                this.append(name, values[i]);
+            }
+        }
+    }
+
+    Headers.prototype.append = function (name, value) {
+        name = String(name).toLowerCase();
+        (this._map[name] = this._map[name] || []).push(String(value));
+    };
+
+    Headers.prototype.set = function (name, value) {
+        this._map[String(name).toLowerCase()] = [String(value)];
+    };
+
+    Headers.prototype.get = function (name) {
+        var values = this._map[String(name).toLowerCase()];
+        return values ? values.join(", ") : null;
+    };
+
+    Headers.prototype.has = function (name) {
+        return String(name).toLowerCase() in this._map;
+    };
+
+    Headers.prototype.delete = function (name) {
+        delete this._map[String(name).toLowerCase()];
+    };
+
+    Headers.prototype.forEach = function (fn, thisArg) {
+        for (var name in this._map) {
+            fn.call(thisArg, this.get(name), name, this);
+        }
+    };
+
+    Headers.prototype.entries = function () {
+        var entries = [];
+        this.forEach(function (value, name) {
+            entries.push([name, value]);
+        });
+        return entries[Symbol.iterator]();
+    };
+
+    Headers.prototype[Symbol.iterator] = Headers.prototype.entries;
+
+    // Body implements the methods that read the body of a Request or
+    // Response.
+    function Body() {}
+
+    Body.prototype.arrayBuffer = function () {
+        var b = this._bytes;
+        return Promise.resolve(b.buffer.slice(b.byteOffset, b.byteOffset + b.byteLength));
+    };
+
+    Body.prototype.bytes = function () {
+        return Promise.resolve(this._bytes);
+    };
+
+    Body.prototype.text = function () {
+        return Promise.resolve(utf8Decode(this._bytes));
+    };
+
+    Body.prototype.json = function () {
+        return this.text().then(JSON.parse);
+    };
+
+    function Request(input, init) {
+        init = init || {};
+        this.method = String(init.method || "GET").toUpperCase();
+        this.url = String(input);
+        this.headers = new Headers(init.headers);
+        this._bytes = toBytes(init.body);
+    }
+
+    Request.prototype = Object.create(Body.prototype);
+    Request.prototype.constructor = Request;
+
+    // Response is the response of a handler.
+    function Response(body, init) {
+        init = init || {};
+        this.status = init.status === undefined ? 200 : init.status;
+        this.statusText = init.statusText || "";
+        this.headers = new Headers(init.headers);
+        this._bytes = toBytes(body);
+    }
+
+    Response.prototype = Object.create(Body.prototype);
+    Response.prototype.constructor = Response;
+
+    Object.defineProperty(Response.prototype, "ok", {
+        get: function () {
+            return this.status >= 200 && this.status <= 299;
+        },
+    });
+
+    Response.json = function (data, init) {
+        init = init || {};
+        var headers = new Headers(init.headers);
+        if (!headers.has("content-type")) {
+            headers.set("content-type", "application/json");
+        }
+        return new Response(JSON.stringify(data), {
+            status: init.status,
+            statusText: init.statusText,
+            headers: headers,
+        });
+    };
+
+    // fetch sends the request through the host, which allows the hosts of
+    // the allowed_hosts of the endpoint. The host sends it right away, hence
+    // the promise is settled when fetch returns.
+    function fetch(input, init) {
+        try {
+            var req = input instanceof Request && init === undefined ? input : new Request(input instanceof Request ? input.url : input, init);
+            var header = {};
+            for (var name in req.headers._map) {
+                header[name] = { fields: req.headers._map[name] };
+            }
+            var res = hostCall({
+                fetch: {
+                    method: req.method,
+                    URL: req.url,
+                    header: header,
+                    body: base64Encode(req._bytes),
+                },
+            });
+            if (res.error) {
+                return Promise.reject(new TypeError("fetch failed: " + res.error));
+            }
+            var headers = new Headers();
+            for (var key in res.header || {}) {
+                var values = res.header[key].fields || [];
+                for (var i = 0; i < values.length; i++) {
+                    headers.append(key, values[i]);
+                }
+            }
+            return Promise.resolve(new Response(base64Decode(res.body || ""), {
+                status: res.statusCode,
+                headers: headers,
+            }));
+        } catch (err) {
+            return Promise.reject(err);
+        }
+    }
+
+    // kvCall sends the operation on the key-value store to the host.
+    function kvCall(req) {
+        try {
+            var res = hostCall({ kv: req });
+            if (res.error) {
+                return Promise.reject(new Error("kv: " + res.error));
+            }
+            return Promise.resolve(res);
+        } catch (err) {
+            return Promise.reject(err);
+        }
+    }
+
+    // KV is the key-value store of the endpoint. Its data outlives requests
+    // and is shared by all deployments of the endpoint.
+    var KV = {
+        // get resolves to the value of the key, or null when it does not
+        // exist. The value is read as "text" (the default), "json" or
+        // "bytes".
+        get: function (key, type) {
+            return kvCall({ op: "get", key: String(key) }).then(function (res) {
+                if (!res.found) {
+                    return null;
+                }
+                var bytes = base64Decode(res.value || "");
+                if (type === "bytes") {
+                    return bytes;
+                }
+                if (type === "json") {
+                    return JSON.parse(utf8Decode(bytes));
+                }
+                return utf8Decode(bytes);
+            });
+        },
+        // put sets the value of the key, a string or bytes. The key expires
+        // after options.ttl seconds when given.
+        put: function (key, value, options) {
+            var ttl = options && options.ttl ? options.ttl : 0;
+            return kvCall({
+                op: "put",
+                key: String(key),
+                value: base64Encode(toBytes(value)),
+                ttlMillis: String(Math.round(ttl * 1000)),
+            }).then(function () {});
+        },
+        delete: function (key) {
+            return kvCall({ op: "delete", key: String(key) }).then(function () {});
+        },
+        // list resolves to the keys with options.prefix in lexical order, at
+        // most options.limit or 100 of them.
+        list: function (options) {
+            options = options || {};
+            return kvCall({
+                op: "list",
+                prefix: options.prefix || "",
+                limit: options.limit || 0,
+            }).then(function (res) {
+                return res.keys || [];
+            });
+        },
+    };
+
+    var listeners = [];
+    var request = null;
+    var env = {};
+
+    function addEventListener(type, listener) {
+        if (type !== "fetch") {
+            throw new TypeError("unsupported event type: " + type);
+        }
+        listeners.push(listener);
+    }
+
+    function FetchEvent(request, env) {
+        this.type = "fetch";
+        this.request = request;
+        this.env = env;
+        this._response = undefined;
+    }
+
+    FetchEvent.prototype.respondWith = function (response) {
+        if (this._response !== undefined) {
+            throw new Error("respondWith was already called");
+        }
+        this._response = response;
+    };
+
+    // write writes the headers and the body of the response to stdout,
+    // followed by its status, the length of its body and the length of its
+    // headers as little endian uint32's and the "RPH1" magic. The headers
+    // are a "Name: value\n" line per value.
+    function write(status, headers, bytes) {
+        var block = "";
+        var names = Object.keys(headers ? headers._map : {}).sort();
+        for (var i = 0; i < names.length; i++) {
+            var values = headers._map[names[i]];
+            if (names[i] === "" || /[:\r\n]/.test(names[i])) {
+                continue;
+            }
+            for (var j = 0; j < values.length; j++) {
+                if (!/[\r\n]/.test(values[j])) {
+                    block += names[i] + ": " + values[j] + "\n";
+                }
+            }
+        }
+        var blockBytes = utf8Encode(block);
+        if (blockBytes.length > 0) {
+            writebytes(new DataView(blockBytes.buffer, blockBytes.byteOffset, blockBytes.byteLength));
+        }
+        if (bytes.length > 0) {
+            writebytes(new DataView(bytes.buffer, bytes.byteOffset, bytes.byteLength));
+        }
+        var view = new DataView(new ArrayBuffer(16));
+        view.setUint32(0, status, true);
+        view.setUint32(4, bytes.length, true);
+        view.setUint32(8, blockBytes.length, true);
+        view.setUint32(12, 0x31485052, true);
+        writebytes(view);
+    }
+
+    function fail(err) {
+        console.log("uncaught error in fetch handler: " + (err && err.stack ? err.stack : err));
+        write(500, null, utf8Encode("internal server error"));
+    }
+
+    global.__raptor = {
+        // init is called by the runtime with the request, before the script.
+        init: function (req) {
+            request = new Request(req.url, {
+                method: req.method,
+                headers: req.headers,
+                body: base64Decode(req.body || ""),
+            });
+            env = req.env || {};
+        },
+        // dispatch is called by the runtime after the script. Scripts that do
+        // not listen to fetch events write their response themselves.
+        dispatch: function () {
+            if (listeners.length === 0) {
+                return;
+            }
+            var event = new FetchEvent(request, env);
+            try {
+                for (var i = 0; i < listeners.length && event._response === undefined; i++) {
+                    listeners[i].call(global, event);
+                }
+            } catch (err) {
+                fail(err);
+                return;
+            }
+            if (event._response === undefined) {
+                fail(new Error("no fetch handler called respondWith"));
+                return;
+            }
+            Promise.resolve(event._response).then(function (response) {
+                if (!(response instanceof Response)) {
+                    throw new TypeError("fetch handler did not respond with a Response");
+                }
+                write(response.status, response.headers, response._bytes);
+            }).catch(fail);
+        },
+    };
+
+    global.Headers = Headers;
+    global.Request = Request;
+    global.Response = Response;
+    global.addEventListener = addEventListener;
+    global.fetch = fetch;
+    global.KV = KV;
+})(globalThis);
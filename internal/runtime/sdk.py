@@ -0,0 +1,217 @@
+# The SDK of the python runtime. It is injected before the script of every
+# deployment as the raptor module, which serves the request like the Go SDK:
+# the request is read from stdin as a protobuf encoded HTTPRequest and the
+# headers and the body of the response are written to stdout, followed by
+# its status, the length of its body and the length of its headers as little
+# endian uint32s and the RPH1 magic.
+#
+#     import raptor
+#
+#     def handler(request):
+#         return raptor.Response("hello " + request.query.get("name", ""))
+#
+#     raptor.handle(handler)
+def __raptor_sdk():
+    import json
+    import struct
+    import sys
+    import traceback
+    import types
+    from urllib.parse import parse_qsl, urlsplit
+
+    def fields(b):
+        # Yields the number and value of every field of a protobuf message.
+        # Fields of the bytes type are yielded as bytes, varints as ints.
+        i = 0
+
+        def varint():
+            nonlocal i
+            v, shift = 0, 0
+            while True:
+                if i >= len(b):
+                    raise ValueError("invalid request: truncated varint")
+                c = b[i]
+                i += 1
+                v |= (c & 0x7F) << shift
+                if c < 0x80:
+                    return v
+                shift += 7
+
+        while i < len(b):
+            tag = varint()
+            typ = tag & 7
+            if typ == 0:
+                value = varint()
+            elif typ == 2:
+                n = varint()
+                value = b[i : i + n]
+                i += n
+            elif typ == 1:
+                value, i = None, i + 8
+            elif typ == 5:
+                value, i = None, i + 4
+            else:
+                raise ValueError("invalid request: unsupported wire type %d" % typ)
+            if i > len(b):
+                raise ValueError("invalid request: truncated field")
+            yield tag >> 3, value
+
+    def map_entry(b):
+        key, value = "", b""
+        for num, v in fields(b):
+            if num == 1:
+                key = v.decode()
+            elif num == 2:
+                value = v
+        return key, value
+
+    class Info:
+        """What raptor knows about the request that is being handled."""
+
+        def __init__(self):
+            self.request_id = ""
+            self.endpoint_id = ""
+            self.deployment_id = ""
+            # True when the request is made on the preview of the deployment
+            # instead of LIVE.
+            self.preview = False
+            # True when the request is made by a cron schedule.
+            self.scheduled = False
+
+    class Headers(dict):
+        """The headers of a request, with case insensitive names."""
+
+        def get(self, name, default=None):
+            for key, values in self.items():
+                if key.lower() == name.lower() and values:
+                    return values[0]
+            return default
+
+    class Request:
+        """The request as it was received by the ingress."""
+
+        def __init__(self, b):
+            self.method = ""
+            # The path and query of the request.
+            self.url = ""
+            self.headers = Headers()
+            self.body = b""
+            self.host = ""
+            self.remote_addr = ""
+            # The environment of the endpoint, which is also in os.environ.
+            self.env = {}
+            self.info = Info()
+            for num, v in fields(b):
+                if num == 1:
+                    self.body = bytes(v)
+                elif num == 2:
+                    self.method = v.decode()
+                elif num == 3:
+                    self.url = v.decode()
+                elif num == 4:
+                    self.info.endpoint_id = v.decode()
+                elif num == 5:
+                    self.info.request_id = v.decode()
+                elif num == 6:
+                    key, value = map_entry(v)
+                    self.headers[key] = [f.decode() for n, f in fields(value) if n == 1]
+                elif num == 8:
+                    self.info.deployment_id = v.decode()
+                elif num == 9:
+                    key, value = map_entry(v)
+                    self.env[key] = value.decode()
+                elif num == 10:
+                    self.info.preview = v != 0
+                elif num == 13:
+                    self.info.scheduled = v != 0
+                elif num == 14:
+                    self.host = v.decode()
+                elif num == 15:
+                    self.remote_addr = v.decode()
+            url = urlsplit(self.url)
+            self.path = url.path
+            # The first value of every query parameter.
+            self.query = {}
+            for key, value in parse_qsl(url.query, keep_blank_values=True):
+                self.query.setdefault(key, value)
+
+        def text(self):
+            return self.body.decode()
+
+        def json(self):
+            return json.loads(self.body)
+
+    class Response:
+        """The response of a handler. The values of headers are a str or a
+        list of them."""
+
+        def __init__(self, body=b"", status=200, headers=None):
+            if isinstance(body, str):
+                body = body.encode()
+            self.body = bytes(body)
+            self.status = status
+            self.headers = dict(headers or {})
+
+        @staticmethod
+        def json(data, status=200, headers=None):
+            headers = dict(headers or {})
+            headers.setdefault("Content-Type", "application/json")
+            return Response(json.dumps(data), status, headers)
+
+    def to_response(v):
+        # Handlers may return a Response, the body as str or bytes, an object
+        # that is sent as JSON or nothing.
+        if isinstance(v, Response):
+            return v
+        if v is None:
+            return Response()
+        if isinstance(v, (str, bytes, bytearray)):
+            return Response(v)
+        return Response.json(v)
+
+    def header_block(headers):
+        # A "Name: value\n" line per value, names and values that do not fit
+        # on a line are left out.
+        lines = []
+        for name in sorted(headers):
+            values = headers[name]
+            if isinstance(values, str):
+                values = [values]
+            if not name or any(c in name for c in ":\r\n"):
+                continue
+            lines.extend("%s: %s\n" % (name, v) for v in values if "\r" not in v and "\n" not in v)
+        return "".join(lines).encode()
+
+    def serve(handler, b, out):
+        req = Request(b)
+        try:
+            resp = to_response(handler(req))
+        except Exception as e:
+            # The exception and its traceback go to the logs of the request.
+            sys.stdout.write("panic serving %s %s: %s\n%s" % (req.method, req.url, e, traceback.format_exc()))
+            resp = Response.json({"error": "internal server error", "request_id": req.info.request_id}, status=500)
+        sys.stdout.flush()
+        block = header_block(resp.headers)
+        out.write(block)
+        out.write(resp.body)
+        out.write(struct.pack("<III", resp.status, len(resp.body), len(block)))
+        out.write(b"RPH1")
+        out.flush()
+
+    def handle(handler):
+        """Reads the request from stdin, handles it with handler and writes
+        the response to stdout. A handler that raises responds with a 500."""
+        serve(handler, sys.stdin.buffer.read(), sys.stdout.buffer)
+
+    sdk = types.ModuleType("raptor")
+    sdk.Info = Info
+    sdk.Headers = Headers
+    sdk.Request = Request
+    sdk.Response = Response
+    sdk.handle = handle
+    sdk.serve = serve
+    sys.modules["raptor"] = sdk
+
+
+__raptor_sdk()
+del __raptor_sdk
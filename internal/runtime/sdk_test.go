@@ -0,0 +1,194 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/anthdm/raptor/internal/cpython"
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/internal/spidermonkey"
+	"github.com/anthdm/raptor/proto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tetratelabs/wazero"
+	pb "google.golang.org/protobuf/proto"
+)
+
+func TestJSScript(t *testing.T) {
+	req := &proto.HTTPRequest{
+		Method: "post",
+		URL:    "/users",
+		Header: map[string]*proto.HeaderFields{
+			"Content-Type": {Fields: []string{"application/json"}},
+		},
+		Body: []byte(`{"name":"bob"}`),
+	}
+	script, err := JSScript([]byte("console.log(1)"), req, map[string]string{"FOO": "bar"})
+	require.Nil(t, err)
+	require.True(t, strings.HasPrefix(script, jsSDK))
+	require.True(t, strings.HasSuffix(script, "console.log(1)\n;__raptor.dispatch();\n"))
+
+	init := strings.TrimPrefix(script, jsSDK+"__raptor.init(")
+	init = init[:strings.Index(init, ");\n")]
+	var jsReq jsRequest
+	require.Nil(t, json.Unmarshal([]byte(init), &jsReq))
+	require.Equal(t, jsRequest{
+		Method:  "POST",
+		URL:     "/users",
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+		Body:    req.Body,
+		Env:     map[string]string{"FOO": "bar"},
+	}, jsReq)
+
+	// The env is always an object, so scripts can read it without checks.
+	script, err = JSScript(nil, &proto.HTTPRequest{Method: "GET", URL: "/"}, nil)
+	require.Nil(t, err)
+	require.Contains(t, script, `"env":{}`)
+}
+
+func TestRuntimeInvokeJSSDK(t *testing.T) {
+	b, err := os.ReadFile("../_testdata/sdk.js")
+	require.Nil(t, err)
+
+	req := &proto.HTTPRequest{
+		Method: "POST",
+		URL:    "/users?id=1",
+		Header: map[string]*proto.HeaderFields{
+			"Content-Type": {Fields: []string{"application/json"}},
+		},
+		Body: []byte(`{"name":"bob"}`),
+	}
+	out := &bytes.Buffer{}
+	r, err := New(context.Background(), Args{
+		Stdout:       out,
+		DeploymentID: uuid.New(),
+		Blob:         spidermonkey.WasmBlob,
+		Engine:       "js",
+		Cache:        wazero.NewCompilationCache(),
+	})
+	require.Nil(t, err)
+	defer r.Close()
+
+	env := map[string]string{"FOO": "bar"}
+	args, err := JSArgs(b, req, env)
+	require.Nil(t, err)
+	require.Nil(t, r.Invoke(bytes.NewReader(nil), env, args...))
+
+	logs, res, status, err := shared.ParseStdout(out)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusCreated, status)
+	require.Contains(t, string(logs), "request POST /users?id=1")
+	require.JSONEq(t, `{
+		"method": "POST",
+		"url": "/users?id=1",
+		"contentType": "application/json",
+		"name": "bob",
+		"foo": "bar"
+	}`, string(res))
+}
+
+func TestPythonArgs(t *testing.T) {
+	args := PythonArgs([]byte("raptor.handle(handler)"))
+	require.Len(t, args, 3)
+	require.Equal(t, []string{"python", "-c"}, args[:2])
+	require.True(t, strings.HasPrefix(args[2], pythonSDK))
+	require.True(t, strings.HasSuffix(args[2], "\nraptor.handle(handler)\n"))
+}
+
+// pythonInvoker invokes the script with the request and returns the output
+// of the python runtime.
+type pythonInvoker func(script []byte, req *proto.HTTPRequest, env map[string]string) []byte
+
+func TestRuntimeInvokePythonSDK(t *testing.T) {
+	if len(cpython.WasmBlob) == 0 {
+		// CI fetches the interpreter, so the test must not pass without it.
+		if os.Getenv("CI") != "" {
+			t.Fatal("python.wasm is not vendored, fetch it with make cpython")
+		}
+		t.Skip("python.wasm is not vendored, fetch it with make cpython")
+	}
+	out := &bytes.Buffer{}
+	r, err := New(context.Background(), Args{
+		Stdout:       out,
+		DeploymentID: uuid.New(),
+		Blob:         cpython.WasmBlob,
+		Engine:       "python",
+		Cache:        wazero.NewCompilationCache(),
+	})
+	require.Nil(t, err)
+	defer r.Close()
+
+	testPythonSDK(t, func(script []byte, req *proto.HTTPRequest, env map[string]string) []byte {
+		defer out.Reset()
+		breq, err := pb.Marshal(req)
+		require.Nil(t, err)
+		require.Nil(t, r.Invoke(bytes.NewReader(breq), env, PythonArgs(script)...))
+		return bytes.Clone(out.Bytes())
+	})
+}
+
+// TestPythonSDK runs the SDK with the python3 of the host, which runs the
+// same script as the interpreter of the runtime, so the decoding of the
+// request and the encoding of the response are tested without python.wasm.
+func TestPythonSDK(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is not installed")
+	}
+	testPythonSDK(t, func(script []byte, req *proto.HTTPRequest, env map[string]string) []byte {
+		breq, err := pb.Marshal(req)
+		require.Nil(t, err)
+		args := PythonArgs(script)
+		cmd := exec.Command(python, args[1:]...)
+		cmd.Stdin = bytes.NewReader(breq)
+		for k, v := range env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+		out, err := cmd.Output()
+		require.Nil(t, err)
+		return out
+	})
+}
+
+func testPythonSDK(t *testing.T, invoke pythonInvoker) {
+	b, err := os.ReadFile("../_testdata/sdk.py")
+	require.Nil(t, err)
+
+	env := map[string]string{"FOO": "bar"}
+	logs, res, header, status, err := shared.ParseResponse(bytes.NewReader(invoke(b, &proto.HTTPRequest{
+		ID:     "request-id",
+		Method: "POST",
+		URL:    "/users?id=1",
+		Header: map[string]*proto.HeaderFields{
+			"Content-Type": {Fields: []string{"application/json"}},
+		},
+		Body: []byte(`{"name":"bob"}`),
+		Env:  env,
+	}, env)))
+	require.Nil(t, err)
+	require.Equal(t, http.StatusCreated, status)
+	require.Equal(t, "application/json", header.Get("Content-Type"))
+	require.Contains(t, string(logs), "request POST /users?id=1")
+	require.JSONEq(t, `{
+		"method": "POST",
+		"url": "/users?id=1",
+		"contentType": "application/json",
+		"name": "bob",
+		"id": "1",
+		"foo": "bar",
+		"environ": "bar",
+		"requestID": "request-id"
+	}`, string(res))
+
+	logs, res, status, err = shared.ParseStdout(bytes.NewReader(invoke(b, &proto.HTTPRequest{ID: "request-id", Method: "GET", URL: "/panic"}, env)))
+	require.Nil(t, err)
+	require.Equal(t, http.StatusInternalServerError, status)
+	require.Contains(t, string(logs), "panic serving GET /panic: boom")
+	require.JSONEq(t, `{"error":"internal server error","request_id":"request-id"}`, string(res))
+}
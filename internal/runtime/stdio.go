@@ -0,0 +1,150 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/anthdm/raptor/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	prot "google.golang.org/protobuf/proto"
+)
+
+// HostCallPrefix starts the lines script guests write to stderr to call the
+// host. The engine of the js runtime is prebuilt and can not import host
+// functions, hence its scripts call the host over stdio instead: a call is a
+// line on stderr holding the prefix and a JSON object with either a "fetch"
+// FetchRequest or a "kv" KVRequest, and the host answers with a line on
+// stdin holding the FetchResponse or KVResponse, in the JSON encoding of
+// protobuf. Both lines are ASCII.
+const HostCallPrefix = "\x1braptor:"
+
+// stdioHost serves the calls of a script guest over stdio. It is the stderr
+// of the guest, passing everything that is not a call on to stderr, and its
+// stdin, reading the answers to the calls before stdin.
+type stdioHost struct {
+	ctx     context.Context
+	fetch   *fetcher
+	kv      *kvHost
+	stdin   io.Reader
+	stderr  io.Writer
+	line    []byte
+	answers bytes.Buffer
+}
+
+func newStdioHost(ctx context.Context, fetch *fetcher, kv *kvHost, stdin io.Reader, stderr io.Writer) *stdioHost {
+	return &stdioHost{
+		ctx:    ctx,
+		fetch:  fetch,
+		kv:     kv,
+		stdin:  stdin,
+		stderr: stderr,
+	}
+}
+
+func (h *stdioHost) Read(p []byte) (int, error) {
+	// Answers are read on their own, so the guest does not buffer stdin
+	// beyond them.
+	if h.answers.Len() > 0 {
+		return h.answers.Read(p)
+	}
+	return h.stdin.Read(p)
+}
+
+func (h *stdioHost) Write(p []byte) (int, error) {
+	h.line = append(h.line, p...)
+	for {
+		i := bytes.IndexByte(h.line, '\n')
+		if i < 0 {
+			break
+		}
+		h.handleLine(h.line[:i+1])
+		h.line = h.line[i+1:]
+	}
+	// Output that can not become a call is passed on right away.
+	if !bytes.HasPrefix(h.line, []byte(HostCallPrefix)) && !bytes.HasPrefix([]byte(HostCallPrefix), h.line) {
+		h.flush()
+	}
+	return len(p), nil
+}
+
+// flush passes the output that is not terminated by a newline on to stderr.
+func (h *stdioHost) flush() {
+	if len(h.line) > 0 {
+		h.stderr.Write(h.line)
+		h.line = nil
+	}
+}
+
+func (h *stdioHost) handleLine(line []byte) {
+	call, ok := bytes.CutPrefix(line, []byte(HostCallPrefix))
+	if !ok {
+		h.stderr.Write(line)
+		return
+	}
+	h.answers.Write(h.call(bytes.TrimSpace(call)))
+	h.answers.WriteByte('\n')
+}
+
+func (h *stdioHost) call(b []byte) []byte {
+	var call struct {
+		Fetch json.RawMessage `json:"fetch"`
+		KV    json.RawMessage `json:"kv"`
+	}
+	if err := json.Unmarshal(b, &call); err != nil {
+		return callError(fmt.Sprintf("invalid host call: %s", err))
+	}
+	switch {
+	case call.Fetch != nil:
+		var req proto.FetchRequest
+		if err := protojson.Unmarshal(call.Fetch, &req); err != nil {
+			return callError(fmt.Sprintf("invalid request: %s", err))
+		}
+		return answer(h.fetch.do(h.ctx, &req))
+	case call.KV != nil:
+		var req proto.KVRequest
+		if err := protojson.Unmarshal(call.KV, &req); err != nil {
+			return callError(fmt.Sprintf("invalid request: %s", err))
+		}
+		return answer(h.kv.do(&req))
+	default:
+		return callError("unknown host call")
+	}
+}
+
+func answer(msg prot.Message) []byte {
+	b, err := protojson.Marshal(msg)
+	if err != nil {
+		return callError(err.Error())
+	}
+	return asciiJSON(b)
+}
+
+func callError(msg string) []byte {
+	b, _ := json.Marshal(map[string]string{"error": msg})
+	return asciiJSON(b)
+}
+
+// asciiJSON escapes the characters of the strings of the given JSON that
+// are not ASCII, so engines read the line regardless of their encoding.
+func asciiJSON(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		b = b[size:]
+		if r < utf8.RuneSelf {
+			out = append(out, byte(r))
+			continue
+		}
+		if r > 0xffff {
+			r -= 0x10000
+			out = fmt.Appendf(out, `\u%04x\u%04x`, 0xd800+(r>>10), 0xdc00+(r&0x3ff))
+			continue
+		}
+		out = fmt.Appendf(out, `\u%04x`, r)
+	}
+	return out
+}
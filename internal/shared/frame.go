@@ -0,0 +1,70 @@
+package shared
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// The frame types of the message protocol that is spoken over stdin and
+// stdout with guests that serve WebSocket connections.
+const (
+	// FrameOpen is the first frame a guest receives. It holds the protobuf
+	// encoded HTTP request that opened the connection.
+	FrameOpen byte = iota + 1
+	FrameText
+	FrameBinary
+	FrameClose
+)
+
+// ModeEnv is the environment variable that tells the guest in which mode it
+// is invoked.
+const ModeEnv = "RAPTOR_MODE"
+
+// ModeWebSocket is the mode of guests serving a WebSocket connection.
+const ModeWebSocket = "websocket"
+
+// ModeLoop is the mode of long-lived guests that serve requests in a loop.
+// They ask the host for the next request with the next_request host
+// function instead of reading it from stdin.
+const ModeLoop = "loop"
+
+// maxFrameSize is the maximum size of the payload of a single frame.
+const maxFrameSize = 16 << 20
+
+// FrameHeaderLen is the length of the header that precedes the payload of a frame.
+const FrameHeaderLen = 5
+
+// Frame is a single message of the message protocol.
+type Frame struct {
+	Type byte
+	Data []byte
+}
+
+// WriteFrame writes the given frame to w. A frame is a single byte type
+// followed by the little endian uint32 length of the payload and the payload.
+func WriteFrame(w io.Writer, frame Frame) error {
+	buf := make([]byte, FrameHeaderLen+len(frame.Data))
+	buf[0] = frame.Type
+	binary.LittleEndian.PutUint32(buf[1:FrameHeaderLen], uint32(len(frame.Data)))
+	copy(buf[FrameHeaderLen:], frame.Data)
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadFrame reads a single frame from r.
+func ReadFrame(r io.Reader) (Frame, error) {
+	header := make([]byte, FrameHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return Frame{}, err
+	}
+	size := binary.LittleEndian.Uint32(header[1:])
+	if size > maxFrameSize {
+		return Frame{}, fmt.Errorf("frame size %d exceeds the maximum of %d bytes", size, maxFrameSize)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return Frame{}, err
+	}
+	return Frame{Type: header[0], Data: data}, nil
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 
 	"github.com/anthdm/raptor/proto"
@@ -19,14 +20,47 @@ const (
 	UUIDZERO = "00000000-0000-0000-0000-000000000000"
 )
 
+// HeaderMagic ends the stdout of guests that send the headers of their
+// response. Their stdout is the logs, the header block, a "Name: value\n"
+// line per value, and the body, followed by the status, the length of the
+// body and the length of the header block as little endian u32s and the
+// magic. The stdout of guests without headers ends with the status and the
+// length of the body only.
+const HeaderMagic = "RPH1"
+
+const headerMagicLen = 16
+
 var errInvalidHTTPResponse = errors.New("invalid HTTP response")
 
 func ParseStdout(stdout io.Reader) (logs []byte, resp []byte, status int, err error) {
+	logs, resp, _, status, err = ParseResponse(stdout)
+	return
+}
+
+// ParseResponse is like ParseStdout, but also returns the headers of the
+// response, which are nil when the guest did not send them.
+func ParseResponse(stdout io.Reader) (logs []byte, resp []byte, header http.Header, status int, err error) {
 	stdoutb, err := io.ReadAll(stdout)
 	if err != nil {
 		return
 	}
 	outLen := len(stdoutb)
+	if outLen >= headerMagicLen && string(stdoutb[outLen-len(HeaderMagic):]) == HeaderMagic {
+		magicStart := outLen - headerMagicLen
+		status = int(binary.LittleEndian.Uint32(stdoutb[magicStart : magicStart+4]))
+		respLen := int(binary.LittleEndian.Uint32(stdoutb[magicStart+4 : magicStart+8]))
+		headerLen := int(binary.LittleEndian.Uint32(stdoutb[magicStart+8 : magicStart+12]))
+		if respLen+headerLen > magicStart {
+			err = fmt.Errorf("response length exceeds available data")
+			return
+		}
+		respStart := magicStart - respLen
+		headerStart := respStart - headerLen
+		resp = stdoutb[respStart:magicStart]
+		header = parseHeader(stdoutb[headerStart:respStart])
+		logs = stdoutb[:headerStart]
+		return
+	}
 	if outLen < magicLen {
 		err = fmt.Errorf("mallformed HTTP response missing last %d bytes", magicLen)
 		return
@@ -44,6 +78,57 @@ func ParseStdout(stdout io.Reader) (logs []byte, resp []byte, status int, err er
 	return
 }
 
+// WriteResponse writes the response to out the way ParseResponse reads it.
+func WriteResponse(out io.Writer, status int, header http.Header, body []byte) error {
+	block := AppendHeader(nil, header)
+	b := make([]byte, 0, len(block)+len(body)+headerMagicLen)
+	b = append(b, block...)
+	b = append(b, body...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(status))
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(body)))
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(block)))
+	b = append(b, HeaderMagic...)
+	_, err := out.Write(b)
+	return err
+}
+
+// AppendHeader appends the header block of the given headers to b, sorted
+// by name. Names and values that do not fit on a line are left out.
+func AppendHeader(b []byte, header http.Header) []byte {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "" || strings.ContainsAny(name, ":\r\n") {
+			continue
+		}
+		for _, v := range header[name] {
+			if strings.ContainsAny(v, "\r\n") {
+				continue
+			}
+			b = append(b, name...)
+			b = append(b, ": "...)
+			b = append(b, v...)
+			b = append(b, '\n')
+		}
+	}
+	return b
+}
+
+func parseHeader(b []byte) http.Header {
+	header := http.Header{}
+	for _, line := range strings.Split(string(b), "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return header
+}
+
 func ParseRuntimeHTTPResponse(in string) (resp string, status int, err error) {
 	if len(in) < 16 {
 		err = fmt.Errorf("misformed HTTP response missing last 16 bytes")
@@ -66,24 +151,36 @@ func MakeProtoRequest(id string, r *http.Request) (*proto.HTTPRequest, error) {
 		return nil, err
 	}
 	return &proto.HTTPRequest{
-		Header: makeProtoHeader(r.Header),
-		ID:     id,
-		Body:   b,
-		Method: r.Method,
-		URL:    trimmedEndpointFromURL(r.URL),
+		Header:     MakeProtoHeader(r.Header),
+		ID:         id,
+		Body:       b,
+		Method:     r.Method,
+		URL:        trimmedEndpointFromURL(r.URL),
+		Host:       r.Host,
+		RemoteAddr: r.RemoteAddr,
 	}, nil
 }
 
 func trimmedEndpointFromURL(url *url.URL) string {
 	path := strings.TrimPrefix(url.Path, "/")
 	pathParts := strings.Split(path, "/")
-	if len(pathParts) == 0 {
-		return "/"
+	if len(pathParts) < 2 {
+		return WithQuery("/", url)
+	}
+	return WithQuery("/"+strings.Join(pathParts[2:], "/"), url)
+}
+
+// WithQuery returns the given path followed by the query of url, if any.
+func WithQuery(path string, url *url.URL) string {
+	if len(url.RawQuery) == 0 {
+		return path
 	}
-	return "/" + strings.Join(pathParts[2:], "/")
+	return path + "?" + url.RawQuery
 }
 
-func makeProtoHeader(header http.Header) map[string]*proto.HeaderFields {
+// MakeProtoHeader returns the given headers as the headers of a request or
+// response message.
+func MakeProtoHeader(header http.Header) map[string]*proto.HeaderFields {
 	m := make(map[string]*proto.HeaderFields, len(header))
 	for k, v := range header {
 		m[k] = &proto.HeaderFields{
@@ -93,6 +190,31 @@ func makeProtoHeader(header http.Header) map[string]*proto.HeaderFields {
 	return m
 }
 
+// hopHeaders are the headers of a guest response that describe the
+// connection to the guest rather than the response, which the ingress sets
+// itself.
+var hopHeaders = []string{
+	"Connection",
+	"Content-Length",
+	"Keep-Alive",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// ResponseHeader returns the headers of the response of a guest that are
+// sent to the client.
+func ResponseHeader(header map[string]*proto.HeaderFields) http.Header {
+	h := make(http.Header, len(header))
+	for k, v := range header {
+		h[http.CanonicalHeaderKey(k)] = v.GetFields()
+	}
+	for _, k := range hopHeaders {
+		h.Del(k)
+	}
+	return h
+}
+
 func IsZeroUUID(id uuid.UUID) bool {
 	return id.String() == UUIDZERO
 }
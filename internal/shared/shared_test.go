@@ -5,7 +5,9 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -88,6 +90,52 @@ the big brown fox
 	require.Equal(t, userLogs, string(logs))
 }
 
+func TestParseResponseWithHeader(t *testing.T) {
+	userLogs := "the big brown fox\n"
+	userResp := "<h1>This is the actual response</h1>"
+	header := http.Header{
+		"Cache-Control": {"max-age=60"},
+		"Set-Cookie":    {"a=1", "b=2"},
+		"X-Bad":         {"line\nbreak"},
+	}
+	builder := &bytes.Buffer{}
+	builder.WriteString(userLogs)
+	require.Nil(t, WriteResponse(builder, 201, header, []byte(userResp)))
+
+	logs, resp, other, status, err := ParseResponse(bytes.NewReader(builder.Bytes()))
+	require.Nil(t, err)
+	require.Equal(t, 201, status)
+	require.Equal(t, userResp, string(resp))
+	require.Equal(t, userLogs, string(logs))
+	require.Equal(t, http.Header{
+		"Cache-Control": {"max-age=60"},
+		"Set-Cookie":    {"a=1", "b=2"},
+	}, other)
+
+	// ParseStdout reads the same stdout without the headers.
+	logs, resp, status, err = ParseStdout(builder)
+	require.Nil(t, err)
+	require.Equal(t, 201, status)
+	require.Equal(t, userResp, string(resp))
+	require.Equal(t, userLogs, string(logs))
+}
+
+func TestParseResponseWithoutHeader(t *testing.T) {
+	userResp := "<h1>This is the actual response</h1>"
+	builder := &bytes.Buffer{}
+	builder.WriteString(userResp)
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint32(buf[0:4], 200)
+	binary.LittleEndian.PutUint32(buf[4:8], uint32(len(userResp)))
+	builder.Write(buf)
+
+	_, resp, header, status, err := ParseResponse(builder)
+	require.Nil(t, err)
+	require.Equal(t, 200, status)
+	require.Equal(t, userResp, string(resp))
+	require.Nil(t, header)
+}
+
 func TestParseRuntimeHTTPResponse(t *testing.T) {
 	text := "This is the best.\nBut not always correct.\nThe big brown fox."
 	statusCode := uint32(500)
@@ -102,3 +150,22 @@ func TestParseRuntimeHTTPResponse(t *testing.T) {
 	require.Equal(t, int(statusCode), status)
 	require.Equal(t, text, resp)
 }
+
+func TestReadWriteFrame(t *testing.T) {
+	buf := &bytes.Buffer{}
+	frames := []Frame{
+		{Type: FrameOpen, Data: []byte("request")},
+		{Type: FrameText, Data: []byte("hello")},
+		{Type: FrameClose, Data: []byte{}},
+	}
+	for _, frame := range frames {
+		require.Nil(t, WriteFrame(buf, frame))
+	}
+	for _, frame := range frames {
+		other, err := ReadFrame(buf)
+		require.Nil(t, err)
+		require.Equal(t, frame, other)
+	}
+	_, err := ReadFrame(buf)
+	require.ErrorIs(t, err, io.EOF)
+}
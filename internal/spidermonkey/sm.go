@@ -2,5 +2,9 @@ package spidermonkey
 
 import _ "embed"
 
+// WasmBlob is the engine of the js runtime. It evaluates the script passed
+// with -e, which calls the host with printErr and readline, see
+// runtime.HostCallPrefix.
+//
 //go:embed js.wasm
 var WasmBlob []byte
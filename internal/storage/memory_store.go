@@ -2,7 +2,10 @@ package storage
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/anthdm/raptor/internal/types"
 	"github.com/google/uuid"
@@ -12,22 +15,56 @@ type MemoryStore struct {
 	mu        sync.RWMutex
 	endpoints map[uuid.UUID]*types.Endpoint
 	deploys   map[uuid.UUID]*types.Deployment
+	domains   map[string]*types.Domain
+	certs     map[string]*types.Certificate
+	kv        map[uuid.UUID]map[string]*types.KVEntry
+	schedules map[uuid.UUID]*types.Schedule
+	runs      map[uuid.UUID][]*types.ScheduleRun
+	invokes   map[uuid.UUID]*types.Invocation
+	metrics   map[uuid.UUID][]types.RequestMetric
+	logs      map[uuid.UUID][]*types.RuntimeLog
 }
 
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
 		endpoints: make(map[uuid.UUID]*types.Endpoint),
 		deploys:   make(map[uuid.UUID]*types.Deployment),
+		domains:   make(map[string]*types.Domain),
+		certs:     make(map[string]*types.Certificate),
+		kv:        make(map[uuid.UUID]map[string]*types.KVEntry),
+		schedules: make(map[uuid.UUID]*types.Schedule),
+		runs:      make(map[uuid.UUID][]*types.ScheduleRun),
+		invokes:   make(map[uuid.UUID]*types.Invocation),
+		metrics:   make(map[uuid.UUID][]types.RequestMetric),
+		logs:      make(map[uuid.UUID][]*types.RuntimeLog),
 	}
 }
 
 func (s *MemoryStore) CreateEndpoint(e *types.Endpoint) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if len(e.Slug) > 0 {
+		for _, endpoint := range s.endpoints {
+			if endpoint.Slug == e.Slug {
+				return fmt.Errorf("endpoint with slug (%s) already exists", e.Slug)
+			}
+		}
+	}
 	s.endpoints[e.ID] = e
 	return nil
 }
 
+func (s *MemoryStore) GetEndpointBySlug(slug string) (*types.Endpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, endpoint := range s.endpoints {
+		if len(slug) > 0 && endpoint.Slug == slug {
+			return endpoint, nil
+		}
+	}
+	return nil, fmt.Errorf("could not find endpoint with slug (%s)", slug)
+}
+
 func (s *MemoryStore) GetEndpoint(id uuid.UUID) (*types.Endpoint, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -38,6 +75,54 @@ func (s *MemoryStore) GetEndpoint(id uuid.UUID) (*types.Endpoint, error) {
 	return e, nil
 }
 
+func (s *MemoryStore) GetEndpoints() ([]types.Endpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	endpoints := make([]types.Endpoint, 0, len(s.endpoints))
+	for _, endpoint := range s.endpoints {
+		endpoints = append(endpoints, *endpoint)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].CreatedAT.Before(endpoints[j].CreatedAT)
+	})
+	return endpoints, nil
+}
+
+func (s *MemoryStore) DeleteEndpoint(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.endpoints[id]; !ok {
+		return fmt.Errorf("could not find endpoint with id (%s)", id)
+	}
+	delete(s.endpoints, id)
+	for deployID, deploy := range s.deploys {
+		if deploy.EndpointID == id {
+			delete(s.deploys, deployID)
+		}
+	}
+	for hostname, domain := range s.domains {
+		if domain.EndpointID == id {
+			delete(s.domains, hostname)
+			delete(s.certs, hostname)
+		}
+	}
+	for scheduleID, schedule := range s.schedules {
+		if schedule.EndpointID == id {
+			delete(s.schedules, scheduleID)
+			delete(s.runs, scheduleID)
+		}
+	}
+	for invocationID, invocation := range s.invokes {
+		if invocation.EndpointID == id {
+			delete(s.invokes, invocationID)
+		}
+	}
+	delete(s.kv, id)
+	delete(s.metrics, id)
+	delete(s.logs, id)
+	return nil
+}
+
 func (s *MemoryStore) UpdateEndpoint(id uuid.UUID, params UpdateEndpointParams) error {
 	endpoint, err := s.GetEndpoint(id)
 	if err != nil {
@@ -45,14 +130,42 @@ func (s *MemoryStore) UpdateEndpoint(id uuid.UUID, params UpdateEndpointParams)
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if params.Version != 0 && params.Version != endpoint.Version {
+		return ErrVersionConflict
+	}
 	if params.ActiveDeployID.String() != "00000000-0000-0000-0000-000000000000" {
 		endpoint.ActiveDeploymentID = params.ActiveDeployID
 	}
+	if params.ReplaceEnvironment {
+		endpoint.Environment = make(map[string]string, len(params.Environment))
+	}
 	if params.Environment != nil {
 		for key, val := range params.Environment {
 			endpoint.Environment[key] = val
 		}
 	}
+	for _, key := range params.UnsetEnvironment {
+		delete(endpoint.Environment, key)
+	}
+	if params.SnapshotEnvironment != nil {
+		endpoint.SnapshotEnvironment = *params.SnapshotEnvironment
+	}
+	if params.AllowedHosts != nil {
+		endpoint.AllowedHosts = params.AllowedHosts
+	}
+	endpoint.Version++
+	endpoint.CacheGeneration++
+	return nil
+}
+
+func (s *MemoryStore) PurgeEndpointCache(id uuid.UUID) error {
+	endpoint, err := s.GetEndpoint(id)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	endpoint.CacheGeneration++
 	return nil
 }
 
@@ -73,6 +186,306 @@ func (s *MemoryStore) GetDeployment(id uuid.UUID) (*types.Deployment, error) {
 	return deploy, nil
 }
 
+func (s *MemoryStore) CreateDomain(domain *types.Domain) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.domains[domain.Hostname]; ok {
+		return fmt.Errorf("%w: %s", ErrDomainExists, domain.Hostname)
+	}
+	s.domains[domain.Hostname] = domain
+	return nil
+}
+
+func (s *MemoryStore) GetDomain(hostname string) (*types.Domain, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	domain, ok := s.domains[hostname]
+	if !ok {
+		return nil, fmt.Errorf("could not find domain (%s)", hostname)
+	}
+	return domain, nil
+}
+
+func (s *MemoryStore) GetDomains(endpointID uuid.UUID) ([]*types.Domain, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	domains := []*types.Domain{}
+	for _, domain := range s.domains {
+		if domain.EndpointID == endpointID {
+			domains = append(domains, domain)
+		}
+	}
+	return domains, nil
+}
+
+func (s *MemoryStore) VerifyDomain(hostname string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	domain, ok := s.domains[hostname]
+	if !ok {
+		return fmt.Errorf("could not find domain (%s)", hostname)
+	}
+	domain.Verified = true
+	return nil
+}
+
+func (s *MemoryStore) DeleteDomain(hostname string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.domains, hostname)
+	delete(s.certs, hostname)
+	return nil
+}
+
+func (s *MemoryStore) PutCertificate(cert *types.Certificate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.certs[cert.Hostname] = cert
+	return nil
+}
+
+func (s *MemoryStore) GetCertificate(hostname string) (*types.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cert, ok := s.certs[hostname]
+	if !ok {
+		return nil, fmt.Errorf("could not find certificate for (%s)", hostname)
+	}
+	return cert, nil
+}
+
+func (s *MemoryStore) PutKV(entry *types.KVEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.endpoints[entry.EndpointID]; !ok {
+		return fmt.Errorf("could not find endpoint with id (%s)", entry.EndpointID)
+	}
+	entries, ok := s.kv[entry.EndpointID]
+	if !ok {
+		entries = make(map[string]*types.KVEntry)
+		s.kv[entry.EndpointID] = entries
+	}
+	entries[entry.Key] = entry
+	return nil
+}
+
+func (s *MemoryStore) GetKV(endpointID uuid.UUID, key string) (*types.KVEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, ok := s.kv[endpointID][key]
+	if !ok || entry.Expired(time.Now()) {
+		return nil, ErrKeyNotFound
+	}
+	return entry, nil
+}
+
+func (s *MemoryStore) DeleteKV(endpointID uuid.UUID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.kv[endpointID], key)
+	return nil
+}
+
+func (s *MemoryStore) ListKV(endpointID uuid.UUID, prefix string, limit int) ([]*types.KVEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if limit <= 0 {
+		limit = DefaultKVListLimit
+	}
+	now := time.Now()
+	entries := []*types.KVEntry{}
+	for key, entry := range s.kv[endpointID] {
+		if entry.Expired(now) {
+			delete(s.kv[endpointID], key)
+			continue
+		}
+		if strings.HasPrefix(key, prefix) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+func (s *MemoryStore) CreateSchedule(schedule *types.Schedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.endpoints[schedule.EndpointID]; !ok {
+		return fmt.Errorf("could not find endpoint with id (%s)", schedule.EndpointID)
+	}
+	s.schedules[schedule.ID] = schedule
+	return nil
+}
+
+func (s *MemoryStore) GetSchedule(id uuid.UUID) (*types.Schedule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	schedule, ok := s.schedules[id]
+	if !ok {
+		return nil, fmt.Errorf("could not find schedule with id (%s)", id)
+	}
+	return schedule, nil
+}
+
+func (s *MemoryStore) GetSchedules(endpointID uuid.UUID) ([]*types.Schedule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	schedules := []*types.Schedule{}
+	for _, schedule := range s.schedules {
+		if schedule.EndpointID == endpointID {
+			schedules = append(schedules, schedule)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].CreatedAT.Before(schedules[j].CreatedAT)
+	})
+	return schedules, nil
+}
+
+func (s *MemoryStore) DeleteSchedule(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.schedules, id)
+	delete(s.runs, id)
+	return nil
+}
+
+func (s *MemoryStore) GetDueSchedules(now time.Time) ([]*types.Schedule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	schedules := []*types.Schedule{}
+	for _, schedule := range s.schedules {
+		if !schedule.NextRunAT.After(now) {
+			due := *schedule
+			schedules = append(schedules, &due)
+		}
+	}
+	return schedules, nil
+}
+
+func (s *MemoryStore) ClaimSchedule(id uuid.UUID, runAT, nextRunAT time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schedule, ok := s.schedules[id]
+	if !ok || !schedule.NextRunAT.Equal(runAT) {
+		return false, nil
+	}
+	schedule.NextRunAT = nextRunAT
+	return true, nil
+}
+
+func (s *MemoryStore) CreateScheduleRun(run *types.ScheduleRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schedule, ok := s.schedules[run.ScheduleID]
+	if !ok {
+		return fmt.Errorf("could not find schedule with id (%s)", run.ScheduleID)
+	}
+	startedAT := run.StartedAT
+	schedule.LastRunAT = &startedAT
+	schedule.LastStatus = run.StatusCode
+	runs := append([]*types.ScheduleRun{run}, s.runs[run.ScheduleID]...)
+	if len(runs) > MaxScheduleRuns {
+		runs = runs[:MaxScheduleRuns]
+	}
+	s.runs[run.ScheduleID] = runs
+	return nil
+}
+
+func (s *MemoryStore) GetScheduleRuns(scheduleID uuid.UUID) ([]*types.ScheduleRun, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	runs := make([]*types.ScheduleRun, len(s.runs[scheduleID]))
+	copy(runs, s.runs[scheduleID])
+	return runs, nil
+}
+
+func (s *MemoryStore) CreateInvocation(invocation *types.Invocation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := *invocation
+	s.invokes[invocation.ID] = &stored
+	return nil
+}
+
+func (s *MemoryStore) GetInvocation(id uuid.UUID) (*types.Invocation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	invocation, ok := s.invokes[id]
+	if !ok {
+		return nil, fmt.Errorf("could not find invocation with id (%s)", id)
+	}
+	res := *invocation
+	return &res, nil
+}
+
+func (s *MemoryStore) GetInvocations(endpointID uuid.UUID, status string) ([]*types.Invocation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	invocations := []*types.Invocation{}
+	for _, invocation := range s.invokes {
+		if invocation.EndpointID != endpointID {
+			continue
+		}
+		if len(status) > 0 && invocation.Status != status {
+			continue
+		}
+		res := *invocation
+		invocations = append(invocations, &res)
+	}
+	sort.Slice(invocations, func(i, j int) bool {
+		return invocations[i].CreatedAT.After(invocations[j].CreatedAT)
+	})
+	if len(invocations) > MaxInvocations {
+		invocations = invocations[:MaxInvocations]
+	}
+	return invocations, nil
+}
+
+func (s *MemoryStore) ClaimInvocations(now time.Time, lease time.Duration, limit int) ([]*types.Invocation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	due := []*types.Invocation{}
+	for _, invocation := range s.invokes {
+		if invocation.Done() || invocation.NextAttemptAT.After(now) {
+			continue
+		}
+		due = append(due, invocation)
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttemptAT.Before(due[j].NextAttemptAT)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	claimed := make([]*types.Invocation, len(due))
+	for i, invocation := range due {
+		invocation.Status = types.InvocationRunning
+		invocation.Attempts++
+		invocation.NextAttemptAT = now.Add(lease)
+		invocation.UpdatedAT = now
+		res := *invocation
+		claimed[i] = &res
+	}
+	return claimed, nil
+}
+
+func (s *MemoryStore) UpdateInvocation(invocation *types.Invocation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.invokes[invocation.ID]; !ok {
+		return fmt.Errorf("could not find invocation with id (%s)", invocation.ID)
+	}
+	stored := *invocation
+	s.invokes[invocation.ID] = &stored
+	return nil
+}
+
 func (s *MemoryStore) CreateRuntimeMetric(_ *types.RuntimeMetric) error {
 	return nil
 }
@@ -80,3 +493,45 @@ func (s *MemoryStore) CreateRuntimeMetric(_ *types.RuntimeMetric) error {
 func (s *MemoryStore) GetRuntimeMetrics(_ uuid.UUID) ([]types.RuntimeMetric, error) {
 	return nil, nil
 }
+
+func (s *MemoryStore) CreateRequestMetric(metric *types.RequestMetric) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	metrics := append([]types.RequestMetric{*metric}, s.metrics[metric.EndpointID]...)
+	if len(metrics) > MaxRequestMetrics {
+		metrics = metrics[:MaxRequestMetrics]
+	}
+	s.metrics[metric.EndpointID] = metrics
+	return nil
+}
+
+func (s *MemoryStore) GetRequestMetrics(endpointID uuid.UUID) ([]types.RequestMetric, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	metrics := make([]types.RequestMetric, len(s.metrics[endpointID]))
+	copy(metrics, s.metrics[endpointID])
+	return metrics, nil
+}
+
+func (s *MemoryStore) CreateRuntimeLog(log *types.RuntimeLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	logs := append([]*types.RuntimeLog{log}, s.logs[log.EndpointID]...)
+	if len(logs) > MaxRuntimeLogs {
+		logs = logs[:MaxRuntimeLogs]
+	}
+	s.logs[log.EndpointID] = logs
+	return nil
+}
+
+func (s *MemoryStore) GetRuntimeLogs(endpointID uuid.UUID, limit int) ([]*types.RuntimeLog, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	logs := s.logs[endpointID]
+	if limit > 0 && len(logs) > limit {
+		logs = logs[:limit]
+	}
+	result := make([]*types.RuntimeLog, len(logs))
+	copy(result, logs)
+	return result, nil
+}
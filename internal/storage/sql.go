@@ -3,12 +3,14 @@ package storage
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/anthdm/raptor/internal/types"
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type SQLStore struct {
@@ -41,8 +43,8 @@ func NewSQLStore(user, password, dbname, host, port, sslmode string) (*SQLStore,
 
 func (s *SQLStore) CreateEndpoint(endpoint *types.Endpoint) error {
 	stmt := `
-INSERT INTO endpoint (id, name, runtime, environment, created_at)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO endpoint (id, name, slug, runtime, environment, snapshot_environment, allowed_hosts, version, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 RETURNING id`
 	b, err := json.Marshal(endpoint.Environment)
 	if err != nil {
@@ -51,8 +53,12 @@ RETURNING id`
 	_, err = s.db.Exec(stmt,
 		endpoint.ID,
 		endpoint.Name,
+		sql.NullString{String: endpoint.Slug, Valid: len(endpoint.Slug) > 0},
 		endpoint.Runtime,
 		b,
+		endpoint.SnapshotEnvironment,
+		pq.Array(endpoint.AllowedHosts),
+		endpoint.Version,
 		endpoint.CreatedAT)
 	return err
 }
@@ -64,14 +70,21 @@ func (s *SQLStore) GetEndpoint(id uuid.UUID) (*types.Endpoint, error) {
 	return &endpoint, err
 }
 
+func (s *SQLStore) GetEndpointBySlug(slug string) (*types.Endpoint, error) {
+	row := s.db.QueryRow("SELECT * FROM endpoint WHERE slug = $1", slug)
+	var endpoint types.Endpoint
+	err := scanEndpoint(row, &endpoint)
+	return &endpoint, err
+}
+
 func (s *SQLStore) GetEndpoints() ([]types.Endpoint, error) {
-	rows, err := s.db.Query("SELECT * FROM endpoint")
+	rows, err := s.db.Query("SELECT * FROM endpoint ORDER BY created_at")
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var endpoints []types.Endpoint
+	endpoints := []types.Endpoint{}
 	for rows.Next() {
 		var endpoint types.Endpoint
 		if err := scanEndpoint(rows, &endpoint); err != nil {
@@ -79,17 +92,75 @@ func (s *SQLStore) GetEndpoints() ([]types.Endpoint, error) {
 		}
 		endpoints = append(endpoints, endpoint)
 	}
-	return endpoints, nil
+	return endpoints, rows.Err()
+}
+
+func (s *SQLStore) DeleteEndpoint(id uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// The endpoint and its active deployment reference each other, hence the
+	// reference is removed before the deployments are deleted.
+	res, err := tx.Exec("UPDATE endpoint SET active_deployment_id = NULL WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("could not find endpoint with id (%s)", id)
+	}
+	if _, err := tx.Exec("DELETE FROM deployment WHERE endpoint_id = $1", id); err != nil {
+		return err
+	}
+	// Domains, key-value entries, schedules, invocations, metrics and logs
+	// are deleted by their foreign keys.
+	if _, err := tx.Exec("DELETE FROM endpoint WHERE id = $1", id); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (s *SQLStore) UpdateEndpoint(id uuid.UUID, params UpdateEndpointParams) error {
 	query, args := buildUpdateEndpointQuery(id, params)
-	_, err := s.db.Exec(query, args...)
-	return err
+	res, err := s.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		if params.Version != 0 {
+			return ErrVersionConflict
+		}
+		return fmt.Errorf("could not find endpoint with id (%s)", id)
+	}
+	return nil
+}
+
+func (s *SQLStore) PurgeEndpointCache(id uuid.UUID) error {
+	res, err := s.db.Exec("UPDATE endpoint SET cache_generation = cache_generation + 1 WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("could not find endpoint with id (%s)", id)
+	}
+	return nil
 }
 
 func (s *SQLStore) GetDeployment(id uuid.UUID) (*types.Deployment, error) {
-	stmt := "SELECT id, endpoint_id, hash, blob, created_at FROM deployment WHERE id = $1"
+	stmt := "SELECT id, endpoint_id, hash, blob, environment, assets, created_at FROM deployment WHERE id = $1"
 	row := s.db.QueryRow(stmt, id)
 
 	var deploy types.Deployment
@@ -99,18 +170,404 @@ func (s *SQLStore) GetDeployment(id uuid.UUID) (*types.Deployment, error) {
 
 func (s *SQLStore) CreateDeployment(deploy *types.Deployment) error {
 	stmt := `
-INSERT INTO deployment (id, endpoint_id, hash, blob, created_at)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO deployment (id, endpoint_id, hash, blob, environment, assets, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
 RETURNING id`
+	var env []byte
+	if deploy.Environment != nil {
+		b, err := json.Marshal(deploy.Environment)
+		if err != nil {
+			return err
+		}
+		env = b
+	}
 	_, err := s.db.Exec(stmt,
 		deploy.ID,
 		deploy.EndpointID,
 		deploy.Hash,
 		deploy.Blob,
+		env,
+		deploy.Assets,
 		deploy.CreatedAT)
 	return err
 }
 
+func (s *SQLStore) CreateDomain(domain *types.Domain) error {
+	stmt := `
+INSERT INTO domain (hostname, endpoint_id, verification_token, verified, created_at)
+VALUES ($1, $2, $3, $4, $5)`
+	_, err := s.db.Exec(stmt,
+		domain.Hostname,
+		domain.EndpointID,
+		domain.VerificationToken,
+		domain.Verified,
+		domain.CreatedAT)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return fmt.Errorf("%w: %s", ErrDomainExists, domain.Hostname)
+	}
+	return err
+}
+
+func (s *SQLStore) GetDomain(hostname string) (*types.Domain, error) {
+	stmt := "SELECT hostname, endpoint_id, verification_token, verified, created_at FROM domain WHERE hostname = $1"
+	row := s.db.QueryRow(stmt, hostname)
+	var domain types.Domain
+	err := scanDomain(row, &domain)
+	return &domain, err
+}
+
+func (s *SQLStore) GetDomains(endpointID uuid.UUID) ([]*types.Domain, error) {
+	stmt := "SELECT hostname, endpoint_id, verification_token, verified, created_at FROM domain WHERE endpoint_id = $1"
+	rows, err := s.db.Query(stmt, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	domains := []*types.Domain{}
+	for rows.Next() {
+		var domain types.Domain
+		if err := scanDomain(rows, &domain); err != nil {
+			return nil, err
+		}
+		domains = append(domains, &domain)
+	}
+	return domains, rows.Err()
+}
+
+func (s *SQLStore) VerifyDomain(hostname string) error {
+	_, err := s.db.Exec("UPDATE domain SET verified = true WHERE hostname = $1", hostname)
+	return err
+}
+
+func (s *SQLStore) DeleteDomain(hostname string) error {
+	_, err := s.db.Exec("DELETE FROM domain WHERE hostname = $1", hostname)
+	return err
+}
+
+func (s *SQLStore) PutCertificate(cert *types.Certificate) error {
+	stmt := `
+INSERT INTO certificate (hostname, cert_pem, key_pem, expires_at, updated_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (hostname) DO UPDATE
+SET cert_pem = $2, key_pem = $3, expires_at = $4, updated_at = $5`
+	_, err := s.db.Exec(stmt,
+		cert.Hostname,
+		cert.CertPEM,
+		cert.KeyPEM,
+		cert.ExpiresAT,
+		cert.UpdatedAT)
+	return err
+}
+
+func (s *SQLStore) GetCertificate(hostname string) (*types.Certificate, error) {
+	stmt := "SELECT hostname, cert_pem, key_pem, expires_at, updated_at FROM certificate WHERE hostname = $1"
+	row := s.db.QueryRow(stmt, hostname)
+	var cert types.Certificate
+	err := row.Scan(
+		&cert.Hostname,
+		&cert.CertPEM,
+		&cert.KeyPEM,
+		&cert.ExpiresAT,
+		&cert.UpdatedAT,
+	)
+	return &cert, err
+}
+
+func (s *SQLStore) PutKV(entry *types.KVEntry) error {
+	stmt := `
+INSERT INTO kv (endpoint_id, key, value, expires_at, updated_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (endpoint_id, key) DO UPDATE
+SET value = $3, expires_at = $4, updated_at = $5`
+	_, err := s.db.Exec(stmt,
+		entry.EndpointID,
+		entry.Key,
+		entry.Value,
+		entry.ExpiresAT,
+		entry.UpdatedAT)
+	return err
+}
+
+func (s *SQLStore) GetKV(endpointID uuid.UUID, key string) (*types.KVEntry, error) {
+	stmt := `
+SELECT endpoint_id, key, value, expires_at, updated_at FROM kv
+WHERE endpoint_id = $1 AND key = $2 AND (expires_at IS NULL OR expires_at > now())`
+	row := s.db.QueryRow(stmt, endpointID, key)
+	var entry types.KVEntry
+	if err := scanKV(row, &entry); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrKeyNotFound
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (s *SQLStore) DeleteKV(endpointID uuid.UUID, key string) error {
+	_, err := s.db.Exec("DELETE FROM kv WHERE endpoint_id = $1 AND key = $2", endpointID, key)
+	return err
+}
+
+func (s *SQLStore) ListKV(endpointID uuid.UUID, prefix string, limit int) ([]*types.KVEntry, error) {
+	if limit <= 0 {
+		limit = DefaultKVListLimit
+	}
+	// Expired entries of the endpoint are removed on the way.
+	if _, err := s.db.Exec("DELETE FROM kv WHERE endpoint_id = $1 AND expires_at <= now()", endpointID); err != nil {
+		return nil, err
+	}
+	stmt := `
+SELECT endpoint_id, key, value, expires_at, updated_at FROM kv
+WHERE endpoint_id = $1 AND left(key, length($2)) = $2
+ORDER BY key LIMIT $3`
+	rows, err := s.db.Query(stmt, endpointID, prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []*types.KVEntry{}
+	for rows.Next() {
+		var entry types.KVEntry
+		if err := scanKV(rows, &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, rows.Err()
+}
+
+const scheduleColumns = "id, endpoint_id, cron, method, path, next_run_at, last_run_at, last_status, created_at"
+
+func (s *SQLStore) CreateSchedule(schedule *types.Schedule) error {
+	stmt := `
+INSERT INTO schedule (id, endpoint_id, cron, method, path, next_run_at, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := s.db.Exec(stmt,
+		schedule.ID,
+		schedule.EndpointID,
+		schedule.Cron,
+		schedule.Method,
+		schedule.Path,
+		schedule.NextRunAT,
+		schedule.CreatedAT)
+	return err
+}
+
+func (s *SQLStore) GetSchedule(id uuid.UUID) (*types.Schedule, error) {
+	row := s.db.QueryRow("SELECT "+scheduleColumns+" FROM schedule WHERE id = $1", id)
+	var schedule types.Schedule
+	err := scanSchedule(row, &schedule)
+	return &schedule, err
+}
+
+func (s *SQLStore) GetSchedules(endpointID uuid.UUID) ([]*types.Schedule, error) {
+	return s.querySchedules("SELECT "+scheduleColumns+" FROM schedule WHERE endpoint_id = $1 ORDER BY created_at", endpointID)
+}
+
+func (s *SQLStore) DeleteSchedule(id uuid.UUID) error {
+	_, err := s.db.Exec("DELETE FROM schedule WHERE id = $1", id)
+	return err
+}
+
+func (s *SQLStore) GetDueSchedules(now time.Time) ([]*types.Schedule, error) {
+	return s.querySchedules("SELECT "+scheduleColumns+" FROM schedule WHERE next_run_at <= $1", now)
+}
+
+func (s *SQLStore) querySchedules(query string, args ...any) ([]*types.Schedule, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	schedules := []*types.Schedule{}
+	for rows.Next() {
+		var schedule types.Schedule
+		if err := scanSchedule(rows, &schedule); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, &schedule)
+	}
+	return schedules, rows.Err()
+}
+
+func (s *SQLStore) ClaimSchedule(id uuid.UUID, runAT, nextRunAT time.Time) (bool, error) {
+	res, err := s.db.Exec("UPDATE schedule SET next_run_at = $1 WHERE id = $2 AND next_run_at = $3", nextRunAT, id, runAT)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (s *SQLStore) CreateScheduleRun(run *types.ScheduleRun) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt := `
+INSERT INTO schedule_run (id, schedule_id, deployment_id, status_code, duration, logs, error, started_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err = tx.Exec(stmt,
+		run.ID,
+		run.ScheduleID,
+		run.DeploymentID,
+		run.StatusCode,
+		int64(run.Duration),
+		run.Logs,
+		run.Error,
+		run.StartedAT)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE schedule SET last_run_at = $1, last_status = $2 WHERE id = $3", run.StartedAT, run.StatusCode, run.ScheduleID)
+	if err != nil {
+		return err
+	}
+	// Only the latest runs are kept.
+	stmt = `
+DELETE FROM schedule_run WHERE schedule_id = $1 AND id NOT IN (
+	SELECT id FROM schedule_run WHERE schedule_id = $1 ORDER BY started_at DESC LIMIT $2
+)`
+	if _, err := tx.Exec(stmt, run.ScheduleID, MaxScheduleRuns); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) GetScheduleRuns(scheduleID uuid.UUID) ([]*types.ScheduleRun, error) {
+	stmt := `
+SELECT id, schedule_id, deployment_id, status_code, duration, logs, error, started_at
+FROM schedule_run WHERE schedule_id = $1 ORDER BY started_at DESC`
+	rows, err := s.db.Query(stmt, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	runs := []*types.ScheduleRun{}
+	for rows.Next() {
+		var (
+			run      types.ScheduleRun
+			duration int64
+		)
+		err := rows.Scan(
+			&run.ID,
+			&run.ScheduleID,
+			&run.DeploymentID,
+			&run.StatusCode,
+			&duration,
+			&run.Logs,
+			&run.Error,
+			&run.StartedAT,
+		)
+		if err != nil {
+			return nil, err
+		}
+		run.Duration = time.Duration(duration)
+		runs = append(runs, &run)
+	}
+	return runs, rows.Err()
+}
+
+const invocationColumns = `id, endpoint_id, deployment_id, method, url, header, body, status, attempts,
+max_attempts, backoff, next_attempt_at, status_code, response, error, callback_url, created_at, updated_at, token_hash`
+
+func (s *SQLStore) CreateInvocation(invocation *types.Invocation) error {
+	header, err := json.Marshal(invocation.Header)
+	if err != nil {
+		return err
+	}
+	stmt := `
+INSERT INTO invocation (` + invocationColumns + `)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)`
+	_, err = s.db.Exec(stmt,
+		invocation.ID,
+		invocation.EndpointID,
+		invocation.DeploymentID,
+		invocation.Method,
+		invocation.URL,
+		header,
+		invocation.Body,
+		invocation.Status,
+		invocation.Attempts,
+		invocation.MaxAttempts,
+		int64(invocation.Backoff),
+		invocation.NextAttemptAT,
+		invocation.StatusCode,
+		invocation.Response,
+		invocation.Error,
+		invocation.CallbackURL,
+		invocation.CreatedAT,
+		invocation.UpdatedAT,
+		invocation.TokenHash)
+	return err
+}
+
+func (s *SQLStore) GetInvocation(id uuid.UUID) (*types.Invocation, error) {
+	row := s.db.QueryRow("SELECT "+invocationColumns+" FROM invocation WHERE id = $1", id)
+	var invocation types.Invocation
+	err := scanInvocation(row, &invocation)
+	return &invocation, err
+}
+
+func (s *SQLStore) GetInvocations(endpointID uuid.UUID, status string) ([]*types.Invocation, error) {
+	stmt := `
+SELECT ` + invocationColumns + ` FROM invocation
+WHERE endpoint_id = $1 AND ($2 = '' OR status = $2)
+ORDER BY created_at DESC LIMIT $3`
+	return s.queryInvocations(stmt, endpointID, status, MaxInvocations)
+}
+
+func (s *SQLStore) ClaimInvocations(now time.Time, lease time.Duration, limit int) ([]*types.Invocation, error) {
+	stmt := `
+UPDATE invocation
+SET status = 'running', attempts = attempts + 1, next_attempt_at = $2, updated_at = $1
+WHERE id IN (
+	SELECT id FROM invocation
+	WHERE status IN ('queued', 'running') AND next_attempt_at <= $1
+	ORDER BY next_attempt_at LIMIT $3
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING ` + invocationColumns
+	return s.queryInvocations(stmt, now, now.Add(lease), limit)
+}
+
+func (s *SQLStore) UpdateInvocation(invocation *types.Invocation) error {
+	stmt := `
+UPDATE invocation
+SET status = $1, attempts = $2, next_attempt_at = $3, status_code = $4, response = $5, error = $6, updated_at = $7
+WHERE id = $8`
+	_, err := s.db.Exec(stmt,
+		invocation.Status,
+		invocation.Attempts,
+		invocation.NextAttemptAT,
+		invocation.StatusCode,
+		invocation.Response,
+		invocation.Error,
+		invocation.UpdatedAT,
+		invocation.ID)
+	return err
+}
+
+func (s *SQLStore) queryInvocations(query string, args ...any) ([]*types.Invocation, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	invocations := []*types.Invocation{}
+	for rows.Next() {
+		var invocation types.Invocation
+		if err := scanInvocation(rows, &invocation); err != nil {
+			return nil, err
+		}
+		invocations = append(invocations, &invocation)
+	}
+	return invocations, rows.Err()
+}
+
 func (s *SQLStore) CreateRuntimeMetric(metric *types.RuntimeMetric) error {
 	return nil
 }
@@ -119,6 +576,129 @@ func (s *SQLStore) GetRuntimeMetrics(id uuid.UUID) ([]types.RuntimeMetric, error
 	return nil, nil
 }
 
+func (s *SQLStore) CreateRequestMetric(metric *types.RequestMetric) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt := `
+INSERT INTO request_metric (id, endpoint_id, deployment_id, request_url, duration, status_code, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err = tx.Exec(stmt,
+		metric.ID,
+		metric.EndpointID,
+		metric.DeploymentID,
+		metric.RequestURL,
+		int64(metric.Duration),
+		metric.StatusCode,
+		metric.CreatedAT)
+	if err != nil {
+		return err
+	}
+	// Only the latest metrics are kept.
+	stmt = `
+DELETE FROM request_metric WHERE endpoint_id = $1 AND id NOT IN (
+	SELECT id FROM request_metric WHERE endpoint_id = $1 ORDER BY created_at DESC LIMIT $2
+)`
+	if _, err := tx.Exec(stmt, metric.EndpointID, MaxRequestMetrics); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) GetRequestMetrics(endpointID uuid.UUID) ([]types.RequestMetric, error) {
+	stmt := `
+SELECT id, endpoint_id, deployment_id, request_url, duration, status_code, created_at
+FROM request_metric WHERE endpoint_id = $1 ORDER BY created_at DESC`
+	rows, err := s.db.Query(stmt, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	metrics := []types.RequestMetric{}
+	for rows.Next() {
+		var (
+			metric   types.RequestMetric
+			duration int64
+		)
+		err := rows.Scan(
+			&metric.ID,
+			&metric.EndpointID,
+			&metric.DeploymentID,
+			&metric.RequestURL,
+			&duration,
+			&metric.StatusCode,
+			&metric.CreatedAT)
+		if err != nil {
+			return nil, err
+		}
+		metric.Duration = time.Duration(duration)
+		metrics = append(metrics, metric)
+	}
+	return metrics, rows.Err()
+}
+
+func (s *SQLStore) CreateRuntimeLog(log *types.RuntimeLog) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt := `
+INSERT INTO runtime_log (id, endpoint_id, deployment_id, request_id, data, created_at)
+VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err = tx.Exec(stmt,
+		log.ID,
+		log.EndpointID,
+		log.DeploymentID,
+		log.RequestID,
+		log.Data,
+		log.CreatedAT)
+	if err != nil {
+		return err
+	}
+	// Only the latest logs are kept.
+	stmt = `
+DELETE FROM runtime_log WHERE endpoint_id = $1 AND id NOT IN (
+	SELECT id FROM runtime_log WHERE endpoint_id = $1 ORDER BY created_at DESC LIMIT $2
+)`
+	if _, err := tx.Exec(stmt, log.EndpointID, MaxRuntimeLogs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) GetRuntimeLogs(endpointID uuid.UUID, limit int) ([]*types.RuntimeLog, error) {
+	if limit <= 0 || limit > MaxRuntimeLogs {
+		limit = MaxRuntimeLogs
+	}
+	stmt := `
+SELECT id, endpoint_id, deployment_id, request_id, data, created_at
+FROM runtime_log WHERE endpoint_id = $1 ORDER BY created_at DESC LIMIT $2`
+	rows, err := s.db.Query(stmt, endpointID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	logs := []*types.RuntimeLog{}
+	for rows.Next() {
+		var log types.RuntimeLog
+		err := rows.Scan(
+			&log.ID,
+			&log.EndpointID,
+			&log.DeploymentID,
+			&log.RequestID,
+			&log.Data,
+			&log.CreatedAT)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, &log)
+	}
+	return logs, rows.Err()
+}
+
 type Scanner interface {
 	Scan(dest ...interface{}) error
 }
@@ -135,35 +715,167 @@ func buildUpdateEndpointQuery(id uuid.UUID, params UpdateEndpointParams) (string
 		args = append(args, params.ActiveDeployID)
 		counter++
 	}
-	if params.Environment != nil {
-		b, err := json.Marshal(params.Environment)
+	if params.ReplaceEnvironment {
+		env := params.Environment
+		if env == nil {
+			env = map[string]string{}
+		}
+		b, err := json.Marshal(env)
 		if err != nil {
 			panic(err)
 		}
 		updates = append(updates, fmt.Sprintf("environment = $%d", counter))
 		args = append(args, b)
 		counter++
+	} else if params.Environment != nil || len(params.UnsetEnvironment) > 0 {
+		// Merge and unset are done by postgres so concurrent updates of
+		// different keys do not overwrite each other.
+		expr := "COALESCE(environment, '{}'::jsonb)"
+		if params.Environment != nil {
+			b, err := json.Marshal(params.Environment)
+			if err != nil {
+				panic(err)
+			}
+			expr = fmt.Sprintf("%s || $%d::jsonb", expr, counter)
+			args = append(args, b)
+			counter++
+		}
+		if len(params.UnsetEnvironment) > 0 {
+			expr = fmt.Sprintf("(%s) - $%d::text[]", expr, counter)
+			args = append(args, pq.Array(params.UnsetEnvironment))
+			counter++
+		}
+		updates = append(updates, "environment = "+expr)
+	}
+	if params.SnapshotEnvironment != nil {
+		updates = append(updates, fmt.Sprintf("snapshot_environment = $%d", counter))
+		args = append(args, *params.SnapshotEnvironment)
+		counter++
 	}
+	if params.AllowedHosts != nil {
+		updates = append(updates, fmt.Sprintf("allowed_hosts = $%d", counter))
+		args = append(args, pq.Array(params.AllowedHosts))
+		counter++
+	}
+	updates = append(updates, "version = version + 1", "cache_generation = cache_generation + 1")
 	args = append(args, id)
 
 	setClause := strings.Join(updates, ", ")
 	query := fmt.Sprintf("UPDATE endpoint SET %s WHERE id = $%d", setClause, counter)
+	if params.Version != 0 {
+		counter++
+		query = fmt.Sprintf("%s AND version = $%d", query, counter)
+		args = append(args, params.Version)
+	}
 
 	return query, args
 }
 
 func scanDeploy(s Scanner, d *types.Deployment) error {
-	return s.Scan(
+	var envData []byte
+	err := s.Scan(
 		&d.ID,
 		&d.EndpointID,
 		&d.Hash,
 		&d.Blob,
+		&envData,
+		&d.Assets,
 		&d.CreatedAT,
 	)
+	if err != nil || envData == nil {
+		return err
+	}
+	return json.Unmarshal(envData, &d.Environment)
+}
+
+func scanDomain(s Scanner, d *types.Domain) error {
+	return s.Scan(
+		&d.Hostname,
+		&d.EndpointID,
+		&d.VerificationToken,
+		&d.Verified,
+		&d.CreatedAT,
+	)
+}
+
+func scanKV(s Scanner, e *types.KVEntry) error {
+	var expires sql.NullTime
+	err := s.Scan(
+		&e.EndpointID,
+		&e.Key,
+		&e.Value,
+		&expires,
+		&e.UpdatedAT,
+	)
+	if err != nil {
+		return err
+	}
+	if expires.Valid {
+		e.ExpiresAT = &expires.Time
+	}
+	return nil
+}
+
+func scanSchedule(s Scanner, schedule *types.Schedule) error {
+	var lastRun sql.NullTime
+	err := s.Scan(
+		&schedule.ID,
+		&schedule.EndpointID,
+		&schedule.Cron,
+		&schedule.Method,
+		&schedule.Path,
+		&schedule.NextRunAT,
+		&lastRun,
+		&schedule.LastStatus,
+		&schedule.CreatedAT,
+	)
+	if err != nil {
+		return err
+	}
+	if lastRun.Valid {
+		schedule.LastRunAT = &lastRun.Time
+	}
+	return nil
+}
+
+func scanInvocation(s Scanner, i *types.Invocation) error {
+	var (
+		header  []byte
+		backoff int64
+	)
+	err := s.Scan(
+		&i.ID,
+		&i.EndpointID,
+		&i.DeploymentID,
+		&i.Method,
+		&i.URL,
+		&header,
+		&i.Body,
+		&i.Status,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&backoff,
+		&i.NextAttemptAT,
+		&i.StatusCode,
+		&i.Response,
+		&i.Error,
+		&i.CallbackURL,
+		&i.CreatedAT,
+		&i.UpdatedAT,
+		&i.TokenHash,
+	)
+	if err != nil {
+		return err
+	}
+	i.Backoff = time.Duration(backoff)
+	return json.Unmarshal(header, &i.Header)
 }
 
 func scanEndpoint(s Scanner, e *types.Endpoint) error {
-	var envData []byte
+	var (
+		envData []byte
+		slug    sql.NullString
+	)
 	err := s.Scan(
 		&e.ID,
 		&e.Name,
@@ -171,10 +883,19 @@ func scanEndpoint(s Scanner, e *types.Endpoint) error {
 		&envData,
 		&e.CreatedAT,
 		&e.ActiveDeploymentID,
+		&e.Version,
+		&e.SnapshotEnvironment,
+		&slug,
+		pq.Array(&e.AllowedHosts),
+		&e.CacheGeneration,
 	)
 	if err != nil {
 		return err
 	}
+	e.Slug = slug.String
+	if e.AllowedHosts == nil {
+		e.AllowedHosts = []string{}
+	}
 	return json.Unmarshal(envData, &e.Environment)
 }
 
@@ -196,5 +917,125 @@ CREATE TABLE if not exists deployment (
 );
 
 ALTER table endpoint
-ADD COLUMN if not exists active_deployment_id UUID references deployment
+ADD COLUMN if not exists active_deployment_id UUID references deployment;
+
+ALTER table endpoint
+ADD COLUMN if not exists version integer not null default 1;
+
+ALTER table endpoint
+ADD COLUMN if not exists snapshot_environment boolean not null default false;
+
+ALTER table deployment
+ADD COLUMN if not exists environment jsonb;
+
+ALTER table endpoint
+ADD COLUMN if not exists slug text unique;
+
+ALTER table endpoint
+ADD COLUMN if not exists allowed_hosts text[] not null default '{}';
+
+ALTER table deployment
+ADD COLUMN if not exists assets bytea;
+
+ALTER table endpoint
+ADD COLUMN if not exists cache_generation integer not null default 0;
+
+CREATE TABLE if not exists domain (
+	hostname text primary key,
+	endpoint_id UUID not null references endpoint on delete cascade,
+	verification_token text not null,
+	verified boolean not null default false,
+	created_at timestamp not null default now()
+);
+
+CREATE TABLE if not exists certificate (
+	hostname text primary key references domain on delete cascade,
+	cert_pem bytea not null,
+	key_pem bytea not null,
+	expires_at timestamp not null,
+	updated_at timestamp not null default now()
+);
+
+CREATE TABLE if not exists kv (
+	endpoint_id UUID not null references endpoint on delete cascade,
+	key text not null,
+	value bytea not null,
+	expires_at timestamp,
+	updated_at timestamp not null default now(),
+	primary key (endpoint_id, key)
+);
+
+CREATE TABLE if not exists schedule (
+	id UUID primary key,
+	endpoint_id UUID not null references endpoint on delete cascade,
+	cron text not null,
+	method text not null,
+	path text not null,
+	next_run_at timestamp not null,
+	last_run_at timestamp,
+	last_status integer not null default 0,
+	created_at timestamp not null default now()
+);
+
+CREATE INDEX if not exists schedule_next_run_at ON schedule (next_run_at);
+
+CREATE TABLE if not exists schedule_run (
+	id UUID primary key,
+	schedule_id UUID not null references schedule on delete cascade,
+	deployment_id UUID not null,
+	status_code integer not null,
+	duration bigint not null,
+	logs text not null,
+	error text not null,
+	started_at timestamp not null
+);
+
+CREATE TABLE if not exists invocation (
+	id UUID primary key,
+	endpoint_id UUID not null references endpoint on delete cascade,
+	deployment_id UUID not null,
+	method text not null,
+	url text not null,
+	header jsonb not null,
+	body bytea,
+	status text not null,
+	attempts integer not null,
+	max_attempts integer not null,
+	backoff bigint not null,
+	next_attempt_at timestamp not null,
+	status_code integer not null,
+	response bytea,
+	error text not null,
+	callback_url text not null,
+	created_at timestamp not null,
+	updated_at timestamp not null
+);
+
+ALTER table invocation
+ADD COLUMN if not exists token_hash text not null default '';
+
+CREATE INDEX if not exists invocation_due ON invocation (next_attempt_at) WHERE status IN ('queued', 'running');
+
+CREATE TABLE if not exists request_metric (
+	id UUID primary key,
+	endpoint_id UUID not null references endpoint on delete cascade,
+	deployment_id UUID not null,
+	request_url text not null,
+	duration bigint not null,
+	status_code integer not null,
+	created_at timestamp not null
+);
+
+CREATE INDEX if not exists request_metric_endpoint ON request_metric (endpoint_id, created_at);
+
+CREATE TABLE if not exists runtime_log (
+	id UUID primary key,
+	endpoint_id UUID not null references endpoint on delete cascade,
+	deployment_id UUID not null,
+	request_id text not null,
+	data text not null,
+	created_at timestamp not null
+);
+
+CREATE INDEX if not exists runtime_log_endpoint ON runtime_log (endpoint_id, created_at);
 `
@@ -1,25 +1,127 @@
 package storage
 
 import (
+	"errors"
+	"time"
+
 	"github.com/anthdm/raptor/internal/types"
 	"github.com/google/uuid"
 )
 
+// ErrKeyNotFound is returned when a key does not exist in the key-value store
+// of an endpoint or is expired.
+var ErrKeyNotFound = errors.New("key not found")
+
+// DefaultKVListLimit is the number of entries a list of the key-value store
+// returns when no limit is given.
+const DefaultKVListLimit = 100
+
+// MaxInvocations is the number of invocations that are listed at once.
+const MaxInvocations = 100
+
+// MaxScheduleRuns is the number of runs of a schedule that are kept.
+const MaxScheduleRuns = 100
+
+// MaxRequestMetrics is the number of request metrics that are kept for
+// each endpoint.
+const MaxRequestMetrics = 1000
+
+// MaxRuntimeLogs is the number of runtime logs that are kept for each
+// endpoint.
+const MaxRuntimeLogs = 100
+
+// ErrVersionConflict is returned when an update is made against a version
+// of an endpoint that is not the current one.
+var ErrVersionConflict = errors.New("endpoint was modified by another request")
+
+// ErrDomainExists is returned when a domain is created with a hostname that
+// is already taken.
+var ErrDomainExists = errors.New("domain already exists")
+
 type Store interface {
 	CreateEndpoint(*types.Endpoint) error
+	// UpdateEndpoint updates the endpoint and bumps its version and its
+	// cache generation.
 	UpdateEndpoint(uuid.UUID, UpdateEndpointParams) error
+	// PurgeEndpointCache bumps the cache generation of the endpoint without
+	// bumping its version.
+	PurgeEndpointCache(uuid.UUID) error
 	GetEndpoint(uuid.UUID) (*types.Endpoint, error)
+	GetEndpoints() ([]types.Endpoint, error)
+	// DeleteEndpoint deletes the endpoint together with its deployments,
+	// domains, key-value store, schedules and invocations.
+	DeleteEndpoint(uuid.UUID) error
+	GetEndpointBySlug(string) (*types.Endpoint, error)
 	CreateDeployment(*types.Deployment) error
 	GetDeployment(uuid.UUID) (*types.Deployment, error)
+	CreateDomain(*types.Domain) error
+	GetDomain(hostname string) (*types.Domain, error)
+	GetDomains(endpointID uuid.UUID) ([]*types.Domain, error)
+	VerifyDomain(hostname string) error
+	DeleteDomain(hostname string) error
+	PutCertificate(*types.Certificate) error
+	GetCertificate(hostname string) (*types.Certificate, error)
+	PutKV(*types.KVEntry) error
+	GetKV(endpointID uuid.UUID, key string) (*types.KVEntry, error)
+	DeleteKV(endpointID uuid.UUID, key string) error
+	ListKV(endpointID uuid.UUID, prefix string, limit int) ([]*types.KVEntry, error)
+	CreateSchedule(*types.Schedule) error
+	GetSchedule(uuid.UUID) (*types.Schedule, error)
+	GetSchedules(endpointID uuid.UUID) ([]*types.Schedule, error)
+	DeleteSchedule(uuid.UUID) error
+	// GetDueSchedules returns the schedules that should have run at the given time.
+	GetDueSchedules(now time.Time) ([]*types.Schedule, error)
+	// ClaimSchedule moves the next run of the schedule from runAT to
+	// nextRunAT. It reports false if the run was already claimed, hence
+	// every run is only made by a single scheduler.
+	ClaimSchedule(id uuid.UUID, runAT, nextRunAT time.Time) (bool, error)
+	// CreateScheduleRun stores the run and updates the last run of its schedule.
+	CreateScheduleRun(*types.ScheduleRun) error
+	GetScheduleRuns(scheduleID uuid.UUID) ([]*types.ScheduleRun, error)
+	CreateInvocation(*types.Invocation) error
+	GetInvocation(uuid.UUID) (*types.Invocation, error)
+	// GetInvocations returns the latest invocations of the endpoint with the
+	// given status, or of any status if empty.
+	GetInvocations(endpointID uuid.UUID, status string) ([]*types.Invocation, error)
+	// ClaimInvocations marks up to limit invocations that are due at the
+	// given time as running and counts their attempt. Claimed invocations
+	// are due again after the lease, which retries them when their runner
+	// crashed.
+	ClaimInvocations(now time.Time, lease time.Duration, limit int) ([]*types.Invocation, error)
+	UpdateInvocation(*types.Invocation) error
 }
 
 type MetricStore interface {
 	CreateRuntimeMetric(*types.RuntimeMetric) error
 	GetRuntimeMetrics(uuid.UUID) ([]types.RuntimeMetric, error)
+	CreateRequestMetric(*types.RequestMetric) error
+	// GetRequestMetrics returns the latest request metrics of the endpoint.
+	GetRequestMetrics(endpointID uuid.UUID) ([]types.RequestMetric, error)
+	CreateRuntimeLog(*types.RuntimeLog) error
+	// GetRuntimeLogs returns up to limit of the latest runtime logs of the
+	// endpoint, newest first.
+	GetRuntimeLogs(endpointID uuid.UUID, limit int) ([]*types.RuntimeLog, error)
 }
 
 type UpdateEndpointParams struct {
-	Environment       map[string]string
+	// Environment variables that will be merged into the current environment.
+	Environment map[string]string
+	// UnsetEnvironment holds the keys of the environment variables that
+	// will be removed.
+	UnsetEnvironment []string
+	// ReplaceEnvironment replaces the whole environment with Environment
+	// instead of merging it.
+	ReplaceEnvironment bool
+	// SnapshotEnvironment toggles whether new deployments hold a copy of the
+	// environment. Nil leaves the setting untouched.
+	SnapshotEnvironment *bool
+	// AllowedHosts replaces the hosts the endpoint may send outbound
+	// requests to. Nil leaves the hosts untouched.
+	AllowedHosts      []string
 	ActiveDeployID    uuid.UUID
 	DeploymentHistory *types.DeploymentHistory
+	// Version is the version of the endpoint the update is based on. If not
+	// zero the update will fail with ErrVersionConflict when the endpoint
+	// has been modified in the meantime.
+	Version int
 }
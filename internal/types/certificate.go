@@ -0,0 +1,42 @@
+package types
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"time"
+)
+
+// Certificate is a TLS certificate for a custom domain that is served by the ingress.
+type Certificate struct {
+	Hostname  string    `json:"hostname"`
+	CertPEM   []byte    `json:"-"`
+	KeyPEM    []byte    `json:"-"`
+	ExpiresAT time.Time `json:"expires_at"`
+	UpdatedAT time.Time `json:"updated_at"`
+}
+
+// NewCertificate returns a new certificate for the given hostname. It returns
+// an error if the key pair is invalid or the certificate is not valid for the
+// hostname.
+func NewCertificate(hostname string, certPEM, keyPEM []byte) (*Certificate, error) {
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	hostname = NormalizeHostname(hostname)
+	if err := leaf.VerifyHostname(hostname); err != nil {
+		return nil, fmt.Errorf("certificate is not valid for %s: %s", hostname, err)
+	}
+	return &Certificate{
+		Hostname:  hostname,
+		CertPEM:   certPEM,
+		KeyPEM:    keyPEM,
+		ExpiresAT: leaf.NotAfter,
+		UpdatedAT: time.Now(),
+	}, nil
+}
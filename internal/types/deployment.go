@@ -9,22 +9,59 @@ import (
 )
 
 type Deployment struct {
-	ID         uuid.UUID `json:"id"`
-	EndpointID uuid.UUID `json:"endpoint_id"`
-	Hash       string    `json:"hash"`
-	Blob       []byte    `json:"-"`
-	CreatedAT  time.Time `json:"created_at"`
+	ID          uuid.UUID         `json:"id"`
+	EndpointID  uuid.UUID         `json:"endpoint_id"`
+	Hash        string            `json:"hash"`
+	Blob        []byte            `json:"-"`
+	Environment map[string]string `json:"environment,omitempty"`
+	// Assets is the archive of the static assets that are served together
+	// with the deployment, if any.
+	Assets    []byte    `json:"-"`
+	CreatedAT time.Time `json:"created_at"`
 }
 
+// NewDeployment returns a new deployment of the given blob. If the endpoint
+// snapshots its environment, the deployment will hold a copy of the current
+// environment and will run with that copy instead of the environment of the
+// endpoint.
 func NewDeployment(endpoint *Endpoint, blob []byte) *Deployment {
 	hashBytes := md5.Sum(blob)
 	hashstr := hex.EncodeToString(hashBytes[:])
 	deployID := uuid.New()
-	return &Deployment{
+	deploy := &Deployment{
 		ID:         deployID,
 		EndpointID: endpoint.ID,
 		Blob:       blob,
 		Hash:       hashstr,
 		CreatedAT:  time.Now(),
 	}
+	if endpoint.SnapshotEnvironment {
+		deploy.Environment = CopyEnvironment(endpoint.Environment)
+	}
+	return deploy
+}
+
+// NewDeploymentWithEnvironment returns a new deployment that runs the code of
+// the given deployment with the given environment. This allows previewing and
+// publishing configuration changes the same way as code changes.
+func NewDeploymentWithEnvironment(deploy *Deployment, env map[string]string) *Deployment {
+	return &Deployment{
+		ID:          uuid.New(),
+		EndpointID:  deploy.EndpointID,
+		Blob:        deploy.Blob,
+		Hash:        deploy.Hash,
+		Environment: CopyEnvironment(env),
+		Assets:      deploy.Assets,
+		CreatedAT:   time.Now(),
+	}
+}
+
+// CopyEnvironment returns a copy of the given environment. A nil environment
+// results in an empty one.
+func CopyEnvironment(env map[string]string) map[string]string {
+	m := make(map[string]string, len(env))
+	for k, v := range env {
+		m[k] = v
+	}
+	return m
 }
@@ -0,0 +1,76 @@
+package types
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DomainVerificationPrefix is the prefix of the DNS TXT record that needs to
+// exist in order to verify the ownership of a custom domain.
+const DomainVerificationPrefix = "_raptor-challenge"
+
+// Domain is a custom hostname that routes to an endpoint once verified.
+type Domain struct {
+	Hostname          string    `json:"hostname"`
+	EndpointID        uuid.UUID `json:"endpoint_id"`
+	VerificationToken string    `json:"verification_token"`
+	Verified          bool      `json:"verified"`
+	CreatedAT         time.Time `json:"created_at"`
+}
+
+func NewDomain(endpoint *Endpoint, hostname string) *Domain {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return &Domain{
+		Hostname:          NormalizeHostname(hostname),
+		EndpointID:        endpoint.ID,
+		VerificationToken: hex.EncodeToString(b),
+		CreatedAT:         time.Now(),
+	}
+}
+
+// VerificationRecord returns the name of the DNS TXT record that needs to
+// hold the verification token of the domain.
+func (d Domain) VerificationRecord() string {
+	return fmt.Sprintf("%s.%s", DomainVerificationPrefix, d.Hostname)
+}
+
+var (
+	slugRegexp     = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+	hostnameRegexp = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+	nonSlugChars   = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// ValidSlug reports whether the given slug can be used as a path segment and
+// as a DNS label.
+func ValidSlug(slug string) bool {
+	return slugRegexp.MatchString(slug)
+}
+
+// ValidHostname reports whether the given hostname can be attached to an endpoint.
+func ValidHostname(hostname string) bool {
+	return len(hostname) <= 253 && hostnameRegexp.MatchString(NormalizeHostname(hostname))
+}
+
+// NormalizeHostname lowercases the given hostname and strips the trailing dot.
+func NormalizeHostname(hostname string) string {
+	return strings.TrimSuffix(strings.ToLower(hostname), ".")
+}
+
+// MakeSlug turns the given name into a slug.
+func MakeSlug(name string) string {
+	slug := nonSlugChars.ReplaceAllString(strings.ToLower(name), "-")
+	slug = strings.Trim(slug, "-")
+	if len(slug) > 50 {
+		slug = strings.TrimRight(slug[:50], "-")
+	}
+	return slug
+}
@@ -1,14 +1,27 @@
 package types
 
 import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// Runtimes are the runtimes an endpoint can run on. Besides js and python,
+// whose deployments are scripts run by an interpreter module, all of them
+// run a WASI module that reads the request from stdin and writes its
+// response to stdout. They only differ in the toolchain the module is built
+// with.
 var Runtimes = map[string]bool{
-	"js": true,
-	"go": true,
+	"js":     true,
+	"go":     true,
+	"tinygo": true,
+	"rust":   true,
+	"wasi":   true,
+	"python": true,
 }
 
 func ValidRuntime(runtime string) bool {
@@ -16,14 +29,29 @@ func ValidRuntime(runtime string) bool {
 	return ok
 }
 
+// RuntimeNames returns the names of the runtimes in lexical order.
+func RuntimeNames() []string {
+	names := make([]string, 0, len(Runtimes))
+	for name := range Runtimes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 type Endpoint struct {
-	ID                 uuid.UUID            `json:"id"`
-	Name               string               `json:"name"`
-	Runtime            string               `json:"runtime"`
-	ActiveDeploymentID uuid.UUID            `json:"active_deployment_id"`
-	Environment        map[string]string    `json:"environment"`
-	DeploymentHistory  []*DeploymentHistory `json:"deployment_history"`
-	CreatedAT          time.Time            `json:"created_at"`
+	ID                  uuid.UUID            `json:"id"`
+	Name                string               `json:"name"`
+	Slug                string               `json:"slug"`
+	Runtime             string               `json:"runtime"`
+	ActiveDeploymentID  uuid.UUID            `json:"active_deployment_id"`
+	Environment         map[string]string    `json:"environment"`
+	SnapshotEnvironment bool                 `json:"snapshot_environment"`
+	AllowedHosts        []string             `json:"allowed_hosts"`
+	DeploymentHistory   []*DeploymentHistory `json:"deployment_history"`
+	Version             int                  `json:"version"`
+	CacheGeneration     int                  `json:"cache_generation"`
+	CreatedAT           time.Time            `json:"created_at"`
 }
 
 func (e Endpoint) HasActiveDeploy() bool {
@@ -40,7 +68,9 @@ func NewEndpoint(name string, runtime string, env map[string]string) *Endpoint {
 		Name:              name,
 		Environment:       env,
 		Runtime:           runtime,
+		AllowedHosts:      []string{},
 		DeploymentHistory: []*DeploymentHistory{},
+		Version:           1,
 		CreatedAT:         time.Now(),
 	}
 }
@@ -49,3 +79,37 @@ type DeploymentHistory struct {
 	ID        uuid.UUID `json:"id"`
 	CreatedAT time.Time `json:"created_at"`
 }
+
+// HostAllowed reports whether the host of the given url matches any of the
+// given allowed hosts, see ValidAllowedHost.
+func HostAllowed(allowedHosts []string, u *url.URL) bool {
+	hostname := strings.ToLower(u.Hostname())
+	host := strings.ToLower(u.Host)
+	for _, allowed := range allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if allowed == host || allowed == hostname {
+			return true
+		}
+		if strings.HasPrefix(allowed, "*.") && strings.HasSuffix(hostname, allowed[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidAllowedHost reports whether the given host can be added to the hosts
+// an endpoint is allowed to send outbound requests to. The host is either a
+// hostname, a host:port or a wildcard like *.example.com.
+func ValidAllowedHost(host string) bool {
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		if len(port) == 0 || strings.HasPrefix(h, "*.") {
+			return false
+		}
+		host = h
+	}
+	host = strings.TrimPrefix(host, "*.")
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	return host == "localhost" || ValidHostname(host)
+}
@@ -0,0 +1,82 @@
+package types
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The states of an asynchronous invocation.
+const (
+	InvocationQueued    = "queued"
+	InvocationRunning   = "running"
+	InvocationSucceeded = "succeeded"
+	// InvocationDead is the state of invocations that failed all their
+	// attempts. They stay in the dead letter queue until they are retried.
+	InvocationDead = "dead"
+)
+
+// Invocation is a request to an endpoint that is executed asynchronously.
+// It is polled on the ingress with a token, of which only the hash is kept.
+type Invocation struct {
+	ID            uuid.UUID           `json:"id"`
+	EndpointID    uuid.UUID           `json:"endpoint_id"`
+	DeploymentID  uuid.UUID           `json:"deployment_id"`
+	Method        string              `json:"method"`
+	URL           string              `json:"url"`
+	Header        map[string][]string `json:"header"`
+	Body          []byte              `json:"body"`
+	Status        string              `json:"status"`
+	Attempts      int                 `json:"attempts"`
+	MaxAttempts   int                 `json:"max_attempts"`
+	Backoff       time.Duration       `json:"backoff"`
+	NextAttemptAT time.Time           `json:"next_attempt_at"`
+	StatusCode    int                 `json:"status_code"`
+	Response      []byte              `json:"response"`
+	Error         string              `json:"error,omitempty"`
+	CallbackURL   string              `json:"callback_url,omitempty"`
+	CreatedAT     time.Time           `json:"created_at"`
+	UpdatedAT     time.Time           `json:"updated_at"`
+	TokenHash     string              `json:"-"`
+}
+
+func NewInvocation(endpoint *Endpoint, method, url string, header map[string][]string, body []byte) *Invocation {
+	now := time.Now().UTC()
+	return &Invocation{
+		ID:            uuid.New(),
+		EndpointID:    endpoint.ID,
+		Method:        method,
+		URL:           url,
+		Header:        header,
+		Body:          body,
+		Status:        InvocationQueued,
+		MaxAttempts:   1,
+		NextAttemptAT: now,
+		CreatedAT:     now,
+		UpdatedAT:     now,
+	}
+}
+
+// HashInvocationToken returns the hash of the given token, which is stored
+// instead of the token itself.
+func HashInvocationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidToken reports whether the given token is the token of the
+// invocation.
+func (i Invocation) ValidToken(token string) bool {
+	if len(i.TokenHash) == 0 || len(token) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(HashInvocationToken(token)), []byte(i.TokenHash)) == 1
+}
+
+// Done reports whether the invocation will not be attempted again.
+func (i Invocation) Done() bool {
+	return i.Status == InvocationSucceeded || i.Status == InvocationDead
+}
@@ -0,0 +1,55 @@
+package types
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxKVKeySize is the maximum size of a key in the key-value store.
+	MaxKVKeySize = 512
+	// MaxKVValueSize is the maximum size of a value in the key-value store.
+	MaxKVValueSize = 1 << 20
+)
+
+// KVEntry is a single entry in the key-value store of an endpoint.
+type KVEntry struct {
+	EndpointID uuid.UUID  `json:"endpoint_id"`
+	Key        string     `json:"key"`
+	Value      []byte     `json:"value"`
+	ExpiresAT  *time.Time `json:"expires_at,omitempty"`
+	UpdatedAT  time.Time  `json:"updated_at"`
+}
+
+// NewKVEntry returns a new entry for the given endpoint. The entry expires
+// after the given ttl unless it is zero.
+func NewKVEntry(endpointID uuid.UUID, key string, value []byte, ttl time.Duration) (*KVEntry, error) {
+	if len(key) == 0 || len(key) > MaxKVKeySize {
+		return nil, fmt.Errorf("key should be between 1 and %d bytes long", MaxKVKeySize)
+	}
+	if len(value) > MaxKVValueSize {
+		return nil, fmt.Errorf("value can be maximum %d bytes long", MaxKVValueSize)
+	}
+	if ttl < 0 {
+		return nil, fmt.Errorf("ttl can not be negative")
+	}
+	now := time.Now()
+	entry := &KVEntry{
+		EndpointID: endpointID,
+		Key:        key,
+		Value:      value,
+		UpdatedAT:  now,
+	}
+	if ttl > 0 {
+		expires := now.Add(ttl)
+		entry.ExpiresAT = &expires
+	}
+	return entry, nil
+}
+
+// Expired reports whether the entry is expired at the given time.
+func (e KVEntry) Expired(now time.Time) bool {
+	return e.ExpiresAT != nil && !now.Before(*e.ExpiresAT)
+}
@@ -20,10 +20,24 @@ type RequestMetric struct {
 	RequestURL   string        `json:"request_url"`
 	Duration     time.Duration `json:"duration"`
 	StatusCode   int           `json:"status_code"`
+	CreatedAT    time.Time     `json:"created_at"`
 }
 
 // RuntimeLogEvent holds the logs that where written out
 // during runtime invocation of a script.
 type RuntimeLogEvent struct {
-	Data []byte
+	EndpointID   uuid.UUID
+	DeploymentID uuid.UUID
+	RequestID    string
+	Data         []byte
+}
+
+// RuntimeLog holds the logs of a single request on LIVE.
+type RuntimeLog struct {
+	ID           uuid.UUID `json:"id"`
+	EndpointID   uuid.UUID `json:"endpoint_id"`
+	DeploymentID uuid.UUID `json:"deployment_id"`
+	RequestID    string    `json:"request_id"`
+	Data         string    `json:"data"`
+	CreatedAT    time.Time `json:"created_at"`
 }
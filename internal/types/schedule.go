@@ -0,0 +1,77 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/anthdm/raptor/internal/cron"
+	"github.com/google/uuid"
+)
+
+// Schedule invokes the LIVE deployment of an endpoint on a cron schedule.
+type Schedule struct {
+	ID         uuid.UUID  `json:"id"`
+	EndpointID uuid.UUID  `json:"endpoint_id"`
+	Cron       string     `json:"cron"`
+	Method     string     `json:"method"`
+	Path       string     `json:"path"`
+	NextRunAT  time.Time  `json:"next_run_at"`
+	LastRunAT  *time.Time `json:"last_run_at,omitempty"`
+	LastStatus int        `json:"last_status"`
+	CreatedAT  time.Time  `json:"created_at"`
+}
+
+// NewSchedule returns a new schedule for the given endpoint. Schedules are
+// evaluated in UTC.
+func NewSchedule(endpoint *Endpoint, expr, method, path string) (*Schedule, error) {
+	if len(method) == 0 {
+		method = http.MethodPost
+	}
+	if len(path) == 0 {
+		path = "/"
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path should start with a slash: %s", path)
+	}
+	s := &Schedule{
+		ID:         uuid.New(),
+		EndpointID: endpoint.ID,
+		Cron:       expr,
+		Method:     strings.ToUpper(method),
+		Path:       path,
+		CreatedAT:  time.Now(),
+	}
+	next, err := s.Next(s.CreatedAT)
+	if err != nil {
+		return nil, err
+	}
+	s.NextRunAT = next
+	return s, nil
+}
+
+// Next returns the first time the schedule runs after t.
+func (s Schedule) Next(t time.Time) (time.Time, error) {
+	c, err := cron.Parse(s.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := c.Next(t.UTC())
+	if next.IsZero() {
+		return next, fmt.Errorf("cron expression %q never runs", s.Cron)
+	}
+	return next, nil
+}
+
+// ScheduleRun is a single invocation of a schedule.
+type ScheduleRun struct {
+	ID           uuid.UUID     `json:"id"`
+	ScheduleID   uuid.UUID     `json:"schedule_id"`
+	DeploymentID uuid.UUID     `json:"deployment_id"`
+	StatusCode   int           `json:"status_code"`
+	Duration     time.Duration `json:"duration"`
+	Logs         string        `json:"logs"`
+	Error        string        `json:"error,omitempty"`
+	StartedAT    time.Time     `json:"started_at"`
+}
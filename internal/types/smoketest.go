@@ -0,0 +1,79 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// SmokeTest is a request that is made against the preview of a deployment
+// before it is published. The deployment is only published when all its
+// smoke tests respond with the expected status.
+type SmokeTest struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// The expected status code, any 2xx status when 0.
+	Status int `json:"status"`
+}
+
+// ParseSmokeTest parses a smoke test in the form of "[METHOD] PATH [STATUS]",
+// like "/health" or "POST /users 201".
+func ParseSmokeTest(s string) (SmokeTest, error) {
+	var (
+		t      = SmokeTest{Method: http.MethodGet}
+		fields = strings.Fields(s)
+	)
+	if len(fields) > 0 && !strings.HasPrefix(fields[0], "/") {
+		t.Method = strings.ToUpper(fields[0])
+		fields = fields[1:]
+	}
+	if len(fields) == 0 || len(fields) > 2 {
+		return t, fmt.Errorf("invalid smoke test %q, expected [METHOD] PATH [STATUS]", s)
+	}
+	t.Path = fields[0]
+	if len(fields) == 2 {
+		status, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return t, fmt.Errorf("invalid status in smoke test %q", s)
+		}
+		t.Status = status
+	}
+	return t, t.Validate()
+}
+
+func (t SmokeTest) Validate() error {
+	if len(t.Method) == 0 {
+		return fmt.Errorf("smoke test method is required")
+	}
+	if !strings.HasPrefix(t.Path, "/") {
+		return fmt.Errorf("smoke test path should start with a slash: %s", t.Path)
+	}
+	if t.Status != 0 && (t.Status < 100 || t.Status > 599) {
+		return fmt.Errorf("invalid smoke test status %d", t.Status)
+	}
+	return nil
+}
+
+// Passes reports whether the given status is the expected status.
+func (t SmokeTest) Passes(status int) bool {
+	if t.Status == 0 {
+		return status >= 200 && status < 300
+	}
+	return status == t.Status
+}
+
+func (t SmokeTest) String() string {
+	if t.Status == 0 {
+		return t.Method + " " + t.Path
+	}
+	return fmt.Sprintf("%s %s %d", t.Method, t.Path, t.Status)
+}
+
+// SmokeTestResult is the outcome of a smoke test.
+type SmokeTestResult struct {
+	SmokeTest
+	StatusCode int    `json:"status_code"`
+	Passed     bool   `json:"passed"`
+	Error      string `json:"error,omitempty"`
+}
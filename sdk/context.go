@@ -0,0 +1,43 @@
+package run
+
+import (
+	"context"
+
+	"github.com/anthdm/raptor/proto"
+)
+
+// Info holds what raptor knows about the request that is being handled.
+type Info struct {
+	// RequestID is the id of the request, which is also in the x-request-id
+	// header.
+	RequestID    string
+	EndpointID   string
+	DeploymentID string
+	// Preview is true when the request is made on the preview of the
+	// deployment instead of LIVE.
+	Preview bool
+	// Scheduled is true when the request is made by a cron schedule.
+	Scheduled bool
+}
+
+type infoKey struct{}
+
+// FromContext returns the info of the request that the given context belongs
+// to. It reports false when the context is not one of a request handled by
+// raptor.
+//
+//	info, ok := raptor.FromContext(r.Context())
+func FromContext(ctx context.Context) (Info, bool) {
+	info, ok := ctx.Value(infoKey{}).(Info)
+	return info, ok
+}
+
+func newContext(ctx context.Context, req *proto.HTTPRequest) context.Context {
+	return context.WithValue(ctx, infoKey{}, Info{
+		RequestID:    req.ID,
+		EndpointID:   req.EndpointID,
+		DeploymentID: req.DeploymentID,
+		Preview:      req.Preview,
+		Scheduled:    req.Scheduled,
+	})
+}
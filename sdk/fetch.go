@@ -0,0 +1,69 @@
+package run
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/anthdm/raptor/proto"
+	prot "google.golang.org/protobuf/proto"
+)
+
+// Transport is an http.RoundTripper that sends requests through the host,
+// which only allows requests to the allowed hosts of the endpoint. It is the
+// http.DefaultTransport of guests, hence http.Get and friends work as is.
+type Transport struct{}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	var body []byte
+	if r.Body != nil {
+		b, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+	header := make(map[string]*proto.HeaderFields, len(r.Header))
+	for k, v := range r.Header {
+		header[k] = &proto.HeaderFields{Fields: v}
+	}
+	b, err := prot.Marshal(&proto.FetchRequest{
+		Method: r.Method,
+		URL:    r.URL.String(),
+		Header: header,
+		Body:   body,
+	})
+	if err != nil {
+		return nil, err
+	}
+	out, err := hostFetch(b)
+	if err != nil {
+		return nil, err
+	}
+	var res proto.FetchResponse
+	if err := prot.Unmarshal(out, &res); err != nil {
+		return nil, err
+	}
+	if len(res.Error) > 0 {
+		return nil, errors.New(res.Error)
+	}
+	resp := &http.Response{
+		Status:        strconv.Itoa(int(res.StatusCode)) + " " + http.StatusText(int(res.StatusCode)),
+		StatusCode:    int(res.StatusCode),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header, len(res.Header)),
+		Body:          io.NopCloser(bytes.NewReader(res.Body)),
+		ContentLength: int64(len(res.Body)),
+		Request:       r,
+	}
+	for k, v := range res.Header {
+		resp.Header[k] = v.Fields
+	}
+	return resp, nil
+}
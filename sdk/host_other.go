@@ -0,0 +1,19 @@
+//go:build !wasip1
+
+package run
+
+import "errors"
+
+var errNoHost = errors.New("host functions are only available inside the raptor runtime")
+
+func hostFetch(req []byte) ([]byte, error) {
+	return nil, errNoHost
+}
+
+func hostKV(req []byte) ([]byte, error) {
+	return nil, errNoHost
+}
+
+func hostNextRequest() ([]byte, bool) {
+	return nil, false
+}
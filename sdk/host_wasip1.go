@@ -0,0 +1,68 @@
+//go:build wasip1
+
+package run
+
+import (
+	"net/http"
+	"unsafe"
+)
+
+func init() {
+	http.DefaultTransport = &Transport{}
+}
+
+//go:wasmimport raptor fetch
+func fetch(ptr, size uint32) uint32
+
+//go:wasmimport raptor fetch_response
+func fetchResponse(ptr uint32)
+
+//go:wasmimport raptor kv
+func kv(ptr, size uint32) uint32
+
+//go:wasmimport raptor kv_response
+func kvResponse(ptr uint32)
+
+//go:wasmimport raptor next_request
+func nextRequest() uint32
+
+//go:wasmimport raptor next_request_response
+func nextRequestResponse(ptr uint32)
+
+func hostFetch(req []byte) ([]byte, error) {
+	return hostCall(req, fetch, fetchResponse), nil
+}
+
+func hostKV(req []byte) ([]byte, error) {
+	return hostCall(req, kv, kvResponse), nil
+}
+
+// hostNextRequest blocks until the host has the next request for the
+// instance. It reports false when there are no more requests.
+func hostNextRequest() ([]byte, bool) {
+	size := nextRequest()
+	if size == 0 {
+		return nil, false
+	}
+	out := make([]byte, size)
+	nextRequestResponse(uint32(uintptr(unsafe.Pointer(&out[0]))))
+	return out, true
+}
+
+// hostCall calls a host function with the given request and collects its
+// result with the matching response function.
+func hostCall(req []byte, call func(ptr, size uint32) uint32, collect func(ptr uint32)) []byte {
+	var ptr uint32
+	if len(req) > 0 {
+		ptr = uint32(uintptr(unsafe.Pointer(&req[0])))
+	}
+	size := call(ptr, uint32(len(req)))
+	out := make([]byte, size)
+	ptr = 0
+	if size > 0 {
+		ptr = uint32(uintptr(unsafe.Pointer(&out[0])))
+	}
+	// Always collect the result so the host can release it.
+	collect(ptr)
+	return out
+}
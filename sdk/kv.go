@@ -0,0 +1,82 @@
+package run
+
+import (
+	"errors"
+	"time"
+
+	"github.com/anthdm/raptor/proto"
+	prot "google.golang.org/protobuf/proto"
+)
+
+// ErrKeyNotFound is returned by KV.Get when the key does not exist or is expired.
+var ErrKeyNotFound = errors.New("key not found")
+
+// KV is the key-value store of the endpoint. Its data outlives invocations
+// and is shared by all deployments of the endpoint.
+var KV KVStore
+
+// KVStore gives access to the key-value store of the endpoint.
+type KVStore struct{}
+
+// Get returns the value of the given key.
+func (KVStore) Get(key string) ([]byte, error) {
+	res, err := kvCall(&proto.KVRequest{Op: "get", Key: key})
+	if err != nil {
+		return nil, err
+	}
+	if !res.Found {
+		return nil, ErrKeyNotFound
+	}
+	return res.Value, nil
+}
+
+// Put sets the value of the given key. The key expires after the given ttl
+// unless it is zero.
+func (KVStore) Put(key string, value []byte, ttl time.Duration) error {
+	_, err := kvCall(&proto.KVRequest{
+		Op:        "put",
+		Key:       key,
+		Value:     value,
+		TtlMillis: ttl.Milliseconds(),
+	})
+	return err
+}
+
+// Delete removes the given key.
+func (KVStore) Delete(key string) error {
+	_, err := kvCall(&proto.KVRequest{Op: "delete", Key: key})
+	return err
+}
+
+// List returns the keys with the given prefix in lexical order. At most limit
+// keys are returned, a limit of zero returns up to 100 keys.
+func (KVStore) List(prefix string, limit int) ([]string, error) {
+	res, err := kvCall(&proto.KVRequest{
+		Op:     "list",
+		Prefix: prefix,
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.Keys, nil
+}
+
+func kvCall(req *proto.KVRequest) (*proto.KVResponse, error) {
+	b, err := prot.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	out, err := hostKV(b)
+	if err != nil {
+		return nil, err
+	}
+	var res proto.KVResponse
+	if err := prot.Unmarshal(out, &res); err != nil {
+		return nil, err
+	}
+	if len(res.Error) > 0 {
+		return nil, errors.New(res.Error)
+	}
+	return &res, nil
+}
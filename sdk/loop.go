@@ -0,0 +1,29 @@
+package run
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/anthdm/raptor/proto"
+	prot "google.golang.org/protobuf/proto"
+)
+
+// serveLoop serves requests with h until the host has no more requests for
+// the instance. The instance, and the state of the guest, is reused for all
+// of them.
+func serveLoop(h http.Handler) {
+	for {
+		b, ok := hostNextRequest()
+		if !ok {
+			return
+		}
+		var req proto.HTTPRequest
+		if err := prot.Unmarshal(b, &req); err != nil {
+			log.Fatal(err)
+		}
+		if err := serve(h, &req, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,452 @@
+//! SDK of the rust runtime of raptor.
+//!
+//! Raptor writes the request to the stdin of the module as a protobuf
+//! encoded `HTTPRequest`. The module writes its logs, the headers and the
+//! body of the response to stdout, followed by the status, the length of the
+//! body and the length of the headers as little endian u32s and the `RPH1`
+//! magic. The headers are a `Name: value\n` line per value.
+//!
+//! ```no_run
+//! fn main() {
+//!     raptor::handle(|req| {
+//!         raptor::Response::new(200).body(format!("hello {}", req.query("name").unwrap_or("")))
+//!     });
+//! }
+//! ```
+//!
+//! Build the module with `cargo build --target wasm32-wasip1 --release`.
+
+use std::any::Any;
+use std::collections::HashMap;
+use std::io::{self, Read, Write};
+use std::panic::{self, AssertUnwindSafe};
+
+/// What raptor knows about the request that is being handled.
+#[derive(Debug, Default, Clone, PartialEq)]
+pub struct Info {
+    /// The id of the request, which is also in the x-request-id header.
+    pub request_id: String,
+    pub endpoint_id: String,
+    pub deployment_id: String,
+    /// True when the request is made on the preview of the deployment
+    /// instead of LIVE.
+    pub preview: bool,
+    /// True when the request is made by a cron schedule.
+    pub scheduled: bool,
+}
+
+/// The request as it was received by the ingress.
+#[derive(Debug, Default, Clone, PartialEq)]
+pub struct Request {
+    pub method: String,
+    /// The path and query of the request.
+    pub url: String,
+    /// The headers of the request by their canonical name, Content-Type.
+    pub headers: HashMap<String, Vec<String>>,
+    pub body: Vec<u8>,
+    pub host: String,
+    pub remote_addr: String,
+    /// The environment of the endpoint, which is also available with
+    /// `std::env::var`.
+    pub env: HashMap<String, String>,
+    pub info: Info,
+}
+
+impl Request {
+    /// Returns the path of the request.
+    pub fn path(&self) -> &str {
+        match self.url.find('?') {
+            Some(i) => &self.url[..i],
+            None => &self.url,
+        }
+    }
+
+    /// Returns the first value of the query parameter with the given name.
+    /// The value is not percent decoded.
+    pub fn query(&self, name: &str) -> Option<&str> {
+        let query = &self.url[self.url.find('?')? + 1..];
+        query.split('&').find_map(|pair| {
+            let mut kv = pair.splitn(2, '=');
+            if kv.next()? == name {
+                Some(kv.next().unwrap_or(""))
+            } else {
+                None
+            }
+        })
+    }
+
+    /// Returns the first value of the header with the given name, which is
+    /// case insensitive.
+    pub fn header(&self, name: &str) -> Option<&str> {
+        self.headers
+            .iter()
+            .find(|(k, _)| k.eq_ignore_ascii_case(name))
+            .and_then(|(_, v)| v.first())
+            .map(|v| v.as_str())
+    }
+
+    /// Decodes the protobuf encoded `HTTPRequest`. Fields the SDK does not
+    /// use are skipped.
+    pub fn decode(b: &[u8]) -> Result<Request, DecodeError> {
+        let mut req = Request::default();
+        for field in Fields::new(b) {
+            let (num, value) = field?;
+            match (num, value) {
+                (1, Value::Bytes(v)) => req.body = v.to_vec(),
+                (2, Value::Bytes(v)) => req.method = string(v)?,
+                (3, Value::Bytes(v)) => req.url = string(v)?,
+                (4, Value::Bytes(v)) => req.info.endpoint_id = string(v)?,
+                (5, Value::Bytes(v)) => req.info.request_id = string(v)?,
+                (6, Value::Bytes(v)) => {
+                    let (key, value) = map_entry(v)?;
+                    let mut fields = Vec::new();
+                    for field in Fields::new(value) {
+                        if let (1, Value::Bytes(v)) = field? {
+                            fields.push(string(v)?);
+                        }
+                    }
+                    req.headers.insert(key, fields);
+                }
+                (8, Value::Bytes(v)) => req.info.deployment_id = string(v)?,
+                (9, Value::Bytes(v)) => {
+                    let (key, value) = map_entry(v)?;
+                    req.env.insert(key, string(value)?);
+                }
+                (10, Value::Varint(v)) => req.info.preview = v != 0,
+                (13, Value::Varint(v)) => req.info.scheduled = v != 0,
+                (14, Value::Bytes(v)) => req.host = string(v)?,
+                (15, Value::Bytes(v)) => req.remote_addr = string(v)?,
+                _ => {}
+            }
+        }
+        Ok(req)
+    }
+}
+
+/// The response of a handler.
+#[derive(Debug, Clone, PartialEq)]
+pub struct Response {
+    pub status: u32,
+    /// The headers of the response, in the order they are sent.
+    pub headers: Vec<(String, String)>,
+    pub body: Vec<u8>,
+}
+
+impl Response {
+    pub fn new(status: u32) -> Response {
+        Response {
+            status,
+            headers: Vec::new(),
+            body: Vec::new(),
+        }
+    }
+
+    /// Adds a header to the response.
+    pub fn header(mut self, name: impl Into<String>, value: impl Into<String>) -> Response {
+        self.headers.push((name.into(), value.into()));
+        self
+    }
+
+    /// Sets the body of the response.
+    pub fn body(mut self, body: impl Into<Vec<u8>>) -> Response {
+        self.body = body.into();
+        self
+    }
+}
+
+impl Default for Response {
+    fn default() -> Response {
+        Response::new(200)
+    }
+}
+
+/// Reads the request from stdin, handles it with f and writes the response
+/// to stdout.
+pub fn handle<F>(f: F)
+where
+    F: FnOnce(&Request) -> Response,
+{
+    let mut b = Vec::new();
+    if let Err(err) = io::stdin().read_to_end(&mut b) {
+        eprintln!("{}", err);
+        std::process::exit(1);
+    }
+    let req = match Request::decode(&b) {
+        Ok(req) => req,
+        Err(err) => {
+            eprintln!("{}", err);
+            std::process::exit(1);
+        }
+    };
+
+    // WASI modules abort on panics instead of unwinding, hence the hook
+    // responds with the 500 and exits before the module aborts.
+    #[cfg(panic = "abort")]
+    {
+        let req = req.clone();
+        panic::set_hook(Box::new(move |info| {
+            let stdout = io::stdout();
+            let mut out = stdout.lock();
+            let _ = write_panic(&mut out, &req, info.payload());
+            std::process::exit(0);
+        }));
+    }
+
+    let stdout = io::stdout();
+    if let Err(err) = serve(f, &req, &mut stdout.lock()) {
+        eprintln!("{}", err);
+        std::process::exit(1);
+    }
+}
+
+/// Handles the request with f and writes the response to out, followed by
+/// its status and length. A handler that panics responds with a 500.
+fn serve<F, W>(f: F, req: &Request, out: &mut W) -> io::Result<()>
+where
+    F: FnOnce(&Request) -> Response,
+    W: Write,
+{
+    match panic::catch_unwind(AssertUnwindSafe(|| f(req))) {
+        Ok(resp) => write_response(out, &resp),
+        Err(v) => write_panic(out, req, v.as_ref()),
+    }
+}
+
+/// Writes the panic to the logs of the request and responds with a 500.
+fn write_panic<W: Write>(out: &mut W, req: &Request, v: &(dyn Any + Send)) -> io::Result<()> {
+    let msg = v
+        .downcast_ref::<&str>()
+        .map(|s| s.to_string())
+        .or_else(|| v.downcast_ref::<String>().cloned())
+        .unwrap_or_default();
+    writeln!(out, "panic serving {} {}: {}", req.method, req.url, msg)?;
+    let resp = Response::new(500)
+        .header("Content-Type", "application/json")
+        .body(format!(
+            "{{\"error\":\"internal server error\",\"request_id\":{:?}}}\n",
+            req.info.request_id
+        ));
+    write_response(out, &resp)
+}
+
+/// Writes the headers and the body of the response, followed by its status
+/// and the lengths. Headers that do not fit on a line are left out.
+fn write_response<W: Write>(out: &mut W, resp: &Response) -> io::Result<()> {
+    let mut block = String::new();
+    for (name, value) in &resp.headers {
+        if name.is_empty() || name.contains([':', '\r', '\n']) || value.contains(['\r', '\n']) {
+            continue;
+        }
+        block.push_str(&format!("{}: {}\n", name, value));
+    }
+    out.write_all(block.as_bytes())?;
+    out.write_all(&resp.body)?;
+    out.write_all(&resp.status.to_le_bytes())?;
+    out.write_all(&(resp.body.len() as u32).to_le_bytes())?;
+    out.write_all(&(block.len() as u32).to_le_bytes())?;
+    out.write_all(b"RPH1")?;
+    out.flush()
+}
+
+/// The error of a request that is not a valid `HTTPRequest`.
+#[derive(Debug, Clone, PartialEq)]
+pub struct DecodeError(&'static str);
+
+impl std::fmt::Display for DecodeError {
+    fn fmt(&self, f: &mut std::fmt::Formatter) -> std::fmt::Result {
+        write!(f, "invalid request: {}", self.0)
+    }
+}
+
+impl std::error::Error for DecodeError {}
+
+enum Value<'a> {
+    Varint(u64),
+    Bytes(&'a [u8]),
+    Other,
+}
+
+/// Iterates over the fields of a protobuf message.
+struct Fields<'a> {
+    b: &'a [u8],
+}
+
+impl<'a> Fields<'a> {
+    fn new(b: &'a [u8]) -> Fields<'a> {
+        Fields { b }
+    }
+
+    fn varint(&mut self) -> Result<u64, DecodeError> {
+        let mut v = 0u64;
+        for i in 0..10 {
+            let (&c, rest) = self
+                .b
+                .split_first()
+                .ok_or(DecodeError("truncated varint"))?;
+            self.b = rest;
+            v |= u64::from(c & 0x7f) << (7 * i);
+            if c < 0x80 {
+                return Ok(v);
+            }
+        }
+        Err(DecodeError("varint overflow"))
+    }
+
+    fn take(&mut self, n: usize) -> Result<&'a [u8], DecodeError> {
+        if n > self.b.len() {
+            return Err(DecodeError("truncated field"));
+        }
+        let (v, rest) = self.b.split_at(n);
+        self.b = rest;
+        Ok(v)
+    }
+
+    fn field(&mut self) -> Result<(u64, Value<'a>), DecodeError> {
+        let tag = self.varint()?;
+        let value = match tag & 7 {
+            0 => Value::Varint(self.varint()?),
+            1 => self.take(8).map(|_| Value::Other)?,
+            2 => {
+                let n = self.varint()? as usize;
+                Value::Bytes(self.take(n)?)
+            }
+            5 => self.take(4).map(|_| Value::Other)?,
+            _ => return Err(DecodeError("unsupported wire type")),
+        };
+        Ok((tag >> 3, value))
+    }
+}
+
+impl<'a> Iterator for Fields<'a> {
+    type Item = Result<(u64, Value<'a>), DecodeError>;
+
+    fn next(&mut self) -> Option<Self::Item> {
+        if self.b.is_empty() {
+            return None;
+        }
+        let field = self.field();
+        if field.is_err() {
+            self.b = &[];
+        }
+        Some(field)
+    }
+}
+
+fn string(b: &[u8]) -> Result<String, DecodeError> {
+    String::from_utf8(b.to_vec()).map_err(|_| DecodeError("invalid utf-8"))
+}
+
+/// Decodes the key and value of an entry of a map field.
+fn map_entry(b: &[u8]) -> Result<(String, &[u8]), DecodeError> {
+    let (mut key, mut value) = (String::new(), &[][..]);
+    for field in Fields::new(b) {
+        match field? {
+            (1, Value::Bytes(v)) => key = string(v)?,
+            (2, Value::Bytes(v)) => value = v,
+            _ => {}
+        }
+    }
+    Ok((key, value))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn bytes_field(out: &mut Vec<u8>, num: u8, v: &[u8]) {
+        out.push(num << 3 | 2);
+        out.push(v.len() as u8);
+        out.extend_from_slice(v);
+    }
+
+    fn request() -> Vec<u8> {
+        let mut b = Vec::new();
+        bytes_field(&mut b, 1, b"body");
+        bytes_field(&mut b, 2, b"POST");
+        bytes_field(&mut b, 3, b"/users?name=bob&x");
+        bytes_field(&mut b, 5, b"request-id");
+        let mut fields = Vec::new();
+        bytes_field(&mut fields, 1, b"bar");
+        let mut entry = Vec::new();
+        bytes_field(&mut entry, 1, b"X-Foo");
+        bytes_field(&mut entry, 2, &fields);
+        bytes_field(&mut b, 6, &entry);
+        let mut entry = Vec::new();
+        bytes_field(&mut entry, 1, b"FOO");
+        bytes_field(&mut entry, 2, b"foo");
+        bytes_field(&mut b, 9, &entry);
+        b.extend_from_slice(&[13 << 3, 1]);
+        bytes_field(&mut b, 14, b"app.example.com");
+        b
+    }
+
+    fn parse(out: &[u8]) -> (&[u8], &[u8], &[u8], u32) {
+        assert_eq!(&out[out.len() - 4..], b"RPH1");
+        let n = out.len() - 16;
+        let status = u32::from_le_bytes(out[n..n + 4].try_into().unwrap());
+        let len = u32::from_le_bytes(out[n + 4..n + 8].try_into().unwrap()) as usize;
+        let header_len = u32::from_le_bytes(out[n + 8..n + 12].try_into().unwrap()) as usize;
+        let body = n - len;
+        let header = body - header_len;
+        (&out[..header], &out[header..body], &out[body..n], status)
+    }
+
+    #[test]
+    fn decode_request() {
+        let req = Request::decode(&request()).unwrap();
+        assert_eq!(req.method, "POST");
+        assert_eq!(req.path(), "/users");
+        assert_eq!(req.query("name"), Some("bob"));
+        assert_eq!(req.query("x"), Some(""));
+        assert_eq!(req.query("y"), None);
+        assert_eq!(req.header("x-foo"), Some("bar"));
+        assert_eq!(req.env.get("FOO").map(|s| s.as_str()), Some("foo"));
+        assert_eq!(req.body, b"body");
+        assert_eq!(req.host, "app.example.com");
+        assert_eq!(req.info.request_id, "request-id");
+        assert!(req.info.scheduled);
+        assert!(!req.info.preview);
+
+        assert!(Request::decode(&[0x0a, 0xff]).is_err());
+    }
+
+    #[test]
+    fn serve_request() {
+        let mut out = Vec::new();
+        serve(
+            |req| {
+                Response::new(201)
+                    .header("Cache-Control", "max-age=60")
+                    .header("X-Bad", "a\nb")
+                    .body(format!("hello {}", req.query("name").unwrap()))
+            },
+            &Request::decode(&request()).unwrap(),
+            &mut out,
+        )
+        .unwrap();
+        let (logs, header, body, status) = parse(&out);
+        assert!(logs.is_empty());
+        assert_eq!(header, b"Cache-Control: max-age=60\n");
+        assert_eq!(body, b"hello bob");
+        assert_eq!(status, 201);
+    }
+
+    #[test]
+    fn serve_panic() {
+        let mut out = Vec::new();
+        serve(
+            |_| panic!("boom"),
+            &Request::decode(&request()).unwrap(),
+            &mut out,
+        )
+        .unwrap();
+        let (logs, header, body, status) = parse(&out);
+        assert_eq!(header, b"Content-Type: application/json\n");
+        assert_eq!(logs, b"panic serving POST /users?name=bob&x: boom\n");
+        assert_eq!(
+            body,
+            &b"{\"error\":\"internal server error\",\"request_id\":\"request-id\"}\n"[..]
+        );
+        assert_eq!(status, 500);
+    }
+}
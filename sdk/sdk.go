@@ -2,30 +2,30 @@ package run
 
 import (
 	"bytes"
-	"encoding/binary"
+	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"runtime/debug"
 
+	"github.com/anthdm/raptor/internal/shared"
 	"github.com/anthdm/raptor/proto"
 	// _ "github.com/stealthrocket/net/http"
 	prot "google.golang.org/protobuf/proto"
 )
 
-var (
-	requestBuffer  []byte
-	responseBuffer []byte
-)
-
-type request struct {
-	Body   []byte
-	Method string
-	Header http.Header
-	URL    string
-}
-
 func Handle(h http.Handler) {
+	switch os.Getenv(shared.ModeEnv) {
+	case shared.ModeWebSocket:
+		serveWebSocket()
+		return
+	case shared.ModeLoop:
+		serveLoop(h)
+		return
+	}
 	b, err := io.ReadAll(os.Stdin)
 	if err != nil {
 		log.Fatal(err)
@@ -34,37 +34,105 @@ func Handle(h http.Handler) {
 	if err := prot.Unmarshal(b, &req); err != nil {
 		log.Fatal(err)
 	}
+	if err := serve(h, &req, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
 
+// serve handles the request with h and writes the response to out, with its
+// headers, see shared.WriteResponse. A handler that panics responds with a
+// 500.
+func serve(h http.Handler, req *proto.HTTPRequest, out io.Writer) error {
+	r, err := newRequest(req)
+	if err != nil {
+		return err
+	}
 	w := &ResponseWriter{}
-	r, err := http.NewRequest(req.Method, req.URL, bytes.NewReader(req.Body))
+	if err := serveHTTP(h, w, r); err != nil {
+		// The panic and its stack go to the logs of the request.
+		fmt.Fprintf(out, "%s\n", err)
+		w = &ResponseWriter{}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(panicResponse{
+			Error:     "internal server error",
+			RequestID: req.ID,
+		})
+	}
+	return shared.WriteResponse(out, w.status(), w.header, w.buffer.Bytes())
+}
+
+// panicResponse is the body of the response of a handler that panicked.
+type panicResponse struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id"`
+}
+
+// serveHTTP calls the handler and returns the panic of the handler, if any,
+// as an error.
+func serveHTTP(h http.Handler, w http.ResponseWriter, r *http.Request) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic serving %s %s: %v\n%s", r.Method, r.URL, v, debug.Stack())
+		}
+	}()
+	h.ServeHTTP(w, r)
+	return nil
+}
+
+// newRequest returns the request as it was received by the ingress, with the
+// info of the request in its context.
+func newRequest(req *proto.HTTPRequest) (*http.Request, error) {
+	r, err := http.NewRequestWithContext(newContext(context.Background(), req), req.Method, req.URL, bytes.NewReader(req.Body))
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	for k, v := range req.Header {
 		r.Header[k] = v.Fields
 	}
-	h.ServeHTTP(w, r) // execute the user's handler
-	os.Stdout.Write(w.buffer.Bytes())
-
-	buf := make([]byte, 8)
-	binary.LittleEndian.PutUint32(buf[0:4], uint32(w.statusCode))
-	binary.LittleEndian.PutUint32(buf[4:8], uint32(w.buffer.Len()))
-	os.Stdout.Write(buf)
+	r.Host = req.Host
+	r.RemoteAddr = req.RemoteAddr
+	r.RequestURI = req.URL
+	return r, nil
 }
 
+// ResponseWriter buffers the response of a handler. The response is written
+// once the handler returns, hence Flush does not send anything yet.
 type ResponseWriter struct {
 	buffer     bytes.Buffer
+	header     http.Header
 	statusCode int
 }
 
 func (w *ResponseWriter) Header() http.Header {
-	return http.Header{}
+	if w.header == nil {
+		w.header = http.Header{}
+	}
+	return w.header
 }
 
+// Write writes to the body of the response, with a 200 status unless
+// WriteHeader was called before.
 func (w *ResponseWriter) Write(b []byte) (n int, err error) {
+	w.WriteHeader(http.StatusOK)
 	return w.buffer.Write(b)
 }
 
+// WriteHeader sets the status of the response. Only the first call has an
+// effect, like with net/http.
 func (w *ResponseWriter) WriteHeader(status int) {
-	w.statusCode = status
+	if w.statusCode == 0 {
+		w.statusCode = status
+	}
+}
+
+// Flush implements http.Flusher.
+func (w *ResponseWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (w *ResponseWriter) status() int {
+	if w.statusCode == 0 {
+		return http.StatusOK
+	}
+	return w.statusCode
 }
@@ -0,0 +1,104 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeRequest(t *testing.T) {
+	req := &proto.HTTPRequest{
+		ID:           "request-id",
+		EndpointID:   "endpoint-id",
+		DeploymentID: "deployment-id",
+		Preview:      true,
+		Method:       "GET",
+		URL:          "/users?name=bob",
+		Host:         "app.example.com",
+		RemoteAddr:   "10.0.0.1:1234",
+		Header: map[string]*proto.HeaderFields{
+			"X-Foo": {Fields: []string{"bar"}},
+		},
+	}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/users", r.URL.Path)
+		require.Equal(t, "bob", r.URL.Query().Get("name"))
+		require.Equal(t, "/users?name=bob", r.RequestURI)
+		require.Equal(t, "app.example.com", r.Host)
+		require.Equal(t, "10.0.0.1:1234", r.RemoteAddr)
+		require.Equal(t, "bar", r.Header.Get("X-Foo"))
+
+		info, ok := FromContext(r.Context())
+		require.True(t, ok)
+		require.Equal(t, Info{
+			RequestID:    "request-id",
+			EndpointID:   "endpoint-id",
+			DeploymentID: "deployment-id",
+			Preview:      true,
+		}, info)
+
+		w.Header().Set("Content-Type", "text/plain")
+		require.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+		w.Write([]byte("hello "))
+		w.(http.Flusher).Flush()
+		// The status is set by the first write.
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("bob"))
+	})
+
+	out := &bytes.Buffer{}
+	require.Nil(t, serve(h, req, out))
+	logs, res, header, status, err := shared.ParseResponse(out)
+	require.Nil(t, err)
+	require.Empty(t, logs)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "hello bob", string(res))
+	require.Equal(t, http.Header{"Content-Type": {"text/plain"}}, header)
+
+	_, ok := FromContext(context.Background())
+	require.False(t, ok)
+}
+
+func TestServeStatus(t *testing.T) {
+	req := &proto.HTTPRequest{Method: "GET", URL: "/"}
+
+	out := &bytes.Buffer{}
+	require.Nil(t, serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), req, out))
+	_, res, status, err := shared.ParseStdout(out)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, status)
+	require.Empty(t, res)
+
+	out.Reset()
+	require.Nil(t, serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), req, out))
+	_, _, status, err = shared.ParseStdout(out)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusNoContent, status)
+}
+
+func TestServePanic(t *testing.T) {
+	req := &proto.HTTPRequest{ID: "request-id", Method: "GET", URL: "/"}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		panic("boom")
+	})
+
+	out := &bytes.Buffer{}
+	require.Nil(t, serve(h, req, out))
+	logs, res, status, err := shared.ParseStdout(out)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusInternalServerError, status)
+	require.Contains(t, string(logs), "panic serving GET /: boom")
+
+	var body panicResponse
+	require.Nil(t, json.Unmarshal(res, &body))
+	require.Equal(t, panicResponse{Error: "internal server error", RequestID: "request-id"}, body)
+}
@@ -0,0 +1,141 @@
+package tinygo
+
+import (
+	"fmt"
+	"net/url"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The field numbers of proto.HTTPRequest and proto.HeaderFields.
+const (
+	fieldBody         = 1
+	fieldMethod       = 2
+	fieldURL          = 3
+	fieldEndpointID   = 4
+	fieldID           = 5
+	fieldHeader       = 6
+	fieldDeploymentID = 8
+	fieldEnv          = 9
+	fieldPreview      = 10
+	fieldScheduled    = 13
+	fieldHost         = 14
+	fieldRemoteAddr   = 15
+
+	fieldMapKey      = 1
+	fieldMapValue    = 2
+	fieldHeaderValue = 1
+)
+
+// decodeRequest decodes the proto.HTTPRequest raptor writes to the stdin of
+// the module. Fields the SDK does not use are skipped.
+func decodeRequest(b []byte) (*Request, error) {
+	r := &Request{
+		Header: Header{},
+		Env:    map[string]string{},
+	}
+	err := decodeMessage(b, func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error {
+		switch {
+		case num == fieldBody && typ == protowire.BytesType:
+			r.Body = append([]byte(nil), v...)
+		case num == fieldMethod && typ == protowire.BytesType:
+			r.Method = string(v)
+		case num == fieldURL && typ == protowire.BytesType:
+			r.RequestURI = string(v)
+		case num == fieldEndpointID && typ == protowire.BytesType:
+			r.Info.EndpointID = string(v)
+		case num == fieldID && typ == protowire.BytesType:
+			r.Info.RequestID = string(v)
+		case num == fieldDeploymentID && typ == protowire.BytesType:
+			r.Info.DeploymentID = string(v)
+		case num == fieldPreview && typ == protowire.VarintType:
+			r.Info.Preview = n != 0
+		case num == fieldScheduled && typ == protowire.VarintType:
+			r.Info.Scheduled = n != 0
+		case num == fieldHost && typ == protowire.BytesType:
+			r.Host = string(v)
+		case num == fieldRemoteAddr && typ == protowire.BytesType:
+			r.RemoteAddr = string(v)
+		case num == fieldEnv && typ == protowire.BytesType:
+			key, value, err := decodeMapEntry(v)
+			if err != nil {
+				return err
+			}
+			r.Env[key] = string(value)
+		case num == fieldHeader && typ == protowire.BytesType:
+			key, value, err := decodeMapEntry(v)
+			if err != nil {
+				return err
+			}
+			var fields []string
+			err = decodeMessage(value, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+				if num == fieldHeaderValue && typ == protowire.BytesType {
+					fields = append(fields, string(v))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			r.Header[key] = fields
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %s", err)
+	}
+
+	u, err := url.ParseRequestURI(r.RequestURI)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %s", err)
+	}
+	r.URL = u
+	return r, nil
+}
+
+// decodeMapEntry decodes the key and value of an entry of a map field.
+func decodeMapEntry(b []byte) (key string, value []byte, err error) {
+	err = decodeMessage(b, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		switch {
+		case num == fieldMapKey && typ == protowire.BytesType:
+			key = string(v)
+		case num == fieldMapValue && typ == protowire.BytesType:
+			value = v
+		}
+		return nil
+	})
+	return key, value, err
+}
+
+// decodeMessage calls fn with every field of the message in b. Fields of the
+// bytes type are passed as v, varints as n.
+func decodeMessage(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var (
+			v      []byte
+			varint uint64
+		)
+		switch typ {
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			varint, n = protowire.ConsumeVarint(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := fn(num, typ, v, varint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+// Package tinygo is the SDK of the tinygo runtime. It speaks the same
+// protocol as the Go SDK, but without net/http and the reflection based
+// protobuf package, which TinyGo either does not support or makes modules
+// a lot larger.
+//
+//	func main() {
+//		tinygo.Handle(func(w *tinygo.ResponseWriter, r *tinygo.Request) {
+//			w.Write([]byte("hello " + r.URL.Query().Get("name")))
+//		})
+//	}
+package tinygo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HandlerFunc handles a request by writing its response to w.
+type HandlerFunc func(w *ResponseWriter, r *Request)
+
+// Handle reads the request from stdin, handles it with h and writes the
+// response to stdout.
+func Handle(h HandlerFunc) {
+	b, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fatal(err)
+	}
+	if err := serve(h, b, os.Stdout); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	os.Stderr.WriteString(err.Error() + "\n")
+	os.Exit(1)
+}
+
+// serve decodes the request, handles it with h and writes the response to
+// out with its headers, followed by its status and the lengths. A handler that panics responds
+// with a 500.
+func serve(h HandlerFunc, b []byte, out io.Writer) error {
+	r, err := decodeRequest(b)
+	if err != nil {
+		return err
+	}
+	w := &ResponseWriter{}
+	if err := serveRequest(h, w, r); err != nil {
+		// The panic goes to the logs of the request.
+		fmt.Fprintf(out, "%s\n", err)
+		w = &ResponseWriter{}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(500)
+		w.Write([]byte(`{"error":"internal server error","request_id":` + strconv.Quote(r.Info.RequestID) + "}\n"))
+	}
+	block := appendHeader(nil, w.header)
+	resp := make([]byte, 0, len(block)+w.buffer.Len()+16)
+	resp = append(resp, block...)
+	resp = append(resp, w.buffer.Bytes()...)
+	resp = binary.LittleEndian.AppendUint32(resp, uint32(w.status()))
+	resp = binary.LittleEndian.AppendUint32(resp, uint32(w.buffer.Len()))
+	resp = binary.LittleEndian.AppendUint32(resp, uint32(len(block)))
+	resp = append(resp, headerMagic...)
+	_, err = out.Write(resp)
+	return err
+}
+
+// headerMagic ends the response when it is preceded by the header block, a
+// "Name: value\n" line per value, like shared.WriteResponse writes it.
+const headerMagic = "RPH1"
+
+// appendHeader appends the header block of the given headers to b, sorted
+// by name. Names and values that do not fit on a line are left out.
+func appendHeader(b []byte, header Header) []byte {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "" || strings.ContainsAny(name, ":\r\n") {
+			continue
+		}
+		for _, v := range header[name] {
+			if strings.ContainsAny(v, "\r\n") {
+				continue
+			}
+			b = append(b, name...)
+			b = append(b, ": "...)
+			b = append(b, v...)
+			b = append(b, '\n')
+		}
+	}
+	return b
+}
+
+// serveRequest calls the handler and returns the panic of the handler, if
+// any, as an error.
+func serveRequest(h HandlerFunc, w *ResponseWriter, r *Request) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic serving %s %s: %v", r.Method, r.RequestURI, v)
+		}
+	}()
+	h(w, r)
+	return nil
+}
+
+// Info holds what raptor knows about the request that is being handled.
+type Info struct {
+	// RequestID is the id of the request, which is also in the x-request-id
+	// header.
+	RequestID    string
+	EndpointID   string
+	DeploymentID string
+	// Preview is true when the request is made on the preview of the
+	// deployment instead of LIVE.
+	Preview bool
+	// Scheduled is true when the request is made by a cron schedule.
+	Scheduled bool
+}
+
+// Request is the request as it was received by the ingress.
+type Request struct {
+	Method string
+	URL    *url.URL
+	// RequestURI is the path and query of the request.
+	RequestURI string
+	Header     Header
+	Body       []byte
+	Host       string
+	RemoteAddr string
+	// Env is the environment of the endpoint, which is also available with
+	// os.Getenv.
+	Env  map[string]string
+	Info Info
+}
+
+// Header holds the headers of a request or response by their canonical
+// name.
+type Header map[string][]string
+
+// Get returns the first value of the header with the given name.
+func (h Header) Get(name string) string {
+	if v := h[canonicalHeaderKey(name)]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// Set replaces the values of the header with the given name.
+func (h Header) Set(name, value string) {
+	h[canonicalHeaderKey(name)] = []string{value}
+}
+
+// Add appends a value to the header with the given name.
+func (h Header) Add(name, value string) {
+	name = canonicalHeaderKey(name)
+	h[name] = append(h[name], value)
+}
+
+// canonicalHeaderKey returns the name like net/http does, content-type
+// becomes Content-Type.
+func canonicalHeaderKey(name string) string {
+	b := []byte(name)
+	upper := true
+	for i, c := range b {
+		if upper && 'a' <= c && c <= 'z' {
+			b[i] = c - 'a' + 'A'
+		} else if !upper && 'A' <= c && c <= 'Z' {
+			b[i] = c - 'A' + 'a'
+		}
+		upper = c == '-'
+	}
+	return string(b)
+}
+
+// ResponseWriter buffers the response of a handler, which is written once
+// the handler returns.
+type ResponseWriter struct {
+	buffer     bytes.Buffer
+	header     Header
+	statusCode int
+}
+
+func (w *ResponseWriter) Header() Header {
+	if w.header == nil {
+		w.header = Header{}
+	}
+	return w.header
+}
+
+// Write writes to the body of the response, with a 200 status unless
+// WriteHeader was called before.
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(200)
+	return w.buffer.Write(b)
+}
+
+// WriteHeader sets the status of the response. Only the first call has an
+// effect.
+func (w *ResponseWriter) WriteHeader(status int) {
+	if w.statusCode == 0 {
+		w.statusCode = status
+	}
+}
+
+func (w *ResponseWriter) status() int {
+	if w.statusCode == 0 {
+		return 200
+	}
+	return w.statusCode
+}
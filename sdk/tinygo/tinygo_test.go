@@ -0,0 +1,112 @@
+package tinygo
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/proto"
+	"github.com/stretchr/testify/require"
+	pb "google.golang.org/protobuf/proto"
+)
+
+func TestServeRequest(t *testing.T) {
+	b, err := pb.Marshal(&proto.HTTPRequest{
+		ID:           "request-id",
+		EndpointID:   "endpoint-id",
+		DeploymentID: "deployment-id",
+		Scheduled:    true,
+		Method:       "POST",
+		URL:          "/users?name=bob",
+		Host:         "app.example.com",
+		RemoteAddr:   "10.0.0.1:1234",
+		Runtime:      "tinygo",
+		AllowedHosts: []string{"example.com"},
+		Header: map[string]*proto.HeaderFields{
+			"X-Foo": {Fields: []string{"bar", "baz"}},
+		},
+		Env:  map[string]string{"FOO": "foo"},
+		Body: []byte("body"),
+	})
+	require.Nil(t, err)
+
+	h := func(w *ResponseWriter, r *Request) {
+		require.Equal(t, "POST", r.Method)
+		require.Equal(t, "/users", r.URL.Path)
+		require.Equal(t, "bob", r.URL.Query().Get("name"))
+		require.Equal(t, "/users?name=bob", r.RequestURI)
+		require.Equal(t, "app.example.com", r.Host)
+		require.Equal(t, "10.0.0.1:1234", r.RemoteAddr)
+		require.Equal(t, "bar", r.Header.Get("x-foo"))
+		require.Equal(t, []string{"bar", "baz"}, r.Header["X-Foo"])
+		require.Equal(t, map[string]string{"FOO": "foo"}, r.Env)
+		require.Equal(t, "body", string(r.Body))
+		require.Equal(t, Info{
+			RequestID:    "request-id",
+			EndpointID:   "endpoint-id",
+			DeploymentID: "deployment-id",
+			Scheduled:    true,
+		}, r.Info)
+
+		w.Header().Set("content-type", "text/plain")
+		require.Equal(t, "text/plain", w.Header()["Content-Type"][0])
+		w.Write([]byte("hello "))
+		// The status is set by the first write.
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("bob"))
+	}
+
+	out := &bytes.Buffer{}
+	require.Nil(t, serve(h, b, out))
+	logs, res, header, status, err := shared.ParseResponse(out)
+	require.Nil(t, err)
+	require.Empty(t, logs)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "hello bob", string(res))
+	require.Equal(t, http.Header{"Content-Type": {"text/plain"}}, header)
+}
+
+func TestServeStatus(t *testing.T) {
+	b, err := pb.Marshal(&proto.HTTPRequest{Method: "GET", URL: "/"})
+	require.Nil(t, err)
+
+	out := &bytes.Buffer{}
+	require.Nil(t, serve(func(w *ResponseWriter, r *Request) {}, b, out))
+	_, res, status, err := shared.ParseStdout(out)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, status)
+	require.Empty(t, res)
+
+	out.Reset()
+	require.Nil(t, serve(func(w *ResponseWriter, r *Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}, b, out))
+	_, _, status, err = shared.ParseStdout(out)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusNoContent, status)
+}
+
+func TestServePanic(t *testing.T) {
+	b, err := pb.Marshal(&proto.HTTPRequest{ID: "request-id", Method: "GET", URL: "/"})
+	require.Nil(t, err)
+
+	out := &bytes.Buffer{}
+	require.Nil(t, serve(func(w *ResponseWriter, r *Request) {
+		w.Write([]byte("partial"))
+		panic("boom")
+	}, b, out))
+	logs, res, status, err := shared.ParseStdout(out)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusInternalServerError, status)
+	require.Contains(t, string(logs), "panic serving GET /: boom")
+
+	var body map[string]string
+	require.Nil(t, json.Unmarshal(res, &body))
+	require.Equal(t, map[string]string{"error": "internal server error", "request_id": "request-id"}, body)
+}
+
+func TestServeInvalidRequest(t *testing.T) {
+	require.NotNil(t, serve(func(w *ResponseWriter, r *Request) {}, []byte{0x0a, 0xff}, &bytes.Buffer{}))
+}
@@ -0,0 +1,122 @@
+package run
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/anthdm/raptor/internal/shared"
+	"github.com/anthdm/raptor/proto"
+	prot "google.golang.org/protobuf/proto"
+)
+
+// ErrClosed is returned when reading from or writing to a closed connection.
+var ErrClosed = errors.New("websocket connection closed")
+
+// WebSocketHandler handles a single WebSocket connection. The connection is
+// closed when the handler returns.
+type WebSocketHandler func(conn *Conn, r *http.Request)
+
+var webSocketHandler WebSocketHandler
+
+// HandleWebSocket registers the handler for WebSocket connections to the
+// endpoint. It needs to be called before Handle. The instance of the guest
+// lives as long as the connection, hence state can be kept in memory.
+//
+// Stdout is used to exchange messages with the connection, use stderr (the
+// log package) for logging.
+func HandleWebSocket(h WebSocketHandler) {
+	webSocketHandler = h
+}
+
+// Message is a single WebSocket message.
+type Message struct {
+	Data   []byte
+	Binary bool
+}
+
+// Conn is a WebSocket connection of a client.
+type Conn struct {
+	in     io.Reader
+	out    io.Writer
+	closed bool
+}
+
+// Read blocks until the next message of the client. It returns ErrClosed when
+// the client closed the connection.
+func (c *Conn) Read() (Message, error) {
+	if c.closed {
+		return Message{}, ErrClosed
+	}
+	frame, err := shared.ReadFrame(c.in)
+	if err != nil {
+		c.closed = true
+		return Message{}, ErrClosed
+	}
+	switch frame.Type {
+	case shared.FrameText:
+		return Message{Data: frame.Data}, nil
+	case shared.FrameBinary:
+		return Message{Data: frame.Data, Binary: true}, nil
+	default:
+		c.closed = true
+		return Message{}, ErrClosed
+	}
+}
+
+// WriteText sends a text message to the client.
+func (c *Conn) WriteText(s string) error {
+	return c.write(shared.Frame{Type: shared.FrameText, Data: []byte(s)})
+}
+
+// WriteBinary sends a binary message to the client.
+func (c *Conn) WriteBinary(b []byte) error {
+	return c.write(shared.Frame{Type: shared.FrameBinary, Data: b})
+}
+
+// Close closes the connection with the given reason.
+func (c *Conn) Close(reason string) error {
+	if c.closed {
+		return nil
+	}
+	err := c.write(shared.Frame{Type: shared.FrameClose, Data: []byte(reason)})
+	c.closed = true
+	return err
+}
+
+func (c *Conn) write(frame shared.Frame) error {
+	if c.closed {
+		return ErrClosed
+	}
+	return shared.WriteFrame(c.out, frame)
+}
+
+func serveWebSocket() {
+	frame, err := shared.ReadFrame(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if frame.Type != shared.FrameOpen {
+		log.Fatal("websocket connection did not start with an open frame")
+	}
+	var req proto.HTTPRequest
+	if err := prot.Unmarshal(frame.Data, &req); err != nil {
+		log.Fatal(err)
+	}
+	r, err := newRequest(&req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn := &Conn{
+		in:  os.Stdin,
+		out: os.Stdout,
+	}
+	if webSocketHandler == nil {
+		conn.Close("endpoint does not handle websocket connections")
+		return
+	}
+	webSocketHandler(conn, r)
+	conn.Close("")
+}